@@ -0,0 +1,304 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Agent is a conversation with a provider that keeps track of the message
+// history across turns
+type Agent struct {
+	client   *Client
+	provider string
+	modelID  string
+	options  []Option
+
+	mu         sync.RWMutex
+	messages   []*Message
+	model      *Model    // Resolved on the first turn
+	usage      Usage     // Summed across turns
+	transcript io.Writer // Optional JSONL transcript
+
+	// Writes to the transcript are ordered separately so a slow writer doesn't
+	// block access to the history
+	transcriptMu sync.Mutex
+}
+
+// Agent creates a stateful agent that chats with the given provider. Messages
+// passed in with WithMessage become the initial history.
+func (c *Client) Agent(provider string, options ...Option) *Agent {
+	config := &Config{}
+	for _, option := range options {
+		option(config)
+	}
+	if p, err := c.findProvider(provider); err == nil {
+		setDefaultModel(config, p)
+	}
+	return &Agent{
+		client:     c,
+		provider:   provider,
+		modelID:    config.Model,
+		options:    options,
+		messages:   append([]*Message{}, config.Messages...),
+		transcript: config.Transcript,
+	}
+}
+
+// Chat sends a user message to the model and streams back the response,
+// recording the turn in the agent's history
+func (a *Agent) Chat(ctx context.Context, prompt string) iter.Seq2[*ChatResponse, error] {
+	return func(yield func(*ChatResponse, error) bool) {
+		config := &Config{}
+		for _, option := range a.options {
+			option(config)
+		}
+		config.Model = a.modelID
+
+		user := UserMessage(prompt)
+		user.ID = config.newID()
+		a.mu.Lock()
+		a.messages = append(a.messages, user)
+		history := append([]*Message{}, a.messages...)
+		a.mu.Unlock()
+		a.record(user, nil)
+
+		a.resolve(ctx)
+		history = a.autoCompact(ctx, config, history)
+
+		if config.Truncation != nil {
+			history = a.truncate(history, toolSchemas(config.tools()), config.Truncation)
+		}
+
+		// Replace any initial messages with the full history
+		options := append(append([]Option{}, a.options...), func(c *Config) {
+			c.Messages = history
+		})
+
+		assistant := &Message{
+			Role: "assistant",
+		}
+		turn := new(Usage)
+		defer func() { a.save(config, assistant, turn) }()
+
+		// Answer tool calls that won't run because the caller stopped early, so
+		// the history stays valid for the next turn
+		var pending []string
+		defer func() {
+			for _, id := range pending {
+				a.append(&Message{
+					ID:         config.newID(),
+					Role:       "tool",
+					Content:    `{"error":"cancelled"}`,
+					ToolCallID: id,
+				})
+			}
+		}()
+
+		// Providers report the usage so far as they stream, so keep the latest
+		// usage of each step and add it once the step is over
+		var step *Usage
+		flush := func() {
+			if step == nil {
+				return
+			}
+			a.mu.Lock()
+			a.usage.add(step)
+			a.mu.Unlock()
+			turn.add(step)
+			step = nil
+		}
+		defer flush()
+
+		for res, err := range a.client.Chat(ctx, a.provider, options...) {
+			if err != nil {
+				if !yield(res, err) {
+					return
+				}
+				continue
+			}
+			// A step is over when the next one starts or its tool results arrive
+			if res.Start || res.ToolCallID != "" {
+				flush()
+			}
+			if res.Usage != nil {
+				step = res.Usage
+			}
+			switch {
+			case res.ToolCall != nil:
+				// Save any text before the tool call first so the history keeps
+				// the order the model wrote them in
+				a.save(config, assistant, nil)
+				assistant = &Message{
+					Role: "assistant",
+				}
+				a.append(&Message{
+					ID:       config.newID(),
+					Role:     res.Role,
+					ToolCall: res.ToolCall,
+				})
+				pending = append(pending, res.ToolCall.ID)
+			case res.ToolCallID != "":
+				pending = slices.DeleteFunc(pending, func(id string) bool { return id == res.ToolCallID })
+				a.append(&Message{
+					ID:         config.newID(),
+					Role:       res.Role,
+					Content:    res.Content,
+					ToolCallID: res.ToolCallID,
+					Parts:      res.Parts,
+				})
+			default:
+				assistant.Thinking += res.Thinking
+				assistant.Content += res.Content
+			}
+			if !yield(res, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Messages returns a copy of the agent's conversation history
+func (a *Agent) Messages() []*Message {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	messages := make([]*Message, len(a.messages))
+	for i, message := range a.messages {
+		clone := *message
+		messages[i] = &clone
+	}
+	return messages
+}
+
+// Tools returns the schemas of the tools the agent advertises to its model
+func (a *Agent) Tools() []*ToolSchema {
+	config := &Config{}
+	for _, option := range a.options {
+		option(config)
+	}
+	config.Model = a.modelID
+	return toolSchemas(config.tools())
+}
+
+// resolve the agent's model for its metadata. Failures are retried on the
+// next turn.
+func (a *Agent) resolve(ctx context.Context) {
+	a.mu.RLock()
+	resolved := a.model != nil
+	a.mu.RUnlock()
+	if resolved || a.modelID == "" {
+		return
+	}
+	model, err := a.client.Model(ctx, a.provider, a.modelID)
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	a.model = model
+	a.mu.Unlock()
+}
+
+// truncate the history to fit in the model's context window
+func (a *Agent) truncate(history []*Message, tools []*ToolSchema, config *Truncation) []*Message {
+	a.mu.RLock()
+	model := a.model
+	a.mu.RUnlock()
+	if model == nil || model.Meta == nil || model.Meta.ContextWindow <= 0 {
+		return history
+	}
+	budget := model.Meta.ContextWindow
+	if model.Meta.MaxOutputTokens < budget {
+		budget -= model.Meta.MaxOutputTokens
+	}
+	return truncate(history, tools, budget, config)
+}
+
+func (a *Agent) append(message *Message) {
+	a.mu.Lock()
+	a.messages = append(a.messages, message)
+	a.mu.Unlock()
+	a.record(message, nil)
+}
+
+// save the assistant message for this turn
+func (a *Agent) save(config *Config, assistant *Message, usage *Usage) {
+	if assistant.Content == "" && assistant.Thinking == "" {
+		return
+	}
+	assistant.ID = config.newID()
+	a.mu.Lock()
+	a.messages = append(a.messages, assistant)
+	a.mu.Unlock()
+	a.record(assistant, usage)
+}
+
+// TranscriptEntry is a line in an agent's transcript
+type TranscriptEntry struct {
+	Time time.Time `json:"time"`
+	*Message
+	Usage *Usage `json:"usage,omitzero"`
+}
+
+// record writes the message to the transcript. Write errors are ignored so a
+// failing transcript doesn't interrupt the conversation.
+func (a *Agent) record(message *Message, usage *Usage) {
+	if a.transcript == nil {
+		return
+	}
+	if usage != nil && *usage == (Usage{}) {
+		usage = nil
+	}
+	line, err := json.Marshal(&TranscriptEntry{
+		Time:    time.Now().UTC(),
+		Message: message,
+		Usage:   usage,
+	})
+	if err != nil {
+		return
+	}
+	a.transcriptMu.Lock()
+	defer a.transcriptMu.Unlock()
+	a.transcript.Write(append(line, '\n'))
+}
+
+// agentState is the serialized form of an agent
+type agentState struct {
+	Provider string     `json:"provider,omitzero"`
+	Messages []*Message `json:"messages"`
+}
+
+// Export serializes the agent's conversation history to JSON so it can be
+// persisted and resumed later with Client.ImportAgent
+func (a *Agent) Export() ([]byte, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	data, err := json.Marshal(&agentState{
+		Provider: a.provider,
+		Messages: a.messages,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("llm: exporting agent: %w", err)
+	}
+	return data, nil
+}
+
+// ImportAgent restores an agent that was serialized with Agent.Export. The
+// imported history replaces any messages set with WithMessage, since it
+// already includes them.
+func (c *Client) ImportAgent(data []byte, options ...Option) (*Agent, error) {
+	state := new(agentState)
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("llm: importing agent: %w", err)
+	}
+	if _, err := c.findProvider(state.Provider); err != nil {
+		return nil, fmt.Errorf("llm: importing agent: %w", err)
+	}
+	agent := c.Agent(state.Provider, options...)
+	agent.messages = state.Messages
+	return agent, nil
+}
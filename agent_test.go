@@ -0,0 +1,352 @@
+package llm_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+var addTool = llm.Func("add", "Add two numbers together", func(ctx context.Context, in struct {
+	A int `json:"a" is:"required"`
+	B int `json:"b" is:"required"`
+}) (int, error) {
+	return in.A + in.B, nil
+})
+
+// mediaTool returns a screenshot alongside its text
+type mediaTool struct {
+	llm.Tool
+}
+
+func (mediaTool) RunParts(ctx context.Context, in json.RawMessage) ([]*llm.ContentPart, error) {
+	return []*llm.ContentPart{
+		{Type: "text", Text: `{"path":"screen.png"}`},
+		llm.ImagePart("image/png", []byte("png")),
+	}, nil
+}
+
+func TestAgentMediaTool(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{toolCall("call_1", "screenshot", `{}`), done()},
+			{text("A blank screen"), done()},
+		},
+	}
+	screenshot := mediaTool{llm.Func("screenshot", "Takes a screenshot", func(ctx context.Context, in struct{}) (string, error) {
+		return "", fmt.Errorf("screenshot: only runs as a media tool")
+	})}
+	agent := llm.New(provider).Agent(provider.Name(), llm.WithModel("fake-model"), llm.WithTool(screenshot))
+	for _, err := range agent.Chat(ctx, "What's on the screen?") {
+		is.NoErr(err)
+	}
+
+	// The image goes back to the model with the tool result
+	requests := provider.Requests()
+	is.Equal(len(requests), 2)
+	history := requests[1].Messages
+	result := history[len(history)-1]
+	is.Equal(result.Role, "tool")
+	is.Equal(result.Content, `{"path":"screen.png"}`)
+	is.Equal(result.Parts, []*llm.ContentPart{llm.ImagePart("image/png", []byte("png"))})
+
+	// And it's kept in the agent's history
+	messages := agent.Messages()
+	is.Equal(len(messages), 4)
+	is.Equal(messages[2].Parts, result.Parts)
+}
+
+func TestAgentExportImport(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	call := toolCall("call_1", "add", `{"a":20,"b":22}`)
+	call.ToolCall.ThoughtSignature = []byte("signature")
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{call, done()},
+			{text("The answer is 42"), done()},
+			{text("You're welcome"), done()},
+		},
+	}
+	client := llm.New(provider)
+	agent := client.Agent(provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithTool(addTool),
+		llm.WithMessage(llm.SystemMessage("You are a calculator")),
+	)
+
+	for _, prompt := range []string{"What is 20+22?", "Thanks!"} {
+		for _, err := range agent.Chat(ctx, prompt) {
+			is.NoErr(err)
+		}
+	}
+
+	data, err := agent.Export()
+	is.NoErr(err)
+
+	var exported struct {
+		Messages []*llm.Message `json:"messages"`
+	}
+	is.NoErr(json.Unmarshal(data, &exported))
+	is.Equal(len(exported.Messages), 7)
+	is.Equal(exported.Messages[0].Role, "system")
+	is.Equal(exported.Messages[1].Content, "What is 20+22?")
+	is.Equal(exported.Messages[2].ToolCall.ID, "call_1")
+	is.Equal(string(exported.Messages[2].ToolCall.ThoughtSignature), "signature")
+	is.Equal(exported.Messages[3].Role, "tool")
+	is.Equal(exported.Messages[3].ToolCallID, "call_1")
+	is.Equal(exported.Messages[3].Content, "42")
+	is.Equal(exported.Messages[4].Content, "The answer is 42")
+	is.Equal(exported.Messages[5].Content, "Thanks!")
+	is.Equal(exported.Messages[6].Content, "You're welcome")
+
+	// Importing with the same options doesn't repeat the system message
+	imported, err := client.ImportAgent(data,
+		llm.WithModel("fake-model"),
+		llm.WithTool(addTool),
+		llm.WithMessage(llm.SystemMessage("You are a calculator")),
+	)
+	is.NoErr(err)
+	is.Equal(imported.Messages(), agent.Messages())
+	reexported, err := imported.Export()
+	is.NoErr(err)
+	is.Equal(string(reexported), string(data))
+}
+
+func TestAgentMessagesCopy(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{text("Hello"), done()},
+		},
+	}
+	agent := llm.New(provider).Agent(provider.Name(), llm.WithModel("fake-model"))
+	for _, err := range agent.Chat(ctx, "Hi") {
+		is.NoErr(err)
+	}
+
+	messages := agent.Messages()
+	is.Equal(len(messages), 2)
+	is.Equal(messages[0].Content, "Hi")
+	is.Equal(messages[1].Content, "Hello")
+
+	// Mutating the copy doesn't change the agent's history
+	messages[1].Content = "Changed"
+	is.Equal(len(agent.Messages()), 2)
+	is.Equal(agent.Messages()[1].Content, "Hello")
+}
+
+func TestAgentImportUnknownProvider(t *testing.T) {
+	is := is.New(t)
+	client := llm.New(&fakeProvider{})
+	_, err := client.ImportAgent([]byte(`{"provider":"missing","messages":[]}`))
+	is.True(err != nil)
+}
+
+func TestAgentTranscript(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	usage := &llm.ChatResponse{Role: "assistant", Usage: &llm.Usage{InputTokens: 10, OutputTokens: 5}}
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{toolCall("call_1", "add", `{"a":20,"b":22}`), usage, done()},
+			{text("The answer is 42"), usage, done()},
+		},
+	}
+	transcript := new(bytes.Buffer)
+	agent := llm.New(provider).Agent(provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithTool(addTool),
+		llm.WithTranscript(transcript),
+	)
+	for _, err := range agent.Chat(ctx, "What is 20+22?") {
+		is.NoErr(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(transcript.String()), "\n")
+	is.Equal(len(lines), 4)
+	var entries []*llm.TranscriptEntry
+	for _, line := range lines {
+		entry := new(llm.TranscriptEntry)
+		is.NoErr(json.Unmarshal([]byte(line), entry))
+		is.True(!entry.Time.IsZero())
+		entries = append(entries, entry)
+	}
+	is.Equal(entries[0].Role, "user")
+	is.Equal(entries[0].Content, "What is 20+22?")
+	is.Equal(entries[1].ToolCall.Name, "add")
+	is.Equal(entries[2].Role, "tool")
+	is.Equal(entries[2].Content, "42")
+	is.Equal(entries[3].Content, "The answer is 42")
+	is.Equal(entries[3].Usage.InputTokens, 20)
+	is.Equal(entries[3].Usage.OutputTokens, 10)
+}
+
+// blockingWriter blocks each write until it's released
+type blockingWriter struct {
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.writing <- struct{}{}
+	<-w.release
+	return len(p), nil
+}
+
+func TestAgentTranscriptSlowWriter(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{{text("Hello"), done()}},
+	}
+	transcript := &blockingWriter{writing: make(chan struct{}), release: make(chan struct{})}
+	agent := llm.New(provider).Agent(provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithTranscript(transcript),
+	)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for range agent.Chat(ctx, "Hi") {
+		}
+	}()
+
+	// The history can be read while the transcript is being written
+	<-transcript.writing
+	data, err := agent.Export()
+	is.NoErr(err)
+	is.True(strings.Contains(string(data), "Hi"))
+	close(transcript.release)
+	<-transcript.writing
+	<-finished
+}
+
+func TestAgentDefaultModel(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		model: "fake-default",
+		turns: [][]*llm.ChatResponse{{text("hi"), done()}},
+	}
+	agent := llm.New(provider).Agent(provider.Name())
+	for _, err := range agent.Chat(ctx, "hi") {
+		is.NoErr(err)
+	}
+	requests := provider.Requests()
+	is.Equal(len(requests), 1)
+	is.Equal(requests[0].Model, "fake-default")
+}
+
+func TestAgentStopCancelsTools(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	cancelled := make(chan struct{})
+	blockTool := llm.Func("block", "Block until cancelled", func(ctx context.Context, in struct{}) (string, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return "", ctx.Err()
+	})
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{
+				toolCall("call_1", "block", `{}`),
+				{Role: "assistant", Usage: &llm.Usage{InputTokens: 1}, Done: true},
+			},
+		},
+	}
+	agent := llm.New(provider).Agent(provider.Name(), llm.WithModel("fake-model"), llm.WithTool(blockTool))
+	for res, err := range agent.Chat(ctx, "block") {
+		is.NoErr(err)
+		// Stop once the tool is running
+		if res.Usage != nil {
+			break
+		}
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("tool wasn't cancelled")
+	}
+}
+
+func TestAgentIDGenerator(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	n := 0
+	generate := func() string {
+		n++
+		return fmt.Sprintf("id-%d", n)
+	}
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			// Providers like Ollama don't send tool call IDs
+			{toolCall("", "add", `{"a":1,"b":2}`), done()},
+			{text("3"), done()},
+		},
+	}
+	agent := llm.New(provider).Agent(provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithTool(addTool),
+		llm.WithIDGenerator(generate),
+	)
+	for _, err := range agent.Chat(ctx, "What is 1+2?") {
+		is.NoErr(err)
+	}
+
+	messages := agent.Messages()
+	is.Equal(len(messages), 4)
+	is.Equal(messages[0].ID, "id-1") // User
+	is.Equal(messages[1].ID, "id-3") // Tool call
+	is.Equal(messages[1].ToolCall.ID, "id-2")
+	is.Equal(messages[2].ID, "id-4") // Tool result
+	is.Equal(messages[2].ToolCallID, "id-2")
+	is.Equal(messages[3].ID, "id-5") // Assistant
+	is.Equal(messages[3].Content, "3")
+}
+
+func TestAgentInterleavedToolCalls(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{text("Let me "), text("add those"), toolCall("call_1", "add", `{"a":20,"b":22}`), done()},
+			{text("The answer is 42"), done()},
+		},
+	}
+	agent := llm.New(provider).Agent(provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithTool(addTool),
+	)
+	for _, err := range agent.Chat(ctx, "What is 20+22?") {
+		is.NoErr(err)
+	}
+
+	// Text before the tool call stays before it in the history
+	messages := agent.Messages()
+	is.Equal(len(messages), 5)
+	is.Equal(messages[0].Role, "user")
+	is.Equal(messages[1].Role, "assistant")
+	is.Equal(messages[1].Content, "Let me add those")
+	is.Equal(messages[2].ToolCall.ID, "call_1")
+	is.Equal(messages[3].ToolCallID, "call_1")
+	is.Equal(messages[4].Content, "The answer is 42")
+}
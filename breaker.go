@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when a provider has failed too many times in a
+// row and requests are short-circuited until the cooldown passes
+var ErrCircuitOpen = errors.New("llm: circuit breaker is open")
+
+// CircuitBreaker configures when to stop sending requests to a failing
+// provider
+type CircuitBreaker struct {
+	Threshold int           // Consecutive failures before the circuit opens
+	Cooldown  time.Duration // How long to fail fast before trying again
+}
+
+// WithCircuitBreaker stops sending requests to a provider for the cooldown
+// window after threshold consecutive failures. Requests during the cooldown
+// fail fast with ErrCircuitOpen. Failure counts are tracked per provider on
+// the client.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(c *Config) {
+		c.CircuitBreaker = &CircuitBreaker{
+			Threshold: threshold,
+			Cooldown:  cooldown,
+		}
+	}
+}
+
+// breaker tracks consecutive failures for a provider
+type breaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// Allow returns false while the circuit is open
+func (b *breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !time.Now().Before(b.openUntil)
+}
+
+// Record the outcome of a request
+func (b *breaker) Record(err error, config *CircuitBreaker) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures >= config.Threshold {
+		b.openUntil = time.Now().Add(config.Cooldown)
+	}
+}
+
+// breaker returns the circuit breaker for a provider
+func (c *Client) breaker(provider string) *breaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.breakers == nil {
+		c.breakers = map[string]*breaker{}
+	}
+	b, ok := c.breakers[provider]
+	if !ok {
+		b = new(breaker)
+		c.breakers[provider] = b
+	}
+	return b
+}
+
+// circuit wraps the provider's stream with its circuit breaker
+func (c *Client) circuit(provider Provider, config *CircuitBreaker, chat func() iter.Seq2[*ChatResponse, error]) iter.Seq2[*ChatResponse, error] {
+	if config == nil {
+		return chat()
+	}
+	breaker := c.breaker(provider.Name())
+	return func(yield func(*ChatResponse, error) bool) {
+		if !breaker.Allow() {
+			yield(nil, fmt.Errorf("llm: provider %q: %w", provider.Name(), ErrCircuitOpen))
+			return
+		}
+		failed := false
+		for res, err := range chat() {
+			// Record the failure before yielding since callers usually stop
+			if err != nil && !failed {
+				failed = true
+				breaker.Record(err, config)
+			}
+			if !yield(res, err) {
+				return
+			}
+		}
+		if !failed {
+			breaker.Record(nil, config)
+		}
+	}
+}
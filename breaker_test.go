@@ -0,0 +1,55 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		fails: []error{errors.New("unavailable"), errors.New("unavailable")},
+		turns: [][]*llm.ChatResponse{{text("Hello"), done()}},
+	}
+	client := llm.New(provider)
+	chat := func() (content string, err error) {
+		for res, err := range client.Chat(ctx, provider.Name(),
+			llm.WithModel("fake-model"),
+			llm.WithMessage(llm.UserMessage("Hi")),
+			llm.WithCircuitBreaker(2, 50*time.Millisecond),
+		) {
+			if err != nil {
+				return "", err
+			}
+			content += res.Content
+		}
+		return content, nil
+	}
+
+	// Two failures open the circuit
+	_, err := chat()
+	is.True(err != nil)
+	is.True(!errors.Is(err, llm.ErrCircuitOpen))
+	_, err = chat()
+	is.True(err != nil)
+	is.True(!errors.Is(err, llm.ErrCircuitOpen))
+
+	// Requests fail fast without reaching the provider
+	_, err = chat()
+	is.True(errors.Is(err, llm.ErrCircuitOpen))
+	is.Equal(len(provider.Requests()), 2)
+
+	// After the cooldown the provider is tried again
+	time.Sleep(60 * time.Millisecond)
+	content, err := chat()
+	is.NoErr(err)
+	is.Equal(content, "Hello")
+	is.Equal(len(provider.Requests()), 3)
+}
@@ -0,0 +1,31 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// CanonicalJSON encodes v as compact JSON with the keys of every object
+// sorted, including the fields of structs, so equal values always encode to
+// the same bytes. Numbers are kept exactly as they were encoded. Tool results
+// are encoded this way so they're stable for snapshot tests and cache keys.
+func CanonicalJSON(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return canonicalize(data)
+}
+
+// canonicalize re-encodes JSON in canonical form. Decoding into maps sorts
+// the keys when they're encoded again.
+func canonicalize(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("llm: canonicalizing json: %w", err)
+	}
+	return json.Marshal(value)
+}
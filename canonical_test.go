@@ -0,0 +1,46 @@
+package llm_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	is := is.New(t)
+	type inner struct {
+		Zebra int `json:"zebra"`
+		Apple int `json:"apple"`
+	}
+	out, err := llm.CanonicalJSON(struct {
+		Name   string         `json:"name"`
+		Inner  inner          `json:"inner"`
+		Extra  map[string]any `json:"extra"`
+		Amount float64        `json:"amount"`
+		Big    json.Number    `json:"big"`
+	}{
+		Name:   "x",
+		Inner:  inner{Zebra: 1, Apple: 2},
+		Extra:  map[string]any{"b": []any{map[string]any{"d": 1, "c": 2}}, "a": nil},
+		Amount: 1.5,
+		Big:    "12345678901234567890",
+	})
+	is.NoErr(err)
+	is.Equal(string(out), `{"amount":1.5,"big":12345678901234567890,"extra":{"a":null,"b":[{"c":2,"d":1}]},"inner":{"apple":2,"zebra":1},"name":"x"}`)
+}
+
+func TestToolResultCanonical(t *testing.T) {
+	is := is.New(t)
+	tool := llm.Func("point", "Get a point", func(ctx context.Context, in struct{}) (any, error) {
+		return struct {
+			Y int `json:"y"`
+			X int `json:"x"`
+		}{Y: 2, X: 1}, nil
+	})
+	out, err := tool.Run(context.Background(), nil)
+	is.NoErr(err)
+	is.Equal(string(out), `{"x":1,"y":2}`)
+}
@@ -0,0 +1,395 @@
+package llm_test
+
+import (
+	"context"
+	"iter"
+	"sync/atomic"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func TestChatDuplicateToolCall(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	counter := llm.Func("count", "Count the calls", func(ctx context.Context, in struct{}) (int, error) {
+		return int(calls.Add(1)), nil
+	})
+
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{
+				toolCall("call_1", "count", `{}`),
+				toolCall("call_1", "count", `{ }`),
+				toolCall("", "count", `{"n":1}`),
+				toolCall("", "count", `{"n": 1}`),
+				done(),
+			},
+			{text("Counted"), done()},
+		},
+	}
+	client := llm.New(provider)
+
+	toolCalls := 0
+	toolResults := 0
+	for res, err := range client.Chat(ctx, provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithTool(counter),
+		llm.WithMessage(llm.UserMessage("Count")),
+	) {
+		is.NoErr(err)
+		if res.ToolCall != nil {
+			toolCalls++
+		}
+		if res.ToolCallID != "" || res.Role == "tool" {
+			toolResults++
+		}
+	}
+	is.Equal(calls.Load(), int32(2))
+	is.Equal(toolCalls, 2)
+	is.Equal(toolResults, 2)
+
+	// The duplicates aren't replayed to the model
+	requests := provider.Requests()
+	is.Equal(len(requests), 2)
+	replayed := 0
+	for _, message := range requests[1].Messages {
+		if message.ToolCall != nil {
+			replayed++
+		}
+	}
+	is.Equal(replayed, 2)
+}
+
+func TestChatDuplicateToolCallID(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	counter := llm.Func("count", "Count the calls", func(ctx context.Context, in struct {
+		A int `json:"a"`
+		B int `json:"b"`
+	}) (int, error) {
+		return int(calls.Add(1)), nil
+	})
+
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{
+				toolCall("call_1", "count", `{"a":1,"b":2}`),
+				toolCall("call_1", "count", `{"b":2,"a":1}`),
+				// Gemini uses the function name as the ID
+				toolCall("count", "count", `{"a":1}`),
+				toolCall("count", "count", `{"a":2}`),
+				done(),
+			},
+			{text("Counted"), done()},
+		},
+	}
+	for _, err := range llm.New(provider).Chat(ctx, provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithTool(counter),
+		llm.WithMessage(llm.UserMessage("Count")),
+	) {
+		is.NoErr(err)
+	}
+	is.Equal(calls.Load(), int32(3))
+}
+
+func TestChatStartEvent(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	start := &llm.ChatResponse{Role: "assistant", Start: true}
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{start, toolCall("call_1", "add", `{"a":1,"b":2}`), done()},
+			{start, text("3"), done()},
+		},
+	}
+	client := llm.New(provider)
+
+	var events []*llm.ChatResponse
+	for res, err := range client.Chat(ctx, provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithTool(addTool),
+		llm.WithMessage(llm.UserMessage("What is 1+2?")),
+	) {
+		is.NoErr(err)
+		events = append(events, res)
+	}
+
+	// Each step opens with its start event, ahead of any content
+	starts := 0
+	for i, res := range events {
+		if !res.Start {
+			continue
+		}
+		starts++
+		is.Equal(res.Content, "")
+		is.True(i == 0 || events[i-1].ToolCallID != "")
+	}
+	is.Equal(starts, 2)
+
+	// The first event is the start, naming who's responding
+	is.Equal(events[0], &llm.ChatResponse{Role: "assistant", Start: true, Provider: "fake", Model: "fake-model"})
+
+	// Start events aren't replayed to the model
+	requests := provider.Requests()
+	is.Equal(len(requests), 2)
+	is.Equal(len(requests[1].Messages), 4) // user, tool call, done, tool result
+	for _, message := range requests[1].Messages {
+		is.True(message.Content != "" || message.ToolCall != nil || message.ToolCallID != "" || message.Role == "assistant")
+	}
+}
+
+func TestChatClampMaxOutputTokens(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		meta: &llm.ModelMeta{MaxOutputTokens: 1000},
+		turns: [][]*llm.ChatResponse{
+			{text("Hello"), done()},
+			{text("Hello"), done()},
+		},
+	}
+	client := llm.New(provider)
+	for _, max := range []int{5000, 500} {
+		for _, err := range client.Chat(ctx, provider.Name(),
+			llm.WithModel("fake-model"),
+			llm.WithMaxOutputTokens(max),
+			llm.WithMessage(llm.UserMessage("Hi")),
+		) {
+			is.NoErr(err)
+		}
+	}
+	requests := provider.Requests()
+	is.Equal(len(requests), 2)
+	is.Equal(requests[0].MaxOutputTokens, 1000)
+	is.Equal(requests[1].MaxOutputTokens, 500)
+}
+
+func TestChatForcedToolChoice(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{toolCall("call_1", "add", `{"a":1,"b":2}`), done()},
+			{text("3"), done()},
+		},
+	}
+	client := llm.New(provider)
+	for _, err := range client.Chat(ctx, provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithTool(addTool),
+		llm.WithToolChoice(llm.ToolChoiceTool("add")),
+		llm.WithMessage(llm.UserMessage("What is 1+2?")),
+	) {
+		is.NoErr(err)
+	}
+
+	// The forced choice only applies to the first step
+	requests := provider.Requests()
+	is.Equal(len(requests), 2)
+	is.Equal(requests[0].ToolChoice, llm.ToolChoiceTool("add"))
+	is.Equal(requests[1].ToolChoice, llm.ToolChoiceAuto)
+}
+
+func TestChatDefaultModel(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		model: "fake-default",
+		turns: [][]*llm.ChatResponse{
+			{text("hi"), done()},
+			{text("hi"), done()},
+			{text("hi"), done()},
+		},
+	}
+	client := llm.New(provider)
+	chat := func(options ...llm.Option) {
+		options = append(options, llm.WithMessage(llm.UserMessage("hi")))
+		for _, err := range client.Chat(ctx, provider.Name(), options...) {
+			is.NoErr(err)
+		}
+	}
+
+	// The provider's default is used without a model
+	chat()
+	// WithDefaultModel overrides the provider's default
+	chat(llm.WithDefaultModel("override"))
+	// WithModel takes precedence over both
+	chat(llm.WithDefaultModel("override"), llm.WithModel("fake-model"))
+
+	requests := provider.Requests()
+	is.Equal(len(requests), 3)
+	is.Equal(requests[0].Model, "fake-default")
+	is.Equal(requests[1].Model, "override")
+	is.Equal(requests[2].Model, "fake-model")
+}
+
+// minimalProvider only implements the required provider methods
+type minimalProvider struct {
+	fake *fakeProvider
+}
+
+func (p minimalProvider) Name() string { return p.fake.Name() }
+func (p minimalProvider) Model(ctx context.Context, id string) (*llm.Model, error) {
+	return p.fake.Model(ctx, id)
+}
+func (p minimalProvider) Models(ctx context.Context) ([]*llm.Model, error) {
+	return p.fake.Models(ctx)
+}
+func (p minimalProvider) Chat(ctx context.Context, req *llm.ChatRequest) iter.Seq2[*llm.ChatResponse, error] {
+	return p.fake.Chat(ctx, req)
+}
+
+func TestChatWithoutDefaultModel(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	// Providers don't have to recommend a model
+	fake := &fakeProvider{
+		model: "fake-default",
+		turns: [][]*llm.ChatResponse{
+			{text("hi"), done()},
+			{text("hi"), done()},
+		},
+	}
+	provider := minimalProvider{fake}
+	client := llm.New(provider)
+	for _, err := range client.Chat(ctx, provider.Name(), llm.WithMessage(llm.UserMessage("hi"))) {
+		is.NoErr(err)
+	}
+	for _, err := range client.Chat(ctx, provider.Name(), llm.WithDefaultModel("override"), llm.WithMessage(llm.UserMessage("hi"))) {
+		is.NoErr(err)
+	}
+	requests := fake.Requests()
+	is.Equal(len(requests), 2)
+	is.Equal(requests[0].Model, "")
+	is.Equal(requests[1].Model, "override")
+}
+
+func TestDefaultModelIn(t *testing.T) {
+	is := is.New(t)
+	meta := map[string]*llm.ModelMeta{
+		"small": {DisplayName: "Small"},
+		"large": (&llm.ModelMeta{DisplayName: "Large"}).AsDefault(),
+	}
+	is.Equal(llm.DefaultModelIn(meta), "large")
+	is.Equal(llm.DefaultModelIn(nil), "")
+}
+
+func TestChatProviderParams(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	primary := &fakeProvider{
+		name:  "primary",
+		fails: []error{statusError(503)},
+	}
+	secondary := &fakeProvider{
+		name: "secondary",
+		turns: [][]*llm.ChatResponse{
+			{text("Hello"), done()},
+		},
+	}
+	client := llm.New(primary, secondary)
+	for _, err := range client.Chat(ctx, "primary",
+		llm.WithModel("shared-model"),
+		llm.WithFallback("secondary"),
+		llm.WithProviderParams("primary", map[string]any{"service_tier": "flex"}),
+		llm.WithProviderParams("primary", map[string]any{"store": false}),
+		llm.WithProviderParams("secondary", map[string]any{"metadata": map[string]any{"user_id": "u1"}}),
+	) {
+		is.NoErr(err)
+	}
+
+	// Each provider only gets its own params
+	is.Equal(primary.Requests()[0].ProviderParams, map[string]any{"service_tier": "flex", "store": false})
+	is.Equal(secondary.Requests()[0].ProviderParams, map[string]any{"metadata": map[string]any{"user_id": "u1"}})
+}
+
+func TestChatEndUser(t *testing.T) {
+	is := is.New(t)
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{{text("hi"), done()}},
+	}
+	client := llm.New(provider)
+	for _, err := range client.Chat(context.Background(), provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithEndUser("user-1234"),
+	) {
+		is.NoErr(err)
+	}
+	is.Equal(provider.Requests()[0].EndUser, "user-1234")
+}
+
+func TestChatModelTools(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	subtract := llm.Func("subtract", "Subtract two numbers", func(ctx context.Context, in struct {
+		A int `json:"a"`
+		B int `json:"b"`
+	}) (int, error) {
+		return in.A - in.B, nil
+	})
+	provider := &fakeProvider{
+		model: "cheap",
+		turns: [][]*llm.ChatResponse{
+			{text("hi"), done()},
+			{text("hi"), done()},
+		},
+	}
+	client := llm.New(provider)
+	options := []llm.Option{
+		llm.WithTool(addTool),
+		llm.WithModelTools("cheap", subtract),
+		llm.WithMessage(llm.UserMessage("hi")),
+	}
+
+	// The default model gets its own tools
+	for _, err := range client.Chat(ctx, provider.Name(), options...) {
+		is.NoErr(err)
+	}
+	// Other models get the shared tools
+	for _, err := range client.Chat(ctx, provider.Name(), append(options, llm.WithModel("expensive"))...) {
+		is.NoErr(err)
+	}
+
+	requests := provider.Requests()
+	is.Equal(len(requests), 2)
+	is.Equal(len(requests[0].Tools), 1)
+	is.Equal(requests[0].Tools[0].Function.Name, "subtract")
+	is.Equal(len(requests[1].Tools), 1)
+	is.Equal(requests[1].Tools[0].Function.Name, "add")
+
+	agent := client.Agent(provider.Name(), options...)
+	is.Equal(agent.Tools()[0].Function.Name, "subtract")
+}
+
+func TestChatRawEvents(t *testing.T) {
+	is := is.New(t)
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{{text("Hello"), done()}},
+	}
+	var events []any
+	for _, err := range llm.New(provider).Chat(context.Background(), provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithRawEvents(func(provider string, event any) {
+			is.Equal(provider, "fake")
+			events = append(events, event)
+		}),
+	) {
+		is.NoErr(err)
+	}
+	is.Equal(len(events), 2)
+	is.Equal(events[0].(*llm.ChatResponse).Content, "Hello")
+}
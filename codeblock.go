@@ -0,0 +1,140 @@
+package llm
+
+import (
+	"iter"
+	"strings"
+)
+
+// CodeBlock is a fenced code block from a response
+type CodeBlock struct {
+	Language string // From the fence's info string, if any
+	Code     string
+}
+
+// ExtractCodeBlocks returns the fenced code blocks in the content, in order.
+// Both backtick and tilde fences are supported. A block that's still open at
+// the end of the content runs to the end.
+func ExtractCodeBlocks(content string) (blocks []*CodeBlock) {
+	parser := new(fenceParser)
+	for line := range strings.Lines(content) {
+		if block := parser.line(line); block != nil {
+			blocks = append(blocks, block)
+		}
+	}
+	if block := parser.close(); block != nil {
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// StreamCodeBlocks yields the fenced code blocks in the assistant's content as
+// soon as each one closes, so callers can act on a block while the rest of the
+// response is still streaming
+func StreamCodeBlocks(stream iter.Seq2[*ChatResponse, error]) iter.Seq2[*CodeBlock, error] {
+	return func(yield func(*CodeBlock, error) bool) {
+		parser := new(fenceParser)
+		pending := ""
+		for res, err := range stream {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			if res.Role != "assistant" || res.Content == "" {
+				continue
+			}
+			pending += res.Content
+			// Only parse whole lines, the rest waits for more content
+			for {
+				i := strings.IndexByte(pending, '\n')
+				if i < 0 {
+					break
+				}
+				line := pending[:i+1]
+				pending = pending[i+1:]
+				if block := parser.line(line); block != nil {
+					if !yield(block, nil) {
+						return
+					}
+				}
+			}
+		}
+		if pending != "" {
+			if block := parser.line(pending); block != nil {
+				if !yield(block, nil) {
+					return
+				}
+			}
+		}
+		if block := parser.close(); block != nil {
+			yield(block, nil)
+		}
+	}
+}
+
+// fenceParser finds fenced code blocks a line at a time
+type fenceParser struct {
+	fence    string // Opening fence of the current block, empty outside one
+	language string
+	code     strings.Builder
+}
+
+// line parses the next line, returning a block when the line closes one
+func (p *fenceParser) line(line string) *CodeBlock {
+	trimmed := strings.TrimRight(line, "\r\n")
+	if p.fence == "" {
+		fence, info, ok := openingFence(trimmed)
+		if !ok {
+			return nil
+		}
+		p.fence = fence
+		p.language, _, _ = strings.Cut(strings.TrimSpace(info), " ")
+		p.code.Reset()
+		return nil
+	}
+	// A closing fence uses the same character, is at least as long as the
+	// opening fence and has nothing after it
+	if closing := strings.TrimSpace(trimmed); indent(trimmed) < 4 &&
+		len(closing) >= len(p.fence) && strings.Trim(closing, p.fence[:1]) == "" {
+		return p.close()
+	}
+	p.code.WriteString(line)
+	return nil
+}
+
+// close ends the current block, if there is one
+func (p *fenceParser) close() *CodeBlock {
+	if p.fence == "" {
+		return nil
+	}
+	block := &CodeBlock{
+		Language: p.language,
+		Code:     strings.TrimSuffix(p.code.String(), "\n"),
+	}
+	p.fence = ""
+	return block
+}
+
+// openingFence parses a line like "```go", returning the fence and info string
+func openingFence(line string) (fence, info string, ok bool) {
+	if indent(line) > 3 {
+		return "", "", false
+	}
+	line = strings.TrimLeft(line, " ")
+	if !strings.HasPrefix(line, "```") && !strings.HasPrefix(line, "~~~") {
+		return "", "", false
+	}
+	n := len(line) - len(strings.TrimLeft(line, line[:1]))
+	fence, info = line[:n], line[n:]
+	// Backtick fences can't have backticks in their info string
+	if fence[0] == '`' && strings.Contains(info, "`") {
+		return "", "", false
+	}
+	return fence, info, true
+}
+
+// indent counts the leading spaces
+func indent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
@@ -0,0 +1,49 @@
+package llm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func TestExtractCodeBlocks(t *testing.T) {
+	is := is.New(t)
+	content := "Here's the server:\n\n" +
+		"```go title=\"main.go\"\npackage main\n\nfunc main() {}\n```\n\n" +
+		"Run it with:\n\n" +
+		"~~~\ngo run .\n~~~\n\n" +
+		"````markdown\n```js\nconsole.log(1)\n```\n````\n" +
+		"```python\nprint('unfinished')"
+	blocks := llm.ExtractCodeBlocks(content)
+	is.Equal(len(blocks), 4)
+	is.Equal(blocks[0], &llm.CodeBlock{Language: "go", Code: "package main\n\nfunc main() {}"})
+	is.Equal(blocks[1], &llm.CodeBlock{Code: "go run ."})
+	is.Equal(blocks[2], &llm.CodeBlock{Language: "markdown", Code: "```js\nconsole.log(1)\n```"})
+	is.Equal(blocks[3], &llm.CodeBlock{Language: "python", Code: "print('unfinished')"})
+}
+
+func TestExtractCodeBlocksNone(t *testing.T) {
+	is := is.New(t)
+	is.Equal(len(llm.ExtractCodeBlocks("No code here, just `inline` code.")), 0)
+}
+
+func TestStreamCodeBlocks(t *testing.T) {
+	is := is.New(t)
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{{
+			text("Sure:\n``"), text("`sh\necho hi"), text("\n```\nand then"),
+			text(":\n```go\nfmt.Println()\n``"), text("`"), done(),
+		}},
+	}
+	client := llm.New(provider)
+	var blocks []*llm.CodeBlock
+	for block, err := range llm.StreamCodeBlocks(client.Chat(context.Background(), provider.Name(), llm.WithModel("fake-model"))) {
+		is.NoErr(err)
+		blocks = append(blocks, block)
+	}
+	is.Equal(len(blocks), 2)
+	is.Equal(blocks[0], &llm.CodeBlock{Language: "sh", Code: "echo hi"})
+	is.Equal(blocks[1], &llm.CodeBlock{Language: "go", Code: "fmt.Println()"})
+}
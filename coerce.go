@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// coerceNumbers rewrites numbers in the arguments that the model formatted
+// differently than the target type expects, like 42.0, 4.2e1 or "42" for an
+// int. Numbers with a fraction are left alone so they still fail to decode
+// into an int rather than being silently rounded.
+func coerceNumbers(args json.RawMessage, t reflect.Type) (json.RawMessage, bool) {
+	dec := json.NewDecoder(bytes.NewReader(args))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, false
+	}
+	coerced, changed := coerceValue(value, t)
+	if !changed {
+		return nil, false
+	}
+	out, err := json.Marshal(coerced)
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// coerceValue coerces the decoded value to fit the type, reporting whether
+// anything changed
+func coerceValue(value any, t reflect.Type) (any, bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return coerceInt(value, func(s string) error {
+			_, err := strconv.ParseInt(s, 10, 64)
+			return err
+		})
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return coerceInt(value, func(s string) error {
+			_, err := strconv.ParseUint(s, 10, 64)
+			return err
+		})
+	case reflect.Float32, reflect.Float64:
+		if _, ok := value.(string); !ok {
+			return value, false
+		}
+		f, ok := toFloat(value)
+		if !ok {
+			return value, false
+		}
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), true
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]any)
+		if !ok {
+			return value, false
+		}
+		changed := false
+		for i, item := range items {
+			var itemChanged bool
+			items[i], itemChanged = coerceValue(item, t.Elem())
+			changed = changed || itemChanged
+		}
+		return items, changed
+	case reflect.Map:
+		object, ok := value.(map[string]any)
+		if !ok || t.Key().Kind() != reflect.String {
+			return value, false
+		}
+		changed := false
+		for key, item := range object {
+			var itemChanged bool
+			object[key], itemChanged = coerceValue(item, t.Elem())
+			changed = changed || itemChanged
+		}
+		return object, changed
+	case reflect.Struct:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return value, false
+		}
+		fields := fieldTypes(t)
+		changed := false
+		for key, item := range object {
+			// encoding/json matches field names case-insensitively
+			field, ok := fields[strings.ToLower(key)]
+			if !ok {
+				continue
+			}
+			var itemChanged bool
+			object[key], itemChanged = coerceValue(item, field)
+			changed = changed || itemChanged
+		}
+		return object, changed
+	}
+	return value, false
+}
+
+// coerceInt coerces the value to an integer. Integers are kept exactly as
+// written, since going through a float would corrupt ones above 2^53, so only
+// whole numbers written like 42.0 or 4.2e1 are reformatted.
+func coerceInt(value any, parse func(s string) error) (any, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		if parse(string(v)) == nil {
+			return value, false
+		}
+	case string:
+		if s := strings.TrimSpace(v); parse(s) == nil {
+			return json.Number(s), true
+		}
+	}
+	f, ok := toFloat(value)
+	if !ok || f != math.Trunc(f) || math.IsInf(f, 0) {
+		return value, false
+	}
+	return json.Number(strconv.FormatFloat(f, 'f', -1, 64)), true
+}
+
+// toFloat parses a JSON number or a string holding one
+func toFloat(value any) (float64, bool) {
+	var s string
+	switch v := value.(type) {
+	case json.Number:
+		s = string(v)
+	case string:
+		s = strings.TrimSpace(v)
+	default:
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+// fieldTypes maps the lowercased JSON names of a struct's fields to their
+// types, including the fields of embedded structs
+func fieldTypes(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name := field.Name
+		if jsonTag := field.Tag.Get("json"); jsonTag != "" {
+			parts := strings.Split(jsonTag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+		}
+		if field.Anonymous && field.Tag.Get("json") == "" && field.Type.Kind() == reflect.Struct {
+			for name, typ := range fieldTypes(field.Type) {
+				if _, ok := fields[name]; !ok {
+					fields[name] = typ
+				}
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		fields[strings.ToLower(name)] = field.Type
+	}
+	return fields
+}
@@ -0,0 +1,223 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Compaction configures how an agent summarizes its older turns
+type Compaction struct {
+	TriggerTokens int    // Estimated tokens that trigger compaction (0 to only compact manually)
+	KeepLastN     int    // Number of most recent turns to keep as is
+	Model         string // Model that writes the summary (empty for the agent's model)
+	ChunkTokens   int    // Estimated tokens summarized in each request (0 for 50,000)
+}
+
+// defaultChunkTokens leaves room in most context windows for the prompt and
+// the summary
+const defaultChunkTokens = 50_000
+
+// WithAutoCompact compacts the agent's history before a turn once the
+// estimated tokens of the history pass triggerTokens, so long conversations
+// keep working without hitting the context window. If compacting fails, the
+// turn goes ahead with the full history.
+func WithAutoCompact(triggerTokens int) Option {
+	return func(c *Config) {
+		if c.Compaction == nil {
+			c.Compaction = &Compaction{}
+		}
+		c.Compaction.TriggerTokens = triggerTokens
+	}
+}
+
+// WithCompaction sets how many of the most recent turns are kept as is when
+// the agent compacts its history, and the model that summarizes the rest. An
+// empty model uses the agent's model.
+func WithCompaction(keepLastN int, model string) Option {
+	return func(c *Config) {
+		if c.Compaction == nil {
+			c.Compaction = &Compaction{}
+		}
+		c.Compaction.KeepLastN = keepLastN
+		c.Compaction.Model = model
+	}
+}
+
+const compactPrompt = `Summarize the conversation below so it can continue without the original messages. Keep the user's goals, decisions that were made, facts that were learned, results of tool calls that still matter and any open tasks. Be concise and only respond with the summary.`
+
+// summaryPrefix starts the message that replaces the compacted turns
+const summaryPrefix = "Summary of the earlier conversation:\n\n"
+
+// Compact replaces the agent's older turns with a summary written by the
+// model. System messages and the most recent turns are kept as is. It's a
+// no-op when there aren't enough turns to compact. The transcript keeps the
+// full history.
+func (a *Agent) Compact(ctx context.Context) error {
+	config := &Config{}
+	for _, option := range a.options {
+		option(config)
+	}
+	compaction := config.Compaction
+	if compaction == nil {
+		compaction = &Compaction{}
+	}
+
+	a.mu.RLock()
+	history := append([]*Message{}, a.messages...)
+	a.mu.RUnlock()
+
+	turns := splitTurns(history)
+	keep := max(compaction.KeepLastN, 1)
+	if len(turns) <= keep {
+		return nil
+	}
+	var compacted []*Message
+	for _, turn := range turns[:len(turns)-keep] {
+		compacted = append(compacted, turn...)
+	}
+
+	// System messages aren't summarized, but earlier summaries are summarized
+	// again with the turns after them so they don't pile up
+	var system []*Message
+	var lines []string
+	for _, message := range compacted {
+		if message.Role == "system" {
+			if summary, ok := strings.CutPrefix(message.Content, summaryPrefix); ok {
+				lines = append(lines, "summary of the earlier conversation: "+summary+"\n")
+				continue
+			}
+			system = append(system, message)
+			continue
+		}
+		line := new(strings.Builder)
+		writeCompactMessage(line, message)
+		if line.Len() > 0 {
+			lines = append(lines, line.String())
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	model := compaction.Model
+	if model == "" {
+		model = a.modelID
+	}
+	chunkTokens := compaction.ChunkTokens
+	if chunkTokens <= 0 {
+		chunkTokens = defaultChunkTokens
+	}
+
+	// Summarize long conversations a chunk at a time, carrying the summary so
+	// far into the next chunk
+	summary := ""
+	for _, chunk := range compactChunks(lines, chunkTokens*charsPerToken) {
+		if summary != "" {
+			chunk = "summary of the earlier conversation: " + summary + "\n" + chunk
+		}
+		var err error
+		summary, err = a.summarize(ctx, model, chunk)
+		if err != nil {
+			return fmt.Errorf("llm: compacting history: %w", err)
+		}
+	}
+
+	message := SystemMessage(summaryPrefix + summary)
+	message.ID = config.newID()
+
+	// Turns may have been added while summarizing
+	a.mu.Lock()
+	a.messages = append(append(system, message), a.messages[len(compacted):]...)
+	a.mu.Unlock()
+	return nil
+}
+
+// summarize asks the model to summarize the conversation. It keeps the
+// agent's options, like provider params, retries and fallbacks, but doesn't
+// offer tools or constrain the response.
+func (a *Agent) summarize(ctx context.Context, model, conversation string) (string, error) {
+	options := append(append([]Option{}, a.options...), func(c *Config) {
+		c.Model = model
+		c.Thinking = ThinkingNone
+		c.Tools, c.ModelTools = nil, nil
+		c.ToolChoice = ToolChoice{}
+		c.ResponseSchema = nil
+		c.StopSequences = nil
+		c.Messages = []*Message{SystemMessage(compactPrompt), UserMessage(conversation)}
+	})
+	summary := new(strings.Builder)
+	for res, err := range a.client.Chat(ctx, a.provider, options...) {
+		if err != nil {
+			return "", err
+		}
+		summary.WriteString(res.Content)
+	}
+	if strings.TrimSpace(summary.String()) == "" {
+		return "", errors.New("empty summary")
+	}
+	return strings.TrimSpace(summary.String()), nil
+}
+
+// compactChunks groups the lines of the conversation into chunks of up to
+// max bytes, cutting lines that don't fit in a chunk on their own
+func compactChunks(lines []string, max int) (chunks []string) {
+	const truncated = " [truncated]\n"
+	chunk := new(strings.Builder)
+	for _, line := range lines {
+		if len(line) > max {
+			line = prefix(line, max-len(truncated)) + truncated
+		}
+		if chunk.Len() > 0 && chunk.Len()+len(line) > max {
+			chunks = append(chunks, chunk.String())
+			chunk.Reset()
+		}
+		chunk.WriteString(line)
+	}
+	if chunk.Len() > 0 {
+		chunks = append(chunks, chunk.String())
+	}
+	return chunks
+}
+
+// writeCompactMessage writes the message as a line of the conversation to
+// summarize
+func writeCompactMessage(w *strings.Builder, message *Message) {
+	switch {
+	case message.ToolCall != nil:
+		fmt.Fprintf(w, "%s called %s(%s)\n", message.Role, message.ToolCall.Name, message.ToolCall.Arguments)
+	case message.ToolCallID != "":
+		fmt.Fprintf(w, "tool result: %s\n", message.Content)
+	case message.Content != "":
+		fmt.Fprintf(w, "%s: %s\n", message.Role, message.Content)
+	}
+	for _, part := range message.Parts {
+		if part.Type == "text" {
+			fmt.Fprintf(w, "%s: %s\n", message.Role, part.Text)
+		}
+	}
+}
+
+// autoCompact compacts the history when it's grown past the trigger, logging
+// failures so the turn can go ahead with the full history
+func (a *Agent) autoCompact(ctx context.Context, config *Config, history []*Message) []*Message {
+	if config.Compaction == nil || config.Compaction.TriggerTokens <= 0 {
+		return history
+	}
+	if EstimateTokens(history, toolSchemas(config.tools())) <= config.Compaction.TriggerTokens {
+		return history
+	}
+	if err := a.Compact(ctx); err != nil {
+		log := config.Log
+		if log == nil {
+			log = slog.Default()
+		}
+		log.Warn("llm: unable to compact history", "err", err)
+		return history
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return append([]*Message{}, a.messages...)
+}
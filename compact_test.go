@@ -0,0 +1,145 @@
+package llm_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func TestAgentAutoCompact(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{text("Paris"), done()},
+			{text("The user asked for the capital of France"), done()},
+			{text("About 2 million"), done()},
+		},
+	}
+	agent := llm.New(provider).Agent(provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithMessage(llm.SystemMessage("You are a geography tutor")),
+		llm.WithAutoCompact(10),
+		llm.WithCompaction(1, "cheap-model"),
+	)
+	for _, prompt := range []string{"What's the capital of France?", "How many people live there?"} {
+		for _, err := range agent.Chat(ctx, prompt) {
+			is.NoErr(err)
+		}
+	}
+
+	requests := provider.Requests()
+	is.Equal(len(requests), 3)
+
+	// The first turn is summarized by the compaction model
+	summarize := requests[1]
+	is.Equal(summarize.Model, "cheap-model")
+	is.Equal(len(summarize.Tools), 0)
+	is.True(strings.Contains(summarize.Messages[1].Content, "user: What's the capital of France?"))
+	is.True(strings.Contains(summarize.Messages[1].Content, "assistant: Paris"))
+
+	// The next request has the system prompt, the summary and the current turn
+	messages := requests[2].Messages
+	is.Equal(len(messages), 3)
+	is.Equal(messages[0].Content, "You are a geography tutor")
+	is.Equal(messages[1].Role, "system")
+	is.True(strings.HasSuffix(messages[1].Content, "The user asked for the capital of France"))
+	is.Equal(messages[2].Content, "How many people live there?")
+
+	is.Equal(len(agent.Messages()), 4)
+}
+
+func TestAgentCompactTooFewTurns(t *testing.T) {
+	is := is.New(t)
+	provider := &fakeProvider{}
+	agent := llm.New(provider).Agent(provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithMessage(llm.UserMessage("Hi")),
+	)
+	is.NoErr(agent.Compact(context.Background()))
+	is.Equal(len(provider.Requests()), 0)
+}
+
+func TestAgentCompactFoldsSummaries(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{text("Paris"), done()},
+			{text("About 2 million"), done()},
+			{text("The user asked about Paris"), done()},
+			{text("The user asked about Paris and its population"), done()},
+		},
+	}
+	agent := llm.New(provider).Agent(provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithMessage(llm.SystemMessage("You are a geography tutor")),
+		llm.WithEndUser("user-1"),
+		llm.WithProviderParams(provider.Name(), map[string]any{"service_tier": "flex"}),
+	)
+	for _, prompt := range []string{"What's the capital of France?", "How many people live there?"} {
+		for _, err := range agent.Chat(ctx, prompt) {
+			is.NoErr(err)
+		}
+	}
+	is.NoErr(agent.Compact(ctx))
+	is.NoErr(agent.Compact(ctx))
+
+	// The second summary replaces the first instead of being added after it
+	messages := agent.Messages()
+	is.Equal(len(messages), 4)
+	is.Equal(messages[0].Content, "You are a geography tutor")
+	is.Equal(messages[1].Content, "Summary of the earlier conversation:\n\nThe user asked about Paris and its population")
+	is.Equal(messages[2].Content, "How many people live there?")
+
+	requests := provider.Requests()
+	is.Equal(len(requests), 4)
+	is.True(strings.Contains(requests[3].Messages[1].Content, "summary of the earlier conversation: The user asked about Paris"))
+
+	// The summaries are requested with the agent's options
+	is.Equal(requests[3].EndUser, "user-1")
+	is.Equal(requests[3].ProviderParams, map[string]any{"service_tier": "flex"})
+}
+
+func TestAgentCompactChunks(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{text("ok"), done()},
+			{text("ok"), done()},
+			{text("ok"), done()},
+			{text("First part"), done()},
+			{text("Second part"), done()},
+			{text("All parts"), done()},
+		},
+	}
+	agent := llm.New(provider).Agent(provider.Name(),
+		llm.WithModel("fake-model"),
+		func(c *llm.Config) { c.Compaction = &llm.Compaction{KeepLastN: 1, ChunkTokens: 20} },
+	)
+	for _, prompt := range []string{strings.Repeat("a", 50), strings.Repeat("b", 500), "Last"} {
+		for _, err := range agent.Chat(ctx, prompt) {
+			is.NoErr(err)
+		}
+	}
+	is.NoErr(agent.Compact(ctx))
+
+	// Each chunk fits in 20 tokens, cutting the message that doesn't fit, and
+	// carries the summary so far
+	requests := provider.Requests()
+	is.Equal(len(requests), 6)
+	first := requests[3].Messages[1].Content
+	is.Equal(first, "user: "+strings.Repeat("a", 50)+"\nassistant: ok\n")
+	second := requests[4].Messages[1].Content
+	is.True(strings.HasPrefix(second, "summary of the earlier conversation: First part\nuser: bbb"))
+	is.True(strings.HasSuffix(second, "b [truncated]\n"))
+	is.Equal(len(second)-len("summary of the earlier conversation: First part\n"), 80)
+	third := requests[5].Messages[1].Content
+	is.Equal(third, "summary of the earlier conversation: Second part\nassistant: ok\n")
+	is.Equal(agent.Messages()[0].Content, "Summary of the earlier conversation:\n\nAll parts")
+}
@@ -0,0 +1,34 @@
+package llm
+
+// Cost estimates the cost of the usage in dollars. Returns zero if the model
+// metadata or its prices aren't known.
+func (u *Usage) Cost(meta *ModelMeta) float64 {
+	if u == nil || meta == nil {
+		return 0
+	}
+	input := float64(u.InputTokens) * meta.InputCostPerMTok
+	output := float64(u.OutputTokens) * meta.OutputCostPerMTok
+	return (input + output) / 1_000_000
+}
+
+// add the other usage to this usage
+func (u *Usage) add(other *Usage) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.TotalTokens += other.TotalTokens
+	u.CachedInputTokens += other.CachedInputTokens
+	u.ReasoningTokens += other.ReasoningTokens
+	u.CacheReadTokens += other.CacheReadTokens
+	u.CacheWriteTokens += other.CacheWriteTokens
+}
+
+// Cost estimates the cost in dollars of every turn so far, using the prices
+// of the agent's model. Returns zero if the model's prices aren't known.
+func (a *Agent) Cost() float64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.model == nil {
+		return 0
+	}
+	return a.usage.Cost(a.model.Meta)
+}
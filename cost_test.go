@@ -0,0 +1,55 @@
+package llm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func TestUsageCost(t *testing.T) {
+	is := is.New(t)
+	meta := &llm.ModelMeta{InputCostPerMTok: 3, OutputCostPerMTok: 15}
+	usage := &llm.Usage{InputTokens: 1_000_000, OutputTokens: 200_000}
+	is.Equal(usage.Cost(meta), 6.0)
+	is.Equal(usage.Cost(nil), 0.0)
+
+	var missing *llm.Usage
+	is.Equal(missing.Cost(meta), 0.0)
+}
+
+func TestAgentCost(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	usage := func(input, output int) *llm.ChatResponse {
+		return &llm.ChatResponse{
+			Role:  "assistant",
+			Usage: &llm.Usage{InputTokens: input, OutputTokens: output},
+			Done:  true,
+		}
+	}
+	provider := &fakeProvider{
+		meta: &llm.ModelMeta{InputCostPerMTok: 2, OutputCostPerMTok: 10},
+		turns: [][]*llm.ChatResponse{
+			// Usage is reported cumulatively within a step
+			{toolCall("call_1", "add", `{"a":1,"b":2}`), usage(500, 50), usage(1000, 100)},
+			{text("3"), usage(2000, 200)},
+			{text("You're welcome"), usage(3000, 300)},
+		},
+	}
+	agent := llm.New(provider).Agent(provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithTool(addTool),
+	)
+
+	for _, prompt := range []string{"What is 1+2?", "Thanks!"} {
+		for _, err := range agent.Chat(ctx, prompt) {
+			is.NoErr(err)
+		}
+	}
+
+	// 6000 input tokens at $2/MTok and 600 output tokens at $10/MTok
+	is.Equal(agent.Cost(), 0.018)
+}
@@ -0,0 +1,23 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// Embed embeds each input as a vector with the provider's embedding model.
+// Vectors are returned in the same order as the inputs.
+func (c *Client) Embed(ctx context.Context, provider, model string, inputs ...string) ([][]float32, error) {
+	p, err := c.findProvider(provider)
+	if err != nil {
+		return nil, err
+	}
+	embedder, ok := p.(Embedder)
+	if !ok {
+		return nil, fmt.Errorf("llm: provider %q doesn't support embeddings", p.Name())
+	}
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+	return embedder.Embed(ctx, model, inputs)
+}
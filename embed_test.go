@@ -0,0 +1,38 @@
+package llm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+// fakeEmbedder embeds each input as its length
+type fakeEmbedder struct {
+	fakeProvider
+}
+
+func (p *fakeEmbedder) Embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	vectors := make([][]float32, len(inputs))
+	for i, input := range inputs {
+		vectors[i] = []float32{float32(len(input))}
+	}
+	return vectors, nil
+}
+
+func TestEmbed(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	client := llm.New(&fakeEmbedder{fakeProvider{name: "embedder"}}, &fakeProvider{})
+	vectors, err := client.Embed(ctx, "embedder", "fake-embed", "a", "abc")
+	is.NoErr(err)
+	is.Equal(vectors, [][]float32{{1}, {3}})
+
+	_, err = client.Embed(ctx, "fake", "fake-embed", "a")
+	is.True(err != nil)
+
+	_, err = client.Embed(ctx, "missing", "fake-embed", "a")
+	is.True(err != nil)
+}
@@ -0,0 +1,68 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"net"
+	"net/http"
+)
+
+// WithFallback tries the given providers in order when the primary provider
+// fails before streaming anything back. The same request, including the
+// model, is sent to each provider. Only hard failures like auth errors,
+// missing models, 5xx responses and connection errors fall back.
+func WithFallback(providers ...string) Option {
+	return func(c *Config) {
+		c.Fallbacks = append(c.Fallbacks, providers...)
+	}
+}
+
+// fallback streams from each provider in turn until one doesn't fail up front
+func fallback(ctx context.Context, providers []Provider, chat func(Provider) iter.Seq2[*ChatResponse, error]) iter.Seq2[*ChatResponse, error] {
+	if len(providers) == 1 {
+		return chat(providers[0])
+	}
+	return func(yield func(*ChatResponse, error) bool) {
+		for i, provider := range providers {
+			streamed, falling := false, false
+			for res, err := range chat(provider) {
+				if err != nil && !streamed && i < len(providers)-1 && hardFailure(ctx, err) {
+					falling = true
+					break
+				}
+				// The start event doesn't carry any content
+				if err == nil && !res.Start {
+					streamed = true
+				}
+				if !yield(res, err) {
+					return
+				}
+			}
+			if !falling {
+				return
+			}
+		}
+	}
+}
+
+// hardFailure returns true for errors where another provider may do better.
+// Errors without a status code only fall back when the provider couldn't be
+// reached or its circuit breaker is open, since the rest, like an invalid
+// request, would fail anywhere.
+func hardFailure(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	switch code := statusCode(err); {
+	case code == 0:
+		var netErr net.Error
+		return transient(err) || errors.As(err, &netErr) || errors.Is(err, ErrCircuitOpen)
+	case code == http.StatusUnauthorized,
+		code == http.StatusForbidden,
+		code == http.StatusNotFound,
+		code >= http.StatusInternalServerError:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func TestFallback(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	primary := &fakeProvider{
+		name:  "primary",
+		fails: []error{statusError(503)},
+	}
+	secondary := &fakeProvider{
+		name: "secondary",
+		turns: [][]*llm.ChatResponse{
+			{text("Hello"), done()},
+		},
+	}
+	client := llm.New(primary, secondary)
+
+	content := ""
+	for res, err := range client.Chat(ctx, "primary",
+		llm.WithModel("shared-model"),
+		llm.WithFallback("secondary"),
+	) {
+		is.NoErr(err)
+		content += res.Content
+	}
+	is.Equal(content, "Hello")
+	is.Equal(len(primary.Requests()), 1)
+	is.Equal(len(secondary.Requests()), 1)
+	is.Equal(secondary.Requests()[0].Model, "shared-model")
+}
+
+func TestFallbackSoftFailure(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	primary := &fakeProvider{
+		name:  "primary",
+		fails: []error{statusError(400)},
+	}
+	secondary := &fakeProvider{name: "secondary"}
+	client := llm.New(primary, secondary)
+
+	var errs []error
+	for _, err := range client.Chat(ctx, "primary",
+		llm.WithModel("shared-model"),
+		llm.WithFallback("secondary"),
+	) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	is.Equal(len(errs), 1)
+	is.Equal(errs[0], statusError(400))
+	is.Equal(len(secondary.Requests()), 0)
+}
+
+func TestFallbackConnectionError(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	// The primary provider can't be reached
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	primary := &fakeProvider{
+		name:  "primary",
+		fails: []error{fmt.Errorf("primary: %w", refused)},
+	}
+	secondary := &fakeProvider{
+		name: "secondary",
+		turns: [][]*llm.ChatResponse{
+			{text("Hello"), done()},
+		},
+	}
+	client := llm.New(primary, secondary)
+
+	content := ""
+	for res, err := range client.Chat(ctx, "primary",
+		llm.WithModel("shared-model"),
+		llm.WithFallback("secondary"),
+	) {
+		is.NoErr(err)
+		content += res.Content
+	}
+	is.Equal(content, "Hello")
+	is.Equal(len(secondary.Requests()), 1)
+}
+
+func TestFallbackInvalidRequest(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	// Errors without a response that aren't connection errors fail anywhere
+	primary := &fakeProvider{
+		name:  "primary",
+		fails: []error{errors.New("primary: invalid tool schema")},
+	}
+	secondary := &fakeProvider{name: "secondary"}
+	client := llm.New(primary, secondary)
+
+	var errs []error
+	for _, err := range client.Chat(ctx, "primary",
+		llm.WithModel("shared-model"),
+		llm.WithFallback("secondary"),
+	) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	is.Equal(len(errs), 1)
+	is.Equal(errs[0].Error(), "primary: invalid tool schema")
+	is.Equal(len(secondary.Requests()), 0)
+}
+
+func TestFallbackUnknownProvider(t *testing.T) {
+	is := is.New(t)
+	client := llm.New(&fakeProvider{})
+	for _, err := range client.Chat(context.Background(), "fake", llm.WithFallback("missing")) {
+		is.True(err != nil)
+	}
+}
+
+func TestFallbackCircuitOpen(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	primary := &fakeProvider{
+		name:  "primary",
+		fails: []error{statusError(503)},
+	}
+	secondary := &fakeProvider{
+		name: "secondary",
+		turns: [][]*llm.ChatResponse{
+			{text("Hello"), done()},
+			{text("Hello again"), done()},
+		},
+	}
+	client := llm.New(primary, secondary)
+	chat := func() (content string, err error) {
+		for res, err := range client.Chat(ctx, "primary",
+			llm.WithModel("shared-model"),
+			llm.WithCircuitBreaker(1, time.Minute),
+			llm.WithFallback("secondary"),
+		) {
+			if err != nil {
+				return "", err
+			}
+			content += res.Content
+		}
+		return content, nil
+	}
+
+	content, err := chat()
+	is.NoErr(err)
+	is.Equal(content, "Hello")
+
+	// The primary's circuit is open, so the secondary answers right away
+	content, err = chat()
+	is.NoErr(err)
+	is.Equal(content, "Hello again")
+	is.Equal(len(primary.Requests()), 1)
+	is.Equal(len(secondary.Requests()), 2)
+}
@@ -0,0 +1,72 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Generate runs a chat and unmarshals the model's final response into T. The
+// response is constrained to T's schema on providers that support structured
+// output.
+func Generate[T any](ctx context.Context, client *Client, provider string, options ...Option) (out T, err error) {
+	schema := generateSchema(out)
+	options = append(options, func(c *Config) {
+		c.ResponseSchema = schema
+	})
+	content := new(strings.Builder)
+	for res, err := range client.Chat(ctx, provider, options...) {
+		if err != nil {
+			return out, err
+		}
+		// Only keep the content after the last tool call
+		if res.ToolCall != nil {
+			content.Reset()
+			continue
+		}
+		if res.Role != "assistant" {
+			continue
+		}
+		content.WriteString(res.Content)
+	}
+	data := trimCodeFence(content.String())
+	if err := validateObject(data, schema); err != nil {
+		return out, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&out); err != nil {
+		return out, fmt.Errorf("llm: unable to decode response into %T: %w", out, err)
+	}
+	return out, nil
+}
+
+// trimCodeFence strips a markdown code fence that some models wrap JSON in
+func trimCodeFence(content string) []byte {
+	content = strings.TrimSpace(content)
+	if rest, ok := strings.CutPrefix(content, "```"); ok {
+		// Drop the language on the opening fence
+		if i := strings.IndexByte(rest, '\n'); i >= 0 {
+			rest = rest[i+1:]
+		}
+		content = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(rest), "```"))
+	}
+	return []byte(content)
+}
+
+// validateObject checks that the data is a JSON object with the schema's
+// required properties
+func validateObject(data []byte, schema *ToolFunctionParameters) error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return fmt.Errorf("llm: response is not a valid JSON object: %w", err)
+	}
+	for _, name := range schema.Required {
+		if _, ok := object[name]; !ok {
+			return fmt.Errorf("llm: response is missing required property %q", name)
+		}
+	}
+	return nil
+}
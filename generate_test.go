@@ -0,0 +1,79 @@
+package llm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+type person struct {
+	Name string `json:"name" is:"required"`
+	Age  int    `json:"age"`
+}
+
+func TestGenerate(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{text("```json\n{\"name\": \"Alice\","), text(` "age": 30}`+"\n```"), done()},
+		},
+	}
+	client := llm.New(provider)
+	out, err := llm.Generate[person](ctx, client, provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithMessage(llm.UserMessage("Who is Alice?")),
+	)
+	is.NoErr(err)
+	is.Equal(out, person{Name: "Alice", Age: 30})
+
+	requests := provider.Requests()
+	is.Equal(len(requests), 1)
+	schema := requests[0].ResponseSchema
+	is.True(schema != nil)
+	is.Equal(schema.Properties["name"].Type, "string")
+	is.Equal(schema.Properties["age"].Type, "integer")
+	is.Equal(schema.Required, []string{"name"})
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{text(`Alice is 30`), done()},
+			{text(`{"age": 30}`), done()},
+			{text(`{"name": "Alice", "age": "thirty"}`), done()},
+			{text(`{"name": "Alice", "height": 170}`), done()},
+		},
+	}
+	client := llm.New(provider)
+	for range 4 {
+		_, err := llm.Generate[person](ctx, client, provider.Name(), llm.WithModel("fake-model"))
+		is.True(err != nil)
+	}
+}
+
+func TestWithResponseSchema(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{{text(`{}`), done()}},
+	}
+	client := llm.New(provider)
+	for _, err := range client.Chat(ctx, provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithResponseSchema(&person{}),
+	) {
+		is.NoErr(err)
+	}
+	schema := provider.Requests()[0].ResponseSchema
+	is.True(schema != nil)
+	is.Equal(schema.Type, "object")
+	is.Equal(len(schema.Properties), 2)
+}
@@ -0,0 +1,21 @@
+package llm
+
+import "crypto/rand"
+
+// WithIDGenerator sets how IDs are generated for tool calls that providers
+// send without one and for the messages an agent records. Deterministic IDs
+// are handy in tests and prefixed IDs can carry trace context. IDs are random
+// by default.
+func WithIDGenerator(generate func() string) Option {
+	return func(c *Config) {
+		c.IDGenerator = generate
+	}
+}
+
+// newID generates an ID with the configured generator
+func (c *Config) newID() string {
+	if c.IDGenerator != nil {
+		return c.IDGenerator()
+	}
+	return rand.Text()
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"text/tabwriter"
@@ -17,11 +18,16 @@ import (
 	"github.com/matthewmueller/llm"
 	"github.com/matthewmueller/llm/internal/env"
 	"github.com/matthewmueller/llm/providers/anthropic"
+	"github.com/matthewmueller/llm/providers/deepseek"
 	"github.com/matthewmueller/llm/providers/gemini"
+	"github.com/matthewmueller/llm/providers/groq"
 	"github.com/matthewmueller/llm/providers/ollama"
 	"github.com/matthewmueller/llm/providers/openai"
+	"github.com/matthewmueller/llm/providers/openrouter"
 	"github.com/matthewmueller/llm/sandbox/container"
+	"github.com/matthewmueller/llm/tool/clock"
 	"github.com/matthewmueller/llm/tool/fetch"
+	"github.com/matthewmueller/llm/tool/pty"
 	"github.com/matthewmueller/llm/tool/shell"
 	"github.com/matthewmueller/prompt"
 )
@@ -29,6 +35,7 @@ import (
 func New(log *slog.Logger) *CLI {
 	return &CLI{
 		log:    log,
+		Stdin:  os.Stdin,
 		Stdout: os.Stdout,
 		Stderr: os.Stderr,
 		Env:    os.Environ(),
@@ -38,6 +45,7 @@ func New(log *slog.Logger) *CLI {
 
 type CLI struct {
 	log    *slog.Logger
+	Stdin  io.Reader
 	Stdout io.Writer
 	Stderr io.Writer
 	Env    []string
@@ -52,18 +60,33 @@ func (c *CLI) Parse(ctx context.Context, args ...string) error {
 	cli.Flag("thinking", "thinking level: low, medium, high").Short('t').Enum(&cmd.Thinking, "none", "low", "medium", "high").Default("medium")
 	cli.Args("prompt", "prompt to send to the model").Optional().Strings(&cmd.Prompt)
 	cli.Flag("format", "output format").Enum(&cmd.Format, "text", "json").Default("text")
+	cli.Flag("export", "print the conversation in this format when it ends").Optional().Enum(&cmd.Export, "md", "json")
 	cli.Run(func(ctx context.Context) error {
 		return c.Chat(ctx, cmd)
 	})
 
 	{ // $ llm models
+		in := &Models{Log: c.log}
 		cli := cli.Command("models", "list available models")
+		cli.Flag("capabilities", "show model capabilities").Bool(&in.Capabilities).Default(false)
+		cli.Flag("sort", "sort models by provider, cutoff or context").Enum(&in.Sort, "provider", "cutoff", "context").Default("provider")
+		cli.Flag("group-reasoning", "list reasoning models first").Bool(&in.GroupReasoning).Default(false)
 		cli.Run(func(ctx context.Context) error {
-			return c.Models(ctx, &Models{
-				Log:      c.log,
-				Provider: cmd.Provider,
-				Format:   cmd.Format,
-			})
+			in.Provider = cmd.Provider
+			in.Format = cmd.Format
+			return c.Models(ctx, in)
+		})
+	}
+
+	{ // $ llm count-tokens
+		in := &CountTokens{Log: c.log}
+		cli := cli.Command("count-tokens", "count the tokens in a prompt")
+		cli.Flag("file", "file to read the prompt from").Optional().String(&in.File)
+		cli.Args("text", "text to count, read from stdin if empty").Optional().Strings(&in.Text)
+		cli.Run(func(ctx context.Context) error {
+			in.Provider = cmd.Provider
+			in.Model = cmd.Model
+			return c.CountTokens(ctx, in)
 		})
 	}
 
@@ -78,17 +101,53 @@ type Chat struct {
 	Thinking string
 	Prompt   []string
 	Format   string
+	Export   *string
+}
+
+// azureDeployments parses deployments like "prod=gpt-5". A deployment without
+// a model is assumed to be named after the model it serves.
+func azureDeployments(entries []string) (deployments []*openai.Deployment, err error) {
+	for _, entry := range entries {
+		name, model, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			model = name
+		}
+		if name == "" || model == "" {
+			return nil, fmt.Errorf("cli: invalid AZURE_OPENAI_DEPLOYMENTS entry %q", entry)
+		}
+		deployments = append(deployments, &openai.Deployment{Name: name, Model: model})
+	}
+	if len(deployments) == 0 {
+		return nil, fmt.Errorf("cli: AZURE_OPENAI_DEPLOYMENTS is required to use Azure OpenAI")
+	}
+	return deployments, nil
 }
 
 func (c *CLI) providers(env *env.Env) (providers []llm.Provider, err error) {
 	if env.AnthropicKey != "" {
-		providers = append(providers, anthropic.New(env.AnthropicKey))
+		providers = append(providers, anthropic.New(env.AnthropicKey, anthropic.WithBaseURL(env.AnthropicBaseURL)))
 	}
 	if env.OpenAIKey != "" {
-		providers = append(providers, openai.New(env.OpenAIKey))
+		providers = append(providers, openai.New(env.OpenAIKey, openai.WithBaseURL(env.OpenAIBaseURL)))
+	}
+	if env.AzureOpenAIEndpoint != "" && env.AzureOpenAIKey != "" {
+		deployments, err := azureDeployments(env.AzureOpenAIDeployments)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, openai.NewAzure(env.AzureOpenAIEndpoint, env.AzureOpenAIKey, env.AzureOpenAIAPIVersion, deployments...))
 	}
 	if env.GeminiKey != "" {
-		providers = append(providers, gemini.New(env.GeminiKey))
+		providers = append(providers, gemini.New(env.GeminiKey, gemini.WithBaseURL(env.GeminiBaseURL)))
+	}
+	if env.GroqKey != "" {
+		providers = append(providers, groq.New(env.GroqKey))
+	}
+	if env.OpenRouterKey != "" {
+		providers = append(providers, openrouter.New(env.OpenRouterKey))
+	}
+	if env.DeepSeekKey != "" {
+		providers = append(providers, deepseek.New(env.DeepSeekKey))
 	}
 	if env.OllamaHost != "" {
 		host, err := url.Parse(env.OllamaHost)
@@ -118,13 +177,21 @@ func (c *CLI) provider(providers []llm.Provider, name *string) (provider llm.Pro
 	return nil, fmt.Errorf("cli: provider not found: %s", *name)
 }
 
-// Chat with the LLM
-func (c *CLI) Chat(ctx context.Context, in *Chat) error {
-	// TODO: can we just pick the most recent model as a default?
-	if in.Model == nil {
-		return fmt.Errorf("cli: model is required")
+// defaultModel returns the provider's default model, if it has one
+func defaultModel(provider llm.Provider) *string {
+	defaulter, ok := provider.(llm.DefaultModeler)
+	if !ok {
+		return nil
+	}
+	model := defaulter.DefaultModel()
+	if model == "" {
+		return nil
 	}
+	return &model
+}
 
+// Chat with the LLM
+func (c *CLI) Chat(ctx context.Context, in *Chat) error {
 	env, err := env.Load()
 	if err != nil {
 		return fmt.Errorf("cli: unable to load env: %w", err)
@@ -140,6 +207,14 @@ func (c *CLI) Chat(ctx context.Context, in *Chat) error {
 		return fmt.Errorf("cli: unable to find provider: %w", err)
 	}
 
+	// Fall back to the provider's default model
+	if in.Model == nil {
+		in.Model = defaultModel(provider)
+		if in.Model == nil {
+			return fmt.Errorf("cli: model is required for %s", provider.Name())
+		}
+	}
+
 	lc := llm.New(providers...)
 	model, err := lc.Model(ctx, provider.Name(), *in.Model)
 	if err != nil {
@@ -156,48 +231,70 @@ func (c *CLI) Chat(ctx context.Context, in *Chat) error {
 	sandbox := container.New("alpine",
 		container.WithWorkDir("/app"),
 		container.WithVolume(tmpDir, "/app"),
+		container.WithAutoStart(),
 	)
+	defer sandbox.Close()
 
+	tools := []llm.Tool{
+		shell.New(sandbox),
+		pty.New(sandbox),
+		fetch.New(http.DefaultClient),
+		clock.Now(),
+		clock.ParseDate(),
+	}
 	options := []llm.Option{
 		llm.WithModel(*in.Model),
 		llm.WithThinking(llm.Thinking(in.Thinking)),
-		llm.WithTool(
-			shell.New(sandbox),
-			fetch.New(http.DefaultClient),
-		),
+		llm.WithTool(tools...),
 	}
 
 	// Log the provider and model we're using
 	fmt.Fprintln(c.Stderr, color.Dim(provider.Name()+" "+*in.Model))
 
+	agent := lc.Agent(provider.Name(), options...)
+
 	if len(in.Prompt) > 0 {
-		options = append(options,
-			llm.WithMessage(
-				llm.UserMessage(strings.Join(in.Prompt, " ")),
-			),
-		)
-		for res, err := range lc.Chat(ctx, provider.Name(), options...) {
+		for res, err := range agent.Chat(ctx, strings.Join(in.Prompt, " ")) {
 			if err != nil {
 				return err
 			}
 			if res.Thinking != "" {
 				fmt.Fprint(c.Stderr, color.Dim(res.Thinking))
 			}
-			if res.Content != "" {
+			// The exported conversation includes the response
+			if res.Content != "" && in.Export == nil {
 				fmt.Fprint(c.Stdout, res.Content)
 			}
 		}
+		if in.Export != nil {
+			return c.export(agent, *in.Export)
+		}
 		return nil
 	}
 
-	messages := []*llm.Message{}
 	var lastUsage *llm.Usage
+	var schemas []*llm.ToolSchema
+	for _, tool := range tools {
+		schemas = append(schemas, tool.Schema())
+	}
+
+	// Load local models while the user types their first prompt
+	if _, ok := provider.(llm.Warmer); ok {
+		go func() {
+			if err := lc.Warmup(ctx, provider.Name(), *in.Model); err != nil {
+				c.log.Debug("unable to warm up model", "err", err)
+			}
+		}()
+	}
 
 	// Interactive mode
 	for {
 		input, err := prompt.Ask(ctx, "$")
 		if err != nil {
 			if err == prompt.ErrInterrupted {
+				if in.Export != nil {
+					return c.export(agent, *in.Export)
+				}
 				return nil
 			}
 			return err
@@ -206,26 +303,32 @@ func (c *CLI) Chat(ctx context.Context, in *Chat) error {
 		if input == "" {
 			continue
 		}
-		if c.handleReplCommand(input, model, messages, lastUsage) {
+		if c.handleReplCommand(input, model, agent, schemas, lastUsage) {
 			continue
 		}
-		messages = append(messages, llm.UserMessage(input))
-		turnOptions := append(options,
-			llm.WithMessage(messages...),
-		)
-		assistant := &llm.Message{
-			Role: "assistant",
-		}
 		hasNewline := true
 		isThinking := true
+		isWaiting := false
 		var turnUsage *llm.Usage
-		for res, err := range lc.Chat(ctx, provider.Name(), turnOptions...) {
+		for res, err := range agent.Chat(ctx, input) {
 			if err != nil {
 				return err
 			}
 			if res.Usage != nil {
 				turnUsage = res.Usage
 			}
+			// Show an indicator until the first token arrives
+			if res.Start {
+				if hasNewline && !isWaiting {
+					fmt.Fprint(c.Stderr, color.Dim("thinking..."))
+					isWaiting = true
+				}
+				continue
+			}
+			if isWaiting && (res.Thinking != "" || res.Content != "" || res.ToolCall != nil || res.ToolCallID != "") {
+				fmt.Fprint(c.Stderr, clearLine)
+				isWaiting = false
+			}
 			if res.Thinking != "" {
 				fmt.Fprint(c.Stderr, color.Dim(res.Thinking))
 				hasNewline = strings.HasSuffix(res.Thinking, "\n")
@@ -236,10 +339,6 @@ func (c *CLI) Chat(ctx context.Context, in *Chat) error {
 					hasNewline = true
 				}
 				c.log.Info("tool call", "name", res.ToolCall.Name, "args", string(res.ToolCall.Arguments), "id", res.ToolCall.ID)
-				messages = append(messages, &llm.Message{
-					Role:     res.Role,
-					ToolCall: res.ToolCall,
-				})
 				continue
 			}
 			if res.ToolCallID != "" {
@@ -248,11 +347,6 @@ func (c *CLI) Chat(ctx context.Context, in *Chat) error {
 					hasNewline = true
 				}
 				c.log.Info("tool result", "id", res.ToolCallID, "result", res.Content)
-				messages = append(messages, &llm.Message{
-					Role:       res.Role,
-					Content:    res.Content,
-					ToolCallID: res.ToolCallID,
-				})
 				continue
 			}
 			if res.Content != "" {
@@ -260,15 +354,13 @@ func (c *CLI) Chat(ctx context.Context, in *Chat) error {
 					fmt.Fprintln(c.Stderr)
 				}
 				fmt.Fprint(c.Stdout, res.Content)
-				assistant.Content += res.Content
 				isThinking = false
 				hasNewline = strings.HasSuffix(res.Content, "\n")
 			}
 		}
 
-		// Save the assistant message for this turn
-		if assistant.Content != "" {
-			messages = append(messages, assistant)
+		if isWaiting {
+			fmt.Fprint(c.Stderr, clearLine)
 		}
 		if turnUsage != nil {
 			lastUsage = turnUsage
@@ -279,23 +371,74 @@ func (c *CLI) Chat(ctx context.Context, in *Chat) error {
 	}
 }
 
+// clearLine moves the cursor to the start of the line and erases it
+const clearLine = "\r\033[K"
+
 const maxContextSnippet = 72
 
-func (c *CLI) handleReplCommand(input string, model *llm.Model, messages []*llm.Message, usage *llm.Usage) bool {
+func (c *CLI) handleReplCommand(input string, model *llm.Model, agent *llm.Agent, tools []*llm.ToolSchema, usage *llm.Usage) bool {
 	fields := strings.Fields(strings.TrimSpace(input))
 	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
 		return false
 	}
 	switch fields[0] {
 	case "/context":
-		fmt.Fprintln(c.Stdout, formatContextSummary(model, messages, usage))
+		fmt.Fprintln(c.Stdout, formatContextSummary(model, agent.Messages(), tools, usage))
+	case "/save":
+		if len(fields) != 2 {
+			fmt.Fprintln(c.Stderr, "usage: /save <path.md|path.json>")
+			return true
+		}
+		if err := saveConversation(agent, fields[1]); err != nil {
+			fmt.Fprintln(c.Stderr, err)
+			return true
+		}
+		fmt.Fprintln(c.Stderr, color.Dim("saved "+fields[1]))
 	default:
 		fmt.Fprintf(c.Stderr, "unknown command: %s\n", fields[0])
 	}
 	return true
 }
 
-func formatContextSummary(model *llm.Model, messages []*llm.Message, usage *llm.Usage) string {
+// export prints the conversation in the given format
+func (c *CLI) export(agent *llm.Agent, format string) error {
+	data, err := formatConversation(agent, format)
+	if err != nil {
+		return err
+	}
+	_, err = c.Stdout.Write(data)
+	return err
+}
+
+// saveConversation writes the conversation to path, as Markdown for .md files
+// and JSON otherwise
+func saveConversation(agent *llm.Agent, path string) error {
+	format := "json"
+	if filepath.Ext(path) == ".md" {
+		format = "md"
+	}
+	data, err := formatConversation(agent, format)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("cli: unable to save conversation: %w", err)
+	}
+	return nil
+}
+
+func formatConversation(agent *llm.Agent, format string) ([]byte, error) {
+	if format == "md" {
+		return []byte(llm.ExportMarkdown(agent.Messages())), nil
+	}
+	data, err := agent.Export()
+	if err != nil {
+		return nil, fmt.Errorf("cli: unable to export conversation: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+func formatContextSummary(model *llm.Model, messages []*llm.Message, tools []*llm.ToolSchema, usage *llm.Usage) string {
 	contextWindow := 0
 	if model.Meta != nil {
 		contextWindow = model.Meta.ContextWindow
@@ -309,7 +452,14 @@ func formatContextSummary(model *llm.Model, messages []*llm.Message, usage *llm.
 			formatPercent((float64(usage.InputTokens)/float64(contextWindow))*100),
 		)
 	} else if contextWindow > 0 {
-		fmt.Fprintf(&b, "context: unknown/%s used, %d messages\n", formatInt(contextWindow), len(messages))
+		// Estimate until the provider reports usage
+		estimate := llm.EstimateTokens(messages, tools)
+		fmt.Fprintf(&b, "context: ~%s/%s estimated (%s), %d messages\n",
+			formatInt(estimate),
+			formatInt(contextWindow),
+			formatPercent((float64(estimate)/float64(contextWindow))*100),
+			len(messages),
+		)
 	} else {
 		fmt.Fprintf(&b, "context: unknown/window_unknown, %d messages\n", len(messages))
 	}
@@ -418,9 +568,12 @@ func formatInt(n int) string {
 }
 
 type Models struct {
-	Log      *slog.Logger
-	Provider *string
-	Format   string
+	Log            *slog.Logger
+	Provider       *string
+	Format         string
+	Capabilities   bool
+	Sort           string
+	GroupReasoning bool
 }
 
 // Models lists available models
@@ -446,6 +599,12 @@ func (c *CLI) Models(ctx context.Context, in *Models) error {
 	if err != nil {
 		return fmt.Errorf("cli: listing models: %w", err)
 	}
+	llm.SortModels(models, llm.ModelSort(in.Sort), in.GroupReasoning)
+
+	if in.Capabilities {
+		fmt.Fprint(c.Stdout, formatCapabilities(models))
+		return nil
+	}
 
 	for _, m := range models {
 		fmt.Fprint(c.Stdout, m.ID)
@@ -454,3 +613,90 @@ func (c *CLI) Models(ctx context.Context, in *Models) error {
 
 	return nil
 }
+
+// formatCapabilities renders a table of what each model supports. Models
+// without curated metadata show "-" for unknown values.
+func formatCapabilities(models []*llm.Model) string {
+	var table strings.Builder
+	tw := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "provider\tmodel\treasoning\tcontext\tmax output\tcutoff")
+	for _, m := range models {
+		reasoning, contextWindow, maxOutput, cutoff := "-", "-", "-", "-"
+		if m.Meta != nil {
+			reasoning = "no"
+			if m.Meta.HasReasoning {
+				reasoning = "yes"
+			}
+			if m.Meta.ContextWindow > 0 {
+				contextWindow = formatInt(m.Meta.ContextWindow)
+			}
+			if m.Meta.MaxOutputTokens > 0 {
+				maxOutput = formatInt(m.Meta.MaxOutputTokens)
+			}
+			if !m.Meta.KnowledgeCutoff.IsZero() {
+				cutoff = m.Meta.KnowledgeCutoff.Format("2006-01")
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", m.Provider, m.ID, reasoning, contextWindow, maxOutput, cutoff)
+	}
+	tw.Flush()
+	return table.String()
+}
+
+type CountTokens struct {
+	Log      *slog.Logger
+	Provider *string
+	Model    *string
+	File     *string
+	Text     []string
+}
+
+// CountTokens prints the number of tokens in a prompt
+func (c *CLI) CountTokens(ctx context.Context, in *CountTokens) error {
+	var text string
+	switch {
+	case in.File != nil:
+		data, err := os.ReadFile(*in.File)
+		if err != nil {
+			return fmt.Errorf("cli: unable to read prompt: %w", err)
+		}
+		text = string(data)
+	case len(in.Text) > 0:
+		text = strings.Join(in.Text, " ")
+	default:
+		data, err := io.ReadAll(c.Stdin)
+		if err != nil {
+			return fmt.Errorf("cli: unable to read prompt: %w", err)
+		}
+		text = string(data)
+	}
+
+	env, err := env.Load()
+	if err != nil {
+		return fmt.Errorf("cli: unable to load env: %w", err)
+	}
+
+	providers, err := c.providers(env)
+	if err != nil {
+		return fmt.Errorf("cli: unable to load providers: %w", err)
+	}
+
+	provider, err := c.provider(providers, in.Provider)
+	if err != nil {
+		return fmt.Errorf("cli: unable to find provider: %w", err)
+	}
+
+	lc := llm.New(providers...)
+	// Without a model, the provider's default is used
+	options := []llm.Option{llm.WithMessage(llm.UserMessage(text))}
+	if in.Model != nil {
+		options = append(options, llm.WithModel(*in.Model))
+	}
+	count, err := lc.CountTokens(ctx, provider.Name(), options...)
+	if err != nil {
+		return fmt.Errorf("cli: counting tokens: %w", err)
+	}
+
+	fmt.Fprintln(c.Stdout, count)
+	return nil
+}
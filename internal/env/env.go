@@ -6,11 +6,23 @@ import (
 
 // Env holds environment configuration for LLM providers
 type Env struct {
-	AnthropicKey string `env:"ANTHROPIC_API_KEY"`
-	OpenAIKey    string `env:"OPENAI_API_KEY"`
-	GeminiKey    string `env:"GEMINI_API_KEY"`
-	OllamaHost   string `env:"OLLAMA_HOST" envDefault:"http://localhost:11434"`
-	OllamaModel  string `env:"OLLAMA_MODEL"`
+	AnthropicKey          string `env:"ANTHROPIC_API_KEY"`
+	AnthropicBaseURL      string `env:"ANTHROPIC_BASE_URL"`
+	OpenAIKey             string `env:"OPENAI_API_KEY"`
+	OpenAIBaseURL         string `env:"OPENAI_BASE_URL"`
+	GeminiKey             string `env:"GEMINI_API_KEY"`
+	GeminiBaseURL         string `env:"GEMINI_BASE_URL"`
+	GroqKey               string `env:"GROQ_API_KEY"`
+	OpenRouterKey         string `env:"OPENROUTER_API_KEY"`
+	DeepSeekKey           string `env:"DEEPSEEK_API_KEY"`
+	AzureOpenAIEndpoint   string `env:"AZURE_OPENAI_ENDPOINT"`
+	AzureOpenAIKey        string `env:"AZURE_OPENAI_API_KEY"`
+	AzureOpenAIAPIVersion string `env:"AZURE_OPENAI_API_VERSION" envDefault:"2025-04-01-preview"`
+	// Comma-separated deployments like "prod=gpt-5,mini=gpt-5-mini", where the
+	// first is the default
+	AzureOpenAIDeployments []string `env:"AZURE_OPENAI_DEPLOYMENTS"`
+	OllamaHost             string   `env:"OLLAMA_HOST" envDefault:"http://localhost:11434"`
+	OllamaModel            string   `env:"OLLAMA_MODEL"`
 }
 
 // Load reads environment variables
@@ -0,0 +1,45 @@
+// Package established tells a provider when its response stream connects,
+// for SDKs that only surface the stream once the first event arrives
+package established
+
+import (
+	"context"
+	"net/http"
+)
+
+type hookKey struct{}
+
+// Context calls fn once a request made with the context gets a successful
+// response, before its body is read. An error from fn aborts the request.
+func Context(ctx context.Context, fn func() error) context.Context {
+	return context.WithValue(ctx, hookKey{}, fn)
+}
+
+// Client wraps the client's transport to call the hooks set with Context
+func Client(client *http.Client) *http.Client {
+	clone := *client
+	base := clone.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	clone.Transport = &transport{base}
+	return &clone
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	res, err := t.base.RoundTrip(r)
+	if err != nil || res.StatusCode >= 300 {
+		return res, err
+	}
+	if fn, ok := r.Context().Value(hookKey{}).(func() error); ok {
+		if err := fn(); err != nil {
+			res.Body.Close()
+			return nil, err
+		}
+	}
+	return res, nil
+}
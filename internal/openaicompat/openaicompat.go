@@ -0,0 +1,501 @@
+// Package openaicompat implements llm.Provider for services that expose an
+// OpenAI-compatible chat completions API
+package openaicompat
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"sort"
+
+	"github.com/matthewmueller/llm"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/packages/param"
+	"github.com/openai/openai-go/shared"
+)
+
+// Config for an OpenAI-compatible provider
+type Config struct {
+	Name         string                         // Provider name
+	BaseURL      string                         // Base URL of the API, e.g. https://api.groq.com/openai/v1
+	APIKey       string                         // API key sent as a bearer token
+	DefaultModel string                         // Model used when a request doesn't set one
+	Meta         func(id string) *llm.ModelMeta // Looks up model metadata (optional)
+	Options      []option.RequestOption         // Extra request options
+	// Reasoning returns request options that set the reasoning level for
+	// reasoning models, for APIs that don't use reasoning_effort (optional)
+	Reasoning func(level llm.Thinking) []option.RequestOption
+}
+
+// New creates a client for an OpenAI-compatible chat completions API
+func New(config *Config) *Client {
+	options := append([]option.RequestOption{
+		option.WithAPIKey(config.APIKey),
+		option.WithBaseURL(config.BaseURL),
+	}, config.Options...)
+	oc := openai.NewClient(options...)
+	return &Client{
+		oc:     &oc,
+		config: config,
+	}
+}
+
+// Client implements llm.Provider over chat completions
+type Client struct {
+	oc     *openai.Client
+	config *Config
+}
+
+var _ llm.Provider = (*Client)(nil)
+
+func (c *Client) Name() string {
+	return c.config.Name
+}
+
+func (c *Client) meta(id string) *llm.ModelMeta {
+	if c.config.Meta == nil {
+		return nil
+	}
+	return c.config.Meta(id)
+}
+
+// DefaultModel returns the configured default model
+func (c *Client) DefaultModel() string {
+	return c.config.DefaultModel
+}
+
+// Model retrieves a specific model
+func (c *Client) Model(ctx context.Context, id string) (*llm.Model, error) {
+	m, err := c.oc.Models.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: getting model %q: %w", c.config.Name, id, err)
+	}
+	return &llm.Model{
+		Provider: c.config.Name,
+		ID:       m.ID,
+		Meta:     c.meta(m.ID),
+	}, nil
+}
+
+// Models lists available models
+func (c *Client) Models(ctx context.Context) ([]*llm.Model, error) {
+	page, err := c.oc.Models.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: listing models: %w", c.config.Name, err)
+	}
+	var models []*llm.Model
+	for _, m := range page.Data {
+		models = append(models, &llm.Model{
+			Provider: c.config.Name,
+			ID:       m.ID,
+			Meta:     c.meta(m.ID),
+		})
+	}
+	return models, nil
+}
+
+func toUsage(usage openai.CompletionUsage) *llm.Usage {
+	if usage.TotalTokens == 0 && usage.PromptTokens == 0 && usage.CompletionTokens == 0 {
+		return nil
+	}
+	return &llm.Usage{
+		InputTokens:       int(usage.PromptTokens),
+		OutputTokens:      int(usage.CompletionTokens),
+		TotalTokens:       int(usage.TotalTokens),
+		CachedInputTokens: int(usage.PromptTokensDetails.CachedTokens),
+		ReasoningTokens:   int(usage.CompletionTokensDetails.ReasoningTokens),
+		CacheReadTokens:   int(usage.PromptTokensDetails.CachedTokens),
+	}
+}
+
+func toSchema(prop *llm.ToolProperty) map[string]any {
+	p := map[string]any{
+		"type":        prop.Type,
+		"description": prop.Description,
+	}
+	if len(prop.Enum) > 0 {
+		p["enum"] = prop.Enum
+	}
+	if prop.Items != nil {
+		p["items"] = toSchema(prop.Items)
+	}
+	if len(prop.Properties) > 0 {
+		props := make(map[string]any)
+		for name, child := range prop.Properties {
+			props[name] = toSchema(child)
+		}
+		p["properties"] = props
+		p["required"] = prop.Required
+	}
+	if prop.AdditionalProperties != nil {
+		p["additionalProperties"] = toSchema(prop.AdditionalProperties)
+	}
+	if prop.Minimum != nil {
+		p["minimum"] = *prop.Minimum
+	}
+	if prop.Maximum != nil {
+		p["maximum"] = *prop.Maximum
+	}
+	if prop.MinLength != nil {
+		p["minLength"] = *prop.MinLength
+	}
+	if prop.MaxLength != nil {
+		p["maxLength"] = *prop.MaxLength
+	}
+	if prop.Pattern != "" {
+		p["pattern"] = prop.Pattern
+	}
+	if prop.Default != nil {
+		p["default"] = prop.Default
+	}
+	return p
+}
+
+func toParameters(params *llm.ToolFunctionParameters) map[string]any {
+	props := make(map[string]any)
+	for name, prop := range params.Properties {
+		props[name] = toSchema(prop)
+	}
+	return map[string]any{
+		"type":       params.Type,
+		"properties": props,
+		"required":   params.Required,
+	}
+}
+
+// toContentParts converts a user message's content and parts into content
+// parts, sending inline images as data URLs
+func toContentParts(m *llm.Message) (parts []openai.ChatCompletionContentPartUnionParam) {
+	if m.Content != "" {
+		parts = append(parts, openai.TextContentPart(m.Content))
+	}
+	for _, part := range m.Parts {
+		switch part.Type {
+		case "text":
+			parts = append(parts, openai.TextContentPart(part.Text))
+		case "image":
+			url := part.URL
+			if url == "" {
+				url = "data:" + part.MediaType + ";base64," + base64.StdEncoding.EncodeToString(part.Data)
+			}
+			parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
+				URL: url,
+			}))
+		}
+	}
+	return parts
+}
+
+// toMessages converts messages into chat completion messages. Each tool call
+// is its own assistant message in the history, but chat completions expects
+// the calls of a step in one assistant message, so consecutive assistant
+// messages are merged. Tool messages can only be text, so images from tool
+// results follow the step's tool messages in a user message.
+func toMessages(messages []*llm.Message) (params []openai.ChatCompletionMessageParamUnion) {
+	var assistant *openai.ChatCompletionAssistantMessageParam
+	images := &llm.Message{Role: "user"}
+	for _, m := range messages {
+		if m.Role != "assistant" {
+			assistant = nil
+		}
+		if m.Role != "tool" && len(images.Parts) > 0 {
+			params = append(params, openai.UserMessage(toContentParts(images)))
+			images.Parts = nil
+		}
+		switch m.Role {
+		case "system":
+			params = append(params, openai.SystemMessage(m.Content))
+		case "user":
+			if len(m.Parts) > 0 {
+				params = append(params, openai.UserMessage(toContentParts(m)))
+				continue
+			}
+			params = append(params, openai.UserMessage(m.Content))
+		case "assistant":
+			if m.Content == "" && m.ToolCall == nil {
+				continue
+			}
+			if assistant == nil {
+				params = append(params, openai.ChatCompletionMessageParamUnion{
+					OfAssistant: &openai.ChatCompletionAssistantMessageParam{},
+				})
+				assistant = params[len(params)-1].OfAssistant
+			}
+			if m.Content != "" {
+				content := assistant.Content.OfString.Value + m.Content
+				assistant.Content.OfString = param.NewOpt(content)
+			}
+			if m.ToolCall != nil {
+				arguments := string(m.ToolCall.Arguments)
+				if arguments == "" {
+					arguments = "{}"
+				}
+				assistant.ToolCalls = append(assistant.ToolCalls, openai.ChatCompletionMessageToolCallParam{
+					ID: m.ToolCall.ID,
+					Function: openai.ChatCompletionMessageToolCallFunctionParam{
+						Name:      m.ToolCall.Name,
+						Arguments: arguments,
+					},
+				})
+			}
+		case "tool":
+			params = append(params, openai.ToolMessage(m.Content, m.ToolCallID))
+			images.Parts = append(images.Parts, m.Parts...)
+		}
+	}
+	if len(images.Parts) > 0 {
+		params = append(params, openai.UserMessage(toContentParts(images)))
+	}
+	return params
+}
+
+// toParams converts a chat request into chat completion params
+func (c *Client) toParams(req *llm.ChatRequest) openai.ChatCompletionNewParams {
+	params := openai.ChatCompletionNewParams{
+		Model:    shared.ChatModel(req.Model),
+		Messages: toMessages(req.Messages),
+	}
+
+	for _, t := range req.Tools {
+		params.Tools = append(params.Tools, openai.ChatCompletionToolParam{
+			Function: shared.FunctionDefinitionParam{
+				Name:        t.Function.Name,
+				Description: openai.String(t.Function.Description),
+				Parameters:  toParameters(t.Function.Parameters),
+			},
+		})
+	}
+
+	switch req.ToolChoice.Mode {
+	case "auto", "none", "required":
+		params.ToolChoice.OfAuto = openai.String(req.ToolChoice.Mode)
+	case "tool":
+		params.ToolChoice.OfChatCompletionNamedToolChoice = &openai.ChatCompletionNamedToolChoiceParam{
+			Function: openai.ChatCompletionNamedToolChoiceFunctionParam{Name: req.ToolChoice.Name},
+		}
+	}
+
+	if len(req.StopSequences) > 0 {
+		params.Stop.OfStringArray = req.StopSequences
+	}
+
+	if req.MaxOutputTokens > 0 {
+		params.MaxCompletionTokens = openai.Int(int64(c.meta(req.Model).ClampOutputTokens(req.MaxOutputTokens)))
+	}
+
+	if req.EndUser != "" {
+		params.User = openai.String(req.EndUser)
+	}
+
+	// Constrain the output to the response schema
+	if req.ResponseSchema != nil {
+		params.ResponseFormat.OfJSONSchema = &shared.ResponseFormatJSONSchemaParam{
+			JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{
+				Name:   "response",
+				Schema: toParameters(req.ResponseSchema),
+			},
+		}
+	}
+
+	// Only reasoning models accept a reasoning effort
+	if meta := c.meta(req.Model); meta != nil && meta.HasReasoning && c.config.Reasoning == nil {
+		switch req.Thinking {
+		case llm.ThinkingLow:
+			params.ReasoningEffort = shared.ReasoningEffortLow
+		case llm.ThinkingMedium:
+			params.ReasoningEffort = shared.ReasoningEffortMedium
+		case llm.ThinkingHigh:
+			params.ReasoningEffort = shared.ReasoningEffortHigh
+		}
+	}
+
+	return params
+}
+
+// requestOptions returns the extra options for a chat request
+func (c *Client) requestOptions(req *llm.ChatRequest) (options []option.RequestOption) {
+	if c.config.Reasoning != nil {
+		if meta := c.meta(req.Model); meta != nil && meta.HasReasoning {
+			options = append(options, c.config.Reasoning(req.Thinking)...)
+		}
+	}
+	for key, value := range req.ProviderParams {
+		options = append(options, option.WithJSONSet(key, value))
+	}
+	return options
+}
+
+// reasoning returns the reasoning text of a delta or message. It isn't part
+// of the OpenAI API, so compatible APIs use different fields for it.
+func reasoning(fields map[string]json.RawMessage) string {
+	for _, key := range []string{"reasoning", "reasoning_content"} {
+		raw, ok := fields[key]
+		if !ok {
+			continue
+		}
+		var text string
+		if err := json.Unmarshal(raw, &text); err == nil && text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// extraFields returns the raw JSON of fields the SDK doesn't know about
+func extraFields[F interface{ Raw() string }](fields map[string]F) map[string]json.RawMessage {
+	raw := make(map[string]json.RawMessage, len(fields))
+	for key, field := range fields {
+		raw[key] = json.RawMessage(field.Raw())
+	}
+	return raw
+}
+
+// Chat sends a chat request to the chat completions API
+func (c *Client) Chat(ctx context.Context, req *llm.ChatRequest) iter.Seq2[*llm.ChatResponse, error] {
+	return func(yield func(*llm.ChatResponse, error) bool) {
+		if req.Model == "" {
+			yield(nil, fmt.Errorf("%s: required model is empty", c.config.Name))
+			return
+		}
+
+		params := c.toParams(req)
+		params.StreamOptions.IncludeUsage = openai.Bool(true)
+		options := c.requestOptions(req)
+
+		stream := c.oc.Chat.Completions.NewStreaming(ctx, params, options...)
+		defer stream.Close()
+
+		// Tool call deltas are keyed by their index in the response
+		calls := map[int64]*llm.ToolCall{}
+		flush := func() bool {
+			indexes := make([]int64, 0, len(calls))
+			for index := range calls {
+				indexes = append(indexes, index)
+			}
+			sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+			for _, index := range indexes {
+				call := calls[index]
+				delete(calls, index)
+				if len(call.Arguments) == 0 {
+					call.Arguments = json.RawMessage("{}")
+				}
+				if !yield(&llm.ChatResponse{Role: "assistant", ToolCall: call}, nil) {
+					return false
+				}
+			}
+			return true
+		}
+
+		established := false
+		var usage *llm.Usage
+		for stream.Next() {
+			chunk := stream.Current()
+			req.Raw(chunk)
+			if !established {
+				established = true
+				if !yield(&llm.ChatResponse{Role: "assistant", Start: true}, nil) {
+					return
+				}
+			}
+			if u := toUsage(chunk.Usage); u != nil {
+				usage = u
+			}
+			for _, choice := range chunk.Choices {
+				delta := choice.Delta
+				if thinking := reasoning(extraFields(delta.JSON.ExtraFields)); thinking != "" {
+					if !yield(&llm.ChatResponse{Role: "assistant", Thinking: thinking}, nil) {
+						return
+					}
+				}
+				if delta.Content != "" {
+					if !yield(&llm.ChatResponse{Role: "assistant", Content: delta.Content}, nil) {
+						return
+					}
+				}
+				for _, tc := range delta.ToolCalls {
+					call, ok := calls[tc.Index]
+					if !ok {
+						call = &llm.ToolCall{}
+						calls[tc.Index] = call
+					}
+					if tc.ID != "" {
+						call.ID = tc.ID
+					}
+					if tc.Function.Name != "" {
+						call.Name = tc.Function.Name
+					}
+					call.Arguments = append(call.Arguments, tc.Function.Arguments...)
+				}
+				if choice.FinishReason != "" && !flush() {
+					return
+				}
+			}
+		}
+
+		if err := stream.Err(); err != nil {
+			if !established && req.StreamFallback {
+				c.fallback(ctx, req, params, options, yield)
+				return
+			}
+			yield(nil, fmt.Errorf("%s: streaming: %w", c.config.Name, err))
+			return
+		}
+
+		// Some APIs end the stream without a finish reason
+		if !flush() {
+			return
+		}
+		yield(&llm.ChatResponse{Role: "assistant", Done: true, Usage: usage}, nil)
+	}
+}
+
+// fallback sends a non-streaming request and synthesizes the stream events
+func (c *Client) fallback(ctx context.Context, req *llm.ChatRequest, params openai.ChatCompletionNewParams, options []option.RequestOption, yield func(*llm.ChatResponse, error) bool) {
+	params.StreamOptions = openai.ChatCompletionStreamOptionsParam{}
+	res, err := c.oc.Chat.Completions.New(ctx, params, options...)
+	if err != nil {
+		yield(nil, fmt.Errorf("%s: non-streaming fallback: %w", c.config.Name, err))
+		return
+	}
+	req.Raw(res)
+	if !yield(&llm.ChatResponse{Role: "assistant", Start: true}, nil) {
+		return
+	}
+	for _, choice := range res.Choices {
+		message := choice.Message
+		if thinking := reasoning(extraFields(message.JSON.ExtraFields)); thinking != "" {
+			if !yield(&llm.ChatResponse{Role: "assistant", Thinking: thinking}, nil) {
+				return
+			}
+		}
+		if message.Content != "" {
+			if !yield(&llm.ChatResponse{Role: "assistant", Content: message.Content}, nil) {
+				return
+			}
+		}
+		for _, tc := range message.ToolCalls {
+			arguments := tc.Function.Arguments
+			if arguments == "" {
+				arguments = "{}"
+			}
+			if !yield(&llm.ChatResponse{
+				Role: "assistant",
+				ToolCall: &llm.ToolCall{
+					ID:        tc.ID,
+					Name:      tc.Function.Name,
+					Arguments: json.RawMessage(arguments),
+				},
+			}, nil) {
+				return
+			}
+		}
+	}
+	yield(&llm.ChatResponse{
+		Role:  "assistant",
+		Done:  true,
+		Usage: toUsage(res.Usage),
+	}, nil)
+}
@@ -0,0 +1,153 @@
+package openaicompat_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+	"github.com/matthewmueller/llm/internal/openaicompat"
+)
+
+func TestChatStream(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.URL.Path, "/v1/chat/completions")
+		is.Equal(r.Header.Get("Authorization"), "Bearer secret")
+		var req struct {
+			Messages []json.RawMessage `json:"messages"`
+			Stream   bool              `json:"stream"`
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&req))
+		is.True(req.Stream)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"choices":[{"index":0,"delta":{"role":"assistant","reasoning":"Adding"}}]}`,
+			`{"choices":[{"index":0,"delta":{"content":"Let me add."}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"add","arguments":"{\"a\":"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"add","arguments":"{}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"1}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider := openaicompat.New(&openaicompat.Config{
+		Name:    "compat",
+		BaseURL: server.URL + "/v1",
+		APIKey:  "secret",
+	})
+	var thinking, content strings.Builder
+	var calls []*llm.ToolCall
+	var last *llm.ChatResponse
+	for res, err := range provider.Chat(ctx, &llm.ChatRequest{
+		Model:    "test",
+		Messages: []*llm.Message{llm.UserMessage("Add 1")},
+	}) {
+		is.NoErr(err)
+		thinking.WriteString(res.Thinking)
+		content.WriteString(res.Content)
+		if res.ToolCall != nil {
+			calls = append(calls, res.ToolCall)
+		}
+		last = res
+	}
+	is.Equal(thinking.String(), "Adding")
+	is.Equal(content.String(), "Let me add.")
+	is.Equal(len(calls), 2)
+	is.Equal(calls[0].ID, "call_1")
+	is.Equal(string(calls[0].Arguments), `{"a":1}`)
+	is.Equal(calls[1].ID, "call_2")
+	is.True(last.Done)
+	is.Equal(last.Usage.TotalTokens, 15)
+}
+
+func TestChatMergesToolCalls(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	var messages []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []map[string]any `json:"messages"`
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&req))
+		messages = req.Messages
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"3\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider := openaicompat.New(&openaicompat.Config{
+		Name:    "compat",
+		BaseURL: server.URL,
+	})
+	for _, err := range provider.Chat(ctx, &llm.ChatRequest{
+		Model: "test",
+		Messages: []*llm.Message{
+			llm.UserMessage("Add 1 and 2 twice"),
+			{Role: "assistant", ToolCall: &llm.ToolCall{ID: "call_1", Name: "add", Arguments: []byte(`{"a":1,"b":2}`)}},
+			{Role: "assistant", ToolCall: &llm.ToolCall{ID: "call_2", Name: "add", Arguments: []byte(`{"a":1,"b":2}`)}},
+			{Role: "tool", ToolCallID: "call_1", Content: "3"},
+			{Role: "tool", ToolCallID: "call_2", Content: "3"},
+		},
+	}) {
+		is.NoErr(err)
+	}
+	is.Equal(len(messages), 4)
+	is.Equal(messages[1]["role"], "assistant")
+	is.Equal(len(messages[1]["tool_calls"].([]any)), 2)
+	is.Equal(messages[2]["tool_call_id"], "call_1")
+	is.Equal(messages[3]["tool_call_id"], "call_2")
+}
+
+func TestChatToolResultImages(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	var messages []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []map[string]any `json:"messages"`
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&req))
+		messages = req.Messages
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"A dot\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider := openaicompat.New(&openaicompat.Config{
+		Name:    "compat",
+		BaseURL: server.URL,
+	})
+	for _, err := range provider.Chat(ctx, &llm.ChatRequest{
+		Model: "test",
+		Messages: []*llm.Message{
+			llm.UserMessage("What's in dot.png?"),
+			{Role: "assistant", ToolCall: &llm.ToolCall{ID: "call_1", Name: "read", Arguments: []byte(`{"path":"dot.png"}`)}},
+			{Role: "tool", ToolCallID: "call_1", Content: `{"path":"dot.png"}`, Parts: []*llm.ContentPart{llm.ImagePart("image/png", []byte("png"))}},
+		},
+	}) {
+		is.NoErr(err)
+	}
+	is.Equal(len(messages), 4)
+	is.Equal(messages[2]["content"], `{"path":"dot.png"}`)
+	is.Equal(messages[3]["role"], "user")
+	content := messages[3]["content"].([]any)
+	is.Equal(len(content), 1)
+	is.Equal(content[0].(map[string]any)["image_url"].(map[string]any)["url"], "data:image/png;base64,cG5n")
+}
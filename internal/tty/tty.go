@@ -0,0 +1,64 @@
+package tty
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+)
+
+// drainTimeout is how long to wait for the rest of the output after the
+// command exits
+const drainTimeout = 250 * time.Millisecond
+
+// Run the command attached to a new pseudo-terminal with the given size. A
+// zero size leaves the terminal's default size. Stdout and stderr share the
+// terminal, so all output is written to stdout.
+func Run(cmd *exec.Cmd, stdin io.Reader, stdout io.Writer, rows, cols uint16) error {
+	var size *pty.Winsize
+	if rows > 0 || cols > 0 {
+		size = &pty.Winsize{Rows: rows, Cols: cols}
+	}
+	term, err := pty.StartWithSize(cmd, size)
+	if err != nil {
+		return err
+	}
+	defer term.Close()
+
+	// Writing stdin stops once the terminal is closed, after the command exits
+	if stdin != nil {
+		go io.Copy(term, stdin)
+	}
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	copied := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(stdout, term)
+		copied <- err
+	}()
+
+	waitErr := cmd.Wait()
+
+	// Reading the terminal fails with EIO once the command has exited and the
+	// remaining output has been drained. Processes left running in the
+	// background can keep the terminal open, so stop reading after a moment.
+	var copyErr error
+	select {
+	case copyErr = <-copied:
+	case <-time.After(drainTimeout):
+		term.Close()
+		copyErr = <-copied
+	}
+	if waitErr != nil {
+		return waitErr
+	}
+	if copyErr != nil && !errors.Is(copyErr, syscall.EIO) && !errors.Is(copyErr, os.ErrClosed) {
+		return copyErr
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package llm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestBackoff(t *testing.T) {
+	is := is.New(t)
+	base := 100 * time.Millisecond
+	for attempt := 1; attempt <= 70; attempt++ {
+		// The delay doubles until it reaches the cap
+		limit := maxBackoff
+		if attempt <= 10 {
+			limit = min(base<<(attempt-1), maxBackoff)
+		}
+		low := false
+		for range 200 {
+			delay := backoff(base, attempt)
+			is.True(delay >= 0)
+			is.True(delay <= limit)
+			low = low || delay < limit/2
+		}
+		// Full jitter goes below half of the delay
+		is.True(low)
+	}
+	is.Equal(backoff(0, 3), time.Duration(0))
+}
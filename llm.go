@@ -1,12 +1,16 @@
 package llm
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/json"
 	"fmt"
+	"io"
 	"iter"
 	"log/slog"
-	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/matthewmueller/llm/internal/batch"
@@ -15,11 +19,23 @@ import (
 
 // Message represents a chat message
 type Message struct {
+	ID         string    `json:"id,omitzero"` // Set on messages recorded by an agent
 	Role       string    `json:"role,omitzero"`
 	Content    string    `json:"content,omitzero"`
 	Thinking   string    `json:"thinking,omitzero"`     // For chain-of-thought / thinking content
 	ToolCall   *ToolCall `json:"tool_call,omitzero"`    // For assistant messages that invoke a tool
 	ToolCallID string    `json:"tool_call_id,omitzero"` // For tool results, the ID of the tool call being responded to
+	// Multimodal content sent after Content, for user messages and tool results
+	Parts []*ContentPart `json:"parts,omitzero"`
+}
+
+// ContentPart is a piece of multimodal message content
+type ContentPart struct {
+	Type      string `json:"type,omitzero"`       // "text" or "image"
+	Text      string `json:"text,omitzero"`       // For text parts
+	MediaType string `json:"media_type,omitzero"` // For images, e.g. "image/png"
+	Data      []byte `json:"data,omitzero"`       // Inline image data
+	URL       string `json:"url,omitzero"`        // Image URL, instead of inline data
 }
 
 // Model represents an available model
@@ -31,11 +47,40 @@ type Model struct {
 
 // Manually curated information about the model
 type ModelMeta struct {
-	DisplayName     string    // Human-friendly name for the model (if available)
-	KnowledgeCutoff time.Time // Zero time if unknown
-	ContextWindow   int       // Maximum context window in tokens
-	MaxOutputTokens int       // Maximum output tokens (if known)
-	HasReasoning    bool      // Whether the model supports chain-of-thought / reasoning
+	DisplayName       string    // Human-friendly name for the model (if available)
+	KnowledgeCutoff   time.Time // Zero time if unknown
+	ContextWindow     int       // Maximum context window in tokens
+	MaxOutputTokens   int       // Maximum output tokens (if known)
+	HasReasoning      bool      // Whether the model supports chain-of-thought / reasoning
+	InputCostPerMTok  float64   // Dollars per million input tokens (zero if unknown or free)
+	OutputCostPerMTok float64   // Dollars per million output tokens (zero if unknown or free)
+	Default           bool      // Recommended for requests that don't set a model
+}
+
+// AsDefault marks the model as the provider's recommended model
+func (m *ModelMeta) AsDefault() *ModelMeta {
+	m.Default = true
+	return m
+}
+
+// DefaultModelIn returns the model marked as the default in a provider's
+// metadata, or an empty string when there isn't one
+func DefaultModelIn(meta map[string]*ModelMeta) (id string) {
+	for model, m := range meta {
+		if m.Default && (id == "" || model < id) {
+			id = model
+		}
+	}
+	return id
+}
+
+// ClampOutputTokens limits the requested output tokens to the model's maximum,
+// when it's known
+func (m *ModelMeta) ClampOutputTokens(n int) int {
+	if m == nil || m.MaxOutputTokens <= 0 || n <= m.MaxOutputTokens {
+		return n
+	}
+	return m.MaxOutputTokens
 }
 
 type ChatRequest struct {
@@ -43,6 +88,47 @@ type ChatRequest struct {
 	Thinking Thinking
 	Tools    []*ToolSchema
 	Messages []*Message
+	// StreamFallback retries with a non-streaming request when the stream
+	// fails to establish
+	StreamFallback bool
+	// ResponseSchema constrains the response to JSON matching the schema
+	ResponseSchema *ToolFunctionParameters
+	// StopSequences end the response when the model generates one of them
+	StopSequences []string
+	// MaxOutputTokens limits the length of the response. Zero uses the
+	// provider's default.
+	MaxOutputTokens int
+	// ToolChoice controls whether the model calls tools. The zero value uses
+	// the provider's default.
+	ToolChoice ToolChoice
+	// ProviderParams are extra parameters the provider merges into its
+	// request, for features without a typed option
+	ProviderParams map[string]any
+	// EndUser identifies the app's end user to providers that use it for
+	// abuse monitoring
+	EndUser string
+	// RawEvents is called with each of the provider's native events before
+	// they're translated. Providers call it through Raw.
+	RawEvents func(event any)
+	// IDGenerator generates IDs for tool calls the provider sends without
+	// one. Providers call it through NewID.
+	IDGenerator func() string
+}
+
+// Raw passes the provider's native event to RawEvents when it's set
+func (r *ChatRequest) Raw(event any) {
+	if r.RawEvents != nil {
+		r.RawEvents(event)
+	}
+}
+
+// NewID generates an ID for a tool call the provider sent without one, using
+// IDGenerator when it's set
+func (r *ChatRequest) NewID() string {
+	if r.IDGenerator != nil {
+		return r.IDGenerator()
+	}
+	return rand.Text()
 }
 
 // Provider interface
@@ -53,6 +139,29 @@ type Provider interface {
 	Chat(ctx context.Context, req *ChatRequest) iter.Seq2[*ChatResponse, error]
 }
 
+// DefaultModeler is implemented by providers that recommend a model for
+// requests that don't set one
+type DefaultModeler interface {
+	DefaultModel() string
+}
+
+// TokenCounter is implemented by providers that can count the input tokens
+// of a request without sending it
+type TokenCounter interface {
+	CountTokens(ctx context.Context, req *ChatRequest) (int, error)
+}
+
+// Warmer is implemented by providers that can load a model ahead of the
+// first request, like local models that are slow to load
+type Warmer interface {
+	Warmup(ctx context.Context, model string) error
+}
+
+// Embedder is implemented by providers that can embed text as vectors
+type Embedder interface {
+	Embed(ctx context.Context, model string, inputs []string) ([][]float32, error)
+}
+
 // ChatResponse represents a streaming response from the chat API
 type ChatResponse struct {
 	Role       string    `json:"role,omitzero"`
@@ -61,16 +170,26 @@ type ChatResponse struct {
 	ToolCall   *ToolCall `json:"tool_call,omitzero"`
 	ToolCallID string    `json:"tool_call_id,omitzero"` // For tool results, the ID of the tool call being responded to
 	Usage      *Usage    `json:"usage,omitzero"`        // Token usage metadata (if available)
+	Start      bool      `json:"start,omitzero"`        // True when the response has started, before the first token
+	Provider   string    `json:"provider,omitzero"`     // For start events, the provider that's responding
+	Model      string    `json:"model,omitzero"`        // For start events, the model that's responding
 	Done       bool      `json:"done,omitzero"`         // True when response is complete
+	StopReason string    `json:"stop_reason,omitzero"`  // Why a done response was cut short, like "max_output_tokens"
+	// For tool results, the images a media tool returned
+	Parts []*ContentPart `json:"parts,omitzero"`
 }
 
-// Usage represents token usage for a single model response.
+// Usage represents token usage for a single model response. The reasoning and
+// cache counts break down the input and output counts rather than adding to
+// them, so TotalTokens stays the sum of InputTokens and OutputTokens.
 type Usage struct {
 	InputTokens       int `json:"input_tokens,omitzero"`
 	OutputTokens      int `json:"output_tokens,omitzero"`
 	TotalTokens       int `json:"total_tokens,omitzero"`
-	CachedInputTokens int `json:"cached_input_tokens,omitzero"`
-	ReasoningTokens   int `json:"reasoning_tokens,omitzero"`
+	CachedInputTokens int `json:"cached_input_tokens,omitzero"` // Input tokens served from the prompt cache
+	ReasoningTokens   int `json:"reasoning_tokens,omitzero"`    // Output tokens spent thinking
+	CacheReadTokens   int `json:"cache_read_tokens,omitzero"`   // Input tokens read from the prompt cache
+	CacheWriteTokens  int `json:"cache_write_tokens,omitzero"`  // Input tokens written to the prompt cache
 }
 
 // Thinking represents the level of extended thinking/reasoning
@@ -83,6 +202,28 @@ const (
 	ThinkingHigh   Thinking = "high"   // High thinking budget
 )
 
+// ToolChoice controls whether the model calls tools
+type ToolChoice struct {
+	Mode string // "auto", "none", "required" or "tool"
+	Name string // Tool to call when the mode is "tool"
+}
+
+var (
+	ToolChoiceAuto     = ToolChoice{Mode: "auto"}     // Model decides whether to call tools
+	ToolChoiceNone     = ToolChoice{Mode: "none"}     // Model doesn't call tools
+	ToolChoiceRequired = ToolChoice{Mode: "required"} // Model calls at least one tool
+)
+
+// ToolChoiceTool forces the model to call the named tool
+func ToolChoiceTool(name string) ToolChoice {
+	return ToolChoice{Mode: "tool", Name: name}
+}
+
+// Forced reports whether the choice forces the model to call a tool
+func (t ToolChoice) Forced() bool {
+	return t.Mode == "required" || t.Mode == "tool"
+}
+
 type Option func(*Config)
 
 type Config struct {
@@ -93,6 +234,48 @@ type Config struct {
 	Tools    []Tool
 	Messages []*Message
 	MaxSteps int
+	// Model to use when none is set, instead of the provider's default
+	DefaultModel string
+	// Retry without streaming if the stream fails to establish
+	StreamFallback bool
+	// Schema the response must conform to
+	ResponseSchema *ToolFunctionParameters
+	// Fail fast after repeated provider failures
+	CircuitBreaker *CircuitBreaker
+	// Retry transient provider failures
+	Retry *Retry
+	// Providers to try in order when the primary provider fails
+	Fallbacks []string
+	// Trim the agent's history to fit the context window
+	Truncation *Truncation
+	// Sequences that end the response
+	StopSequences []string
+	// Maximum tokens in each response
+	MaxOutputTokens int
+	// Whether the model calls tools
+	ToolChoice ToolChoice
+	// Where the agent writes its transcript
+	Transcript io.Writer
+	// Generates IDs for tool calls and messages
+	IDGenerator func() string
+	// Extra request parameters for each provider by name
+	ProviderParams map[string]map[string]any
+	// Identifies the app's end user to the provider
+	EndUser string
+	// The user's time zone
+	TimeZone *time.Location
+	// Times to re-request a step that comes back empty
+	RetryOnEmpty int
+	// Called with each provider's native events
+	RawEvents func(provider string, event any)
+	// Summarize the agent's older turns
+	Compaction *Compaction
+	// Stores tool results that are too large to send
+	ResultStore *ResultStore
+	// Maximum turns an agent runs for on its own
+	MaxTurns int
+	// Tools for specific models, replacing Tools
+	ModelTools map[string][]Tool
 }
 
 // WithModel sets the model for the agent
@@ -102,6 +285,14 @@ func WithModel(model string) Option {
 	}
 }
 
+// WithDefaultModel sets the model to use when WithModel isn't set, overriding
+// the provider's default model
+func WithDefaultModel(model string) Option {
+	return func(c *Config) {
+		c.DefaultModel = model
+	}
+}
+
 // WithThinking sets the extended thinking level.
 // Supported values: ThinkingLow, ThinkingMedium, ThinkingHigh.
 // Default is ThinkingMedium if not specified.
@@ -118,6 +309,26 @@ func WithTool(tools ...Tool) Option {
 	}
 }
 
+// WithModelTools sets the tools for a specific model, replacing the tools
+// added with WithTool when that model is active. This lets a cheaper model
+// have read-only tools while a more capable model can make changes.
+func WithModelTools(model string, tools ...Tool) Option {
+	return func(c *Config) {
+		if c.ModelTools == nil {
+			c.ModelTools = map[string][]Tool{}
+		}
+		c.ModelTools[model] = append(c.ModelTools[model], tools...)
+	}
+}
+
+// tools returns the tools for the active model
+func (c *Config) tools() []Tool {
+	if tools, ok := c.ModelTools[c.Model]; ok {
+		return tools
+	}
+	return c.Tools
+}
+
 // WithMessages sets initial conversation history
 func WithMessage(messages ...*Message) Option {
 	return func(c *Config) {
@@ -132,6 +343,69 @@ func WithMaxSteps(max int) Option {
 	}
 }
 
+// WithStreamFallback retries with a non-streaming request when a provider's
+// stream fails to establish (e.g. behind proxies that block streaming). Errors
+// that happen mid-stream are still returned.
+func WithStreamFallback(fallback bool) Option {
+	return func(c *Config) {
+		c.StreamFallback = fallback
+	}
+}
+
+// WithResponseSchema constrains the model to respond with JSON matching the
+// schema generated from v, which should be a struct. Schemas are generated
+// with the same struct tags as tools.
+func WithResponseSchema(v any) Option {
+	return func(c *Config) {
+		c.ResponseSchema = generateSchema(v)
+	}
+}
+
+// WithStopSequences ends the response when the model generates any of the
+// sequences. The stop sequence isn't included in the response.
+func WithStopSequences(seqs ...string) Option {
+	return func(c *Config) {
+		c.StopSequences = append(c.StopSequences, seqs...)
+	}
+}
+
+// WithMaxOutputTokens limits the number of tokens in each response. Limits
+// above the model's maximum output tokens are lowered to the maximum.
+func WithMaxOutputTokens(n int) Option {
+	return func(c *Config) {
+		c.MaxOutputTokens = n
+	}
+}
+
+// WithToolChoice controls whether the model calls tools. Choices that force a
+// tool call only apply to the first step of a turn, otherwise the model would
+// keep calling tools until it runs out of steps.
+func WithToolChoice(choice ToolChoice) Option {
+	return func(c *Config) {
+		c.ToolChoice = choice
+	}
+}
+
+// WithEndUser identifies the end user of your app to the provider, which some
+// providers use to detect abuse and require for user-facing apps. Use an
+// opaque ID like a hash rather than a name or email. It's sent as OpenAI's
+// safety_identifier, Anthropic's metadata.user_id and the user field of
+// OpenAI-compatible APIs. Other providers ignore it.
+func WithEndUser(id string) Option {
+	return func(c *Config) {
+		c.EndUser = id
+	}
+}
+
+// WithTranscript writes each message an agent adds to its history to w as a
+// line of JSON once the message is final. The final assistant message of each
+// turn includes the turn's usage.
+func WithTranscript(w io.Writer) Option {
+	return func(c *Config) {
+		c.Transcript = w
+	}
+}
+
 // SystemMessage creates a system message
 func SystemMessage(content string) *Message {
 	return &Message{
@@ -148,6 +422,32 @@ func UserMessage(content string) *Message {
 	}
 }
 
+// TextPart creates a text content part
+func TextPart(text string) *ContentPart {
+	return &ContentPart{
+		Type: "text",
+		Text: text,
+	}
+}
+
+// ImagePart creates an image content part from inline data
+func ImagePart(mediaType string, data []byte) *ContentPart {
+	return &ContentPart{
+		Type:      "image",
+		MediaType: mediaType,
+		Data:      data,
+	}
+}
+
+// ImageURLPart creates an image content part from a URL
+func ImageURLPart(mediaType, url string) *ContentPart {
+	return &ContentPart{
+		Type:      "image",
+		MediaType: mediaType,
+		URL:       url,
+	}
+}
+
 // AssistantMessage creates an assistant message
 func AssistantMessage(content string) *Message {
 	return &Message{
@@ -160,11 +460,14 @@ func AssistantMessage(content string) *Message {
 type Client struct {
 	// log       *slog.Logger
 	providers []Provider
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
 }
 
 // New creates a new Client
 func New(providers ...Provider) *Client {
-	return &Client{providers}
+	return &Client{providers: providers}
 }
 
 func (c *Client) findProvider(name string) (Provider, error) {
@@ -179,6 +482,10 @@ func (c *Client) findProvider(name string) (Provider, error) {
 // Chat sends a chat request to the appropriate provider
 func (c *Client) Chat(ctx context.Context, provider string, options ...Option) iter.Seq2[*ChatResponse, error] {
 	return func(yield func(*ChatResponse, error) bool) {
+		// Cancel in-flight requests and tools when the caller stops iterating
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
 		config := &Config{
 			Thinking: ThinkingMedium,
 		}
@@ -186,10 +493,22 @@ func (c *Client) Chat(ctx context.Context, provider string, options ...Option) i
 			option(config)
 		}
 
-		provider, err := c.findProvider(provider)
-		if err != nil {
-			yield(nil, err)
-			return
+		// The primary provider followed by any fallbacks
+		var providers []Provider
+		for _, name := range append([]string{provider}, config.Fallbacks...) {
+			provider, err := c.findProvider(name)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			providers = append(providers, provider)
+		}
+
+		setDefaultModel(config, providers[0])
+		config.Tools = config.tools()
+
+		if config.MaxOutputTokens > 0 {
+			config.MaxOutputTokens = clampOutputTokens(ctx, providers[0], config)
 		}
 
 		toolbox := map[string]Tool{}
@@ -200,6 +519,10 @@ func (c *Client) Chat(ctx context.Context, provider string, options ...Option) i
 
 		// Maintain internal state for this turn
 		messages := append([]*Message{}, config.Messages...)
+		if config.TimeZone != nil {
+			ctx = withTimeZone(ctx, config.TimeZone)
+			messages = timeMessage(messages, config.TimeZone, time.Now())
+		}
 
 	turn:
 		for steps := 0; steps < config.MaxSteps || config.MaxSteps == 0; steps++ {
@@ -208,12 +531,36 @@ func (c *Client) Chat(ctx context.Context, provider string, options ...Option) i
 				Thinking: config.Thinking,
 				Tools:    toolSchemas(config.Tools),
 				Messages: messages,
+
+				StreamFallback: config.StreamFallback,
+				ResponseSchema: config.ResponseSchema,
+				StopSequences:  config.StopSequences,
+
+				MaxOutputTokens: config.MaxOutputTokens,
+				ToolChoice:      config.ToolChoice,
+				EndUser:         config.EndUser,
+				IDGenerator:     config.newID,
+			}
+			if steps > 0 && req.ToolChoice.Forced() {
+				req.ToolChoice = ToolChoiceAuto
 			}
 
 			batch, ctx := batch.New[*Message](ctx)
+			seen := map[string]bool{}
 
 			// Make a request to the LLM and stream back the response
-			for res, err := range provider.Chat(ctx, req) {
+			stream := retryEmpty(config.RetryOnEmpty, func() iter.Seq2[*ChatResponse, error] {
+				return fallback(ctx, providers, func(provider Provider) iter.Seq2[*ChatResponse, error] {
+					return started(provider.Name(), req.Model, c.circuit(provider, config.CircuitBreaker, func() iter.Seq2[*ChatResponse, error] {
+						return retry(ctx, config.Retry, func() iter.Seq2[*ChatResponse, error] {
+							return withTimeout(ctx, requestTimeout(provider, config.Thinking), func(ctx context.Context) iter.Seq2[*ChatResponse, error] {
+								return provider.Chat(ctx, withRawEvents(withProviderParams(req, config.ProviderParams[provider.Name()]), provider.Name(), config.RawEvents))
+							})
+						})
+					}))
+				})
+			})
+			for res, err := range stream {
 				if err != nil {
 					if !yield(res, err) {
 						break turn
@@ -221,6 +568,26 @@ func (c *Client) Chat(ctx context.Context, provider string, options ...Option) i
 					continue
 				}
 
+				// Pass the start event through without saving it
+				if res.Start {
+					if !yield(res, nil) {
+						break turn
+					}
+					continue
+				}
+
+				// Guard against providers emitting the same tool call twice
+				if res.ToolCall != nil {
+					key := toolCallKey(res.ToolCall)
+					if seen[key] {
+						continue
+					}
+					seen[key] = true
+					if res.ToolCall.ID == "" {
+						res.ToolCall.ID = config.newID()
+					}
+				}
+
 				// Save the message for this turn
 				messages = append(messages, &Message{
 					Role:     res.Role,
@@ -244,9 +611,16 @@ func (c *Client) Chat(ctx context.Context, provider string, options ...Option) i
 						break turn
 					}
 
+					// Stored results get their own ref, since some providers reuse
+					// tool call IDs
+					var ref string
+					if config.ResultStore != nil {
+						ref = config.newID()
+					}
+
 					// Run tool in a goroutine
 					batch.Go(func() (*Message, error) {
-						result, err := tool.Run(ctx, res.ToolCall.Arguments)
+						content, images, err := runTool(ctx, tool, res.ToolCall.Arguments)
 						if err != nil {
 							// Return the error as a tool result message so the model can see
 							// it and potentially recover
@@ -256,16 +630,26 @@ func (c *Client) Chat(ctx context.Context, provider string, options ...Option) i
 								ToolCallID: res.ToolCall.ID,
 							}, nil
 						}
+						if config.ResultStore != nil {
+							content = config.ResultStore.shrink(ref, content)
+						}
 						return &Message{
 							Role:       "tool",
-							Content:    string(result),
+							Content:    content,
 							ToolCallID: res.ToolCall.ID,
+							Parts:      images,
 						}, nil
 					})
 				}
 
-				// Stop yielding further messages if we have tool calls to process
+				// Stop yielding further messages if we have tool calls to process,
+				// but pass the usage through so callers can keep track of it
 				if batch.Size() > 0 {
+					if res.Usage != nil && res.ToolCall == nil {
+						if !yield(&ChatResponse{Role: res.Role, Usage: res.Usage}, nil) {
+							break turn
+						}
+					}
 					continue
 				}
 
@@ -296,6 +680,7 @@ func (c *Client) Chat(ctx context.Context, provider string, options ...Option) i
 					Thinking:   message.Thinking,
 					Content:    message.Content,
 					ToolCallID: message.ToolCallID,
+					Parts:      message.Parts,
 				}, nil) {
 					break turn
 				}
@@ -304,6 +689,79 @@ func (c *Client) Chat(ctx context.Context, provider string, options ...Option) i
 	}
 }
 
+// setDefaultModel fills in the model when it isn't set
+func setDefaultModel(config *Config, provider Provider) {
+	if config.Model != "" {
+		return
+	}
+	if config.DefaultModel != "" {
+		config.Model = config.DefaultModel
+		return
+	}
+	if defaulter, ok := provider.(DefaultModeler); ok {
+		config.Model = defaulter.DefaultModel()
+	}
+}
+
+// clampOutputTokens lowers the requested output tokens to the model's maximum,
+// warning when the limit was too high. Models that can't be resolved are left
+// to the provider.
+func clampOutputTokens(ctx context.Context, provider Provider, config *Config) int {
+	if config.Model == "" {
+		return config.MaxOutputTokens
+	}
+	model, err := provider.Model(ctx, config.Model)
+	if err != nil {
+		return config.MaxOutputTokens
+	}
+	n := model.Meta.ClampOutputTokens(config.MaxOutputTokens)
+	if n < config.MaxOutputTokens {
+		log := config.Log
+		if log == nil {
+			log = slog.Default()
+		}
+		log.Warn("llm: max output tokens exceeds the model's limit",
+			"model", config.Model,
+			"requested", config.MaxOutputTokens,
+			"max", n,
+		)
+	}
+	return n
+}
+
+// started tags the start event with the provider and model that's responding,
+// which may be a fallback
+func started(provider, model string, chat iter.Seq2[*ChatResponse, error]) iter.Seq2[*ChatResponse, error] {
+	return func(yield func(*ChatResponse, error) bool) {
+		for res, err := range chat {
+			if err == nil && res.Start {
+				start := *res
+				start.Provider, start.Model = provider, model
+				res = &start
+			}
+			if !yield(res, err) {
+				return
+			}
+		}
+	}
+}
+
+// toolCallKey identifies a tool call within a turn by its ID, so a call that's
+// re-sent with reformatted arguments is still a duplicate. Calls without an
+// ID, or where the provider reuses the function name as the ID for distinct
+// calls (e.g. Gemini), are keyed on their name and arguments instead.
+func toolCallKey(call *ToolCall) string {
+	if call.ID != "" && call.ID != call.Name {
+		return call.ID
+	}
+	args := new(bytes.Buffer)
+	if err := json.Compact(args, call.Arguments); err != nil {
+		args.Reset()
+		args.Write(call.Arguments)
+	}
+	return call.ID + "\x00" + call.Name + "\x00" + args.String()
+}
+
 type ErrMultipleModels struct {
 	Provider string
 	Name     string
@@ -335,9 +793,11 @@ func filterProviders(all []Provider, providers ...string) (filtered []Provider)
 	return filtered
 }
 
-// Models returns a filtered list of available models
+// Models returns a filtered list of available models sorted by provider, then
+// model ID. Use SortModels for other orders.
 func (c *Client) Models(ctx context.Context, providers ...string) (models []*Model, err error) {
 	eg, ctx := errgroup.WithContext(ctx)
+	var mu sync.Mutex
 	for _, provider := range filterProviders(c.providers, providers...) {
 		eg.Go(func() error {
 			m, err := provider.Models(ctx)
@@ -345,19 +805,16 @@ func (c *Client) Models(ctx context.Context, providers ...string) (models []*Mod
 				return err
 			}
 			// TODO: dedupe
+			mu.Lock()
 			models = append(models, m...)
+			mu.Unlock()
 			return nil
 		})
 	}
 	if err := eg.Wait(); err != nil {
 		return nil, err
 	}
-	sort.Slice(models, func(i, j int) bool {
-		if models[i].Provider == models[j].Provider {
-			return models[i].ID < models[j].ID
-		}
-		return models[i].Provider < models[j].Provider
-	})
+	SortModels(models, SortByProvider, false)
 	return models, nil
 }
 
@@ -0,0 +1,97 @@
+package llm_test
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sync"
+
+	"github.com/matthewmueller/llm"
+)
+
+// fakeProvider replays a scripted list of responses, one turn per Chat call
+type fakeProvider struct {
+	name  string
+	turns [][]*llm.ChatResponse
+	fails []error // Errors to return before replaying turns
+	meta  *llm.ModelMeta
+	model string // Default model
+
+	mu       sync.Mutex
+	requests []*llm.ChatRequest
+}
+
+var _ llm.Provider = (*fakeProvider)(nil)
+
+func (p *fakeProvider) Name() string {
+	if p.name == "" {
+		return "fake"
+	}
+	return p.name
+}
+
+func (p *fakeProvider) DefaultModel() string {
+	return p.model
+}
+
+func (p *fakeProvider) Model(ctx context.Context, id string) (*llm.Model, error) {
+	return &llm.Model{Provider: p.Name(), ID: id, Meta: p.meta}, nil
+}
+
+func (p *fakeProvider) Models(ctx context.Context) ([]*llm.Model, error) {
+	return nil, nil
+}
+
+func (p *fakeProvider) Chat(ctx context.Context, req *llm.ChatRequest) iter.Seq2[*llm.ChatResponse, error] {
+	return func(yield func(*llm.ChatResponse, error) bool) {
+		p.mu.Lock()
+		p.requests = append(p.requests, req)
+		if len(p.fails) > 0 {
+			err := p.fails[0]
+			p.fails = p.fails[1:]
+			p.mu.Unlock()
+			yield(nil, err)
+			return
+		}
+		if len(p.turns) == 0 {
+			p.mu.Unlock()
+			yield(nil, fmt.Errorf("fake: no more turns"))
+			return
+		}
+		turn := p.turns[0]
+		p.turns = p.turns[1:]
+		p.mu.Unlock()
+		for _, res := range turn {
+			req.Raw(res)
+			if !yield(res, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Requests returns the requests the provider has received
+func (p *fakeProvider) Requests() []*llm.ChatRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*llm.ChatRequest{}, p.requests...)
+}
+
+func text(content string) *llm.ChatResponse {
+	return &llm.ChatResponse{Role: "assistant", Content: content}
+}
+
+func done() *llm.ChatResponse {
+	return &llm.ChatResponse{Role: "assistant", Done: true}
+}
+
+func toolCall(id, name, args string) *llm.ChatResponse {
+	return &llm.ChatResponse{
+		Role: "assistant",
+		ToolCall: &llm.ToolCall{
+			ID:        id,
+			Name:      name,
+			Arguments: []byte(args),
+		},
+	}
+}
@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ExportMarkdown renders a conversation as Markdown for sharing. Each turn gets
+// a role header, tool calls and results are fenced and thinking is collapsed
+// in a details section.
+func ExportMarkdown(messages []*Message) string {
+	b := new(strings.Builder)
+	role := ""
+	for _, message := range messages {
+		// Tool calls are separate messages, so keep a turn under one header
+		if message.Role != role || message.Role != "assistant" {
+			b.WriteString("## " + roleHeader(message) + "\n\n")
+			role = message.Role
+		}
+		if message.Thinking != "" {
+			b.WriteString("<details>\n<summary>Thinking</summary>\n\n")
+			b.WriteString(strings.TrimSpace(message.Thinking) + "\n\n")
+			b.WriteString("</details>\n\n")
+		}
+		switch {
+		case message.Role == "tool":
+			writeFenced(b, message.Content)
+		case message.Content != "":
+			b.WriteString(strings.TrimSpace(message.Content) + "\n\n")
+		}
+		for _, part := range message.Parts {
+			switch {
+			case part.Type == "text":
+				b.WriteString(strings.TrimSpace(part.Text) + "\n\n")
+			case part.URL != "":
+				fmt.Fprintf(b, "![%s](%s)\n\n", part.Type, part.URL)
+			default:
+				fmt.Fprintf(b, "*[%s: %s]*\n\n", part.Type, part.MediaType)
+			}
+		}
+		if message.ToolCall != nil {
+			fmt.Fprintf(b, "**Tool call:** `%s`", message.ToolCall.Name)
+			if message.ToolCall.ID != "" {
+				fmt.Fprintf(b, " (`%s`)", message.ToolCall.ID)
+			}
+			b.WriteString("\n\n")
+			if len(message.ToolCall.Arguments) > 0 {
+				writeFenced(b, string(message.ToolCall.Arguments))
+			}
+		}
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+func roleHeader(message *Message) string {
+	switch message.Role {
+	case "system":
+		return "System"
+	case "user":
+		return "User"
+	case "assistant":
+		return "Assistant"
+	case "tool":
+		if message.ToolCallID != "" {
+			return "Tool result (`" + message.ToolCallID + "`)"
+		}
+		return "Tool result"
+	default:
+		return message.Role
+	}
+}
+
+// writeFenced writes the content in a code block, pretty-printing JSON. The
+// fence is longer than any backtick run in the content so it can't be closed
+// early.
+func writeFenced(b *strings.Builder, content string) {
+	lang := ""
+	indented := new(bytes.Buffer)
+	if json.Indent(indented, []byte(content), "", "  ") == nil {
+		lang = "json"
+		content = indented.String()
+	}
+	fence := strings.Repeat("`", max(3, longestRun(content, '`')+1))
+	b.WriteString(fence + lang + "\n")
+	b.WriteString(strings.TrimRight(content, "\n") + "\n")
+	b.WriteString(fence + "\n\n")
+}
+
+// longestRun returns the length of the longest run of c in s
+func longestRun(s string, c byte) (longest int) {
+	run := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != c {
+			run = 0
+			continue
+		}
+		run++
+		longest = max(longest, run)
+	}
+	return longest
+}
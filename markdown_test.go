@@ -0,0 +1,38 @@
+package llm_test
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func TestExportMarkdown(t *testing.T) {
+	is := is.New(t)
+	markdown := llm.ExportMarkdown([]*llm.Message{
+		llm.SystemMessage("You are a calculator"),
+		llm.UserMessage("What is 20+22?"),
+		{Role: "assistant", Thinking: "I should add"},
+		{Role: "assistant", ToolCall: &llm.ToolCall{ID: "call_1", Name: "add", Arguments: []byte(`{"a":20,"b":22}`)}},
+		{Role: "tool", ToolCallID: "call_1", Content: "42"},
+		llm.AssistantMessage("The answer is 42"),
+		{Role: "user", Parts: []*llm.ContentPart{llm.ImageURLPart("image/png", "https://example.com/cat.png")}},
+		llm.AssistantMessage("Use ``` for code"),
+	})
+	is.Equal(markdown, "## System\n\n"+
+		"You are a calculator\n\n"+
+		"## User\n\n"+
+		"What is 20+22?\n\n"+
+		"## Assistant\n\n"+
+		"<details>\n<summary>Thinking</summary>\n\nI should add\n\n</details>\n\n"+
+		"**Tool call:** `add` (`call_1`)\n\n"+
+		"```json\n{\n  \"a\": 20,\n  \"b\": 22\n}\n```\n\n"+
+		"## Tool result (`call_1`)\n\n"+
+		"```json\n42\n```\n\n"+
+		"## Assistant\n\n"+
+		"The answer is 42\n\n"+
+		"## User\n\n"+
+		"![image](https://example.com/cat.png)\n\n"+
+		"## Assistant\n\n"+
+		"Use ``` for code\n")
+}
@@ -0,0 +1,53 @@
+package llm
+
+import (
+	"iter"
+	"sync"
+)
+
+// TaggedResponse is a response from one of the merged streams
+type TaggedResponse struct {
+	Source   int           // Index of the stream it came from
+	Response *ChatResponse // Nil when the stream failed
+}
+
+// Merge fans in several streams, like concurrently running agents, into one.
+// Each stream runs in its own goroutine and responses are tagged with the
+// index of the stream they came from, in the order they arrive. Errors are
+// tagged too. Stopping early stops each stream at its next response.
+func Merge(streams ...iter.Seq2[*ChatResponse, error]) iter.Seq2[*TaggedResponse, error] {
+	type tagged struct {
+		res *TaggedResponse
+		err error
+	}
+	return func(yield func(*TaggedResponse, error) bool) {
+		ch := make(chan tagged)
+		done := make(chan struct{})
+		defer close(done)
+
+		var wg sync.WaitGroup
+		for i, stream := range streams {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for res, err := range stream {
+					select {
+					case ch <- tagged{&TaggedResponse{Source: i, Response: res}, err}:
+					case <-done:
+						return
+					}
+				}
+			}()
+		}
+		go func() {
+			wg.Wait()
+			close(ch)
+		}()
+
+		for t := range ch {
+			if !yield(t.res, t.err) {
+				return
+			}
+		}
+	}
+}
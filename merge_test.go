@@ -0,0 +1,71 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func stream(responses ...*llm.ChatResponse) iter.Seq2[*llm.ChatResponse, error] {
+	return func(yield func(*llm.ChatResponse, error) bool) {
+		for _, res := range responses {
+			if !yield(res, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	client := llm.New(
+		&fakeProvider{name: "a", turns: [][]*llm.ChatResponse{{text("one"), done()}}},
+		&fakeProvider{name: "b", turns: [][]*llm.ChatResponse{{text("two"), done()}}},
+	)
+	content := map[int]string{}
+	for res, err := range llm.Merge(
+		client.Agent("a", llm.WithModel("m")).Chat(ctx, "hi"),
+		client.Agent("b", llm.WithModel("m")).Chat(ctx, "hi"),
+	) {
+		is.NoErr(err)
+		content[res.Source] += res.Response.Content
+	}
+	is.Equal(content, map[int]string{0: "one", 1: "two"})
+}
+
+func TestMergeError(t *testing.T) {
+	is := is.New(t)
+	failing := func(yield func(*llm.ChatResponse, error) bool) {
+		yield(nil, errors.New("boom"))
+	}
+	var sources []int
+	for res, err := range llm.Merge(stream(text("ok")), failing) {
+		if err != nil {
+			is.Equal(err.Error(), "boom")
+			is.Equal(res.Response, nil)
+		}
+		sources = append(sources, res.Source)
+	}
+	is.Equal(len(sources), 2)
+}
+
+func TestMergeStop(t *testing.T) {
+	is := is.New(t)
+	stopped := make(chan struct{})
+	endless := func(yield func(*llm.ChatResponse, error) bool) {
+		defer close(stopped)
+		for yield(text("again"), nil) {
+		}
+	}
+	for res, err := range llm.Merge(endless) {
+		is.NoErr(err)
+		is.Equal(res.Response.Content, "again")
+		break
+	}
+	<-stopped
+}
@@ -0,0 +1,62 @@
+package llm
+
+import (
+	"sort"
+	"time"
+)
+
+// ModelSort is the order to list models in
+type ModelSort string
+
+const (
+	SortByProvider ModelSort = "provider" // Provider, then model ID
+	SortByCutoff   ModelSort = "cutoff"   // Newest knowledge cutoff first
+	SortByContext  ModelSort = "context"  // Largest context window first
+)
+
+// SortModels sorts the models in place. Ties, and models without the metadata
+// to sort on, fall back to provider then model ID so the order is always the
+// same. With groupReasoning, reasoning models are listed before the rest.
+func SortModels(models []*Model, by ModelSort, groupReasoning bool) {
+	sort.SliceStable(models, func(i, j int) bool {
+		a, b := models[i], models[j]
+		if groupReasoning {
+			if ra, rb := hasReasoning(a), hasReasoning(b); ra != rb {
+				return ra
+			}
+		}
+		switch by {
+		case SortByCutoff:
+			ca, cb := cutoff(a), cutoff(b)
+			if !ca.Equal(cb) {
+				return ca.After(cb)
+			}
+		case SortByContext:
+			if wa, wb := contextWindow(a), contextWindow(b); wa != wb {
+				return wa > wb
+			}
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		return a.ID < b.ID
+	})
+}
+
+func hasReasoning(m *Model) bool {
+	return m.Meta != nil && m.Meta.HasReasoning
+}
+
+func cutoff(m *Model) (t time.Time) {
+	if m.Meta == nil {
+		return t
+	}
+	return m.Meta.KnowledgeCutoff
+}
+
+func contextWindow(m *Model) int {
+	if m.Meta == nil {
+		return 0
+	}
+	return m.Meta.ContextWindow
+}
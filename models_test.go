@@ -0,0 +1,39 @@
+package llm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func ids(models []*llm.Model) (ids []string) {
+	for _, m := range models {
+		ids = append(ids, m.Provider+"/"+m.ID)
+	}
+	return ids
+}
+
+func TestSortModels(t *testing.T) {
+	is := is.New(t)
+	date := func(year int) time.Time { return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC) }
+	models := []*llm.Model{
+		{Provider: "b", ID: "old", Meta: &llm.ModelMeta{KnowledgeCutoff: date(2023), ContextWindow: 200_000}},
+		{Provider: "a", ID: "unknown"},
+		{Provider: "b", ID: "new", Meta: &llm.ModelMeta{KnowledgeCutoff: date(2025), ContextWindow: 100_000, HasReasoning: true}},
+		{Provider: "a", ID: "mid", Meta: &llm.ModelMeta{KnowledgeCutoff: date(2024), ContextWindow: 100_000}},
+	}
+
+	llm.SortModels(models, llm.SortByProvider, false)
+	is.Equal(ids(models), []string{"a/mid", "a/unknown", "b/new", "b/old"})
+
+	llm.SortModels(models, llm.SortByCutoff, false)
+	is.Equal(ids(models), []string{"b/new", "a/mid", "b/old", "a/unknown"})
+
+	llm.SortModels(models, llm.SortByContext, false)
+	is.Equal(ids(models), []string{"b/old", "a/mid", "b/new", "a/unknown"})
+
+	llm.SortModels(models, llm.SortByContext, true)
+	is.Equal(ids(models), []string{"b/new", "b/old", "a/mid", "a/unknown"})
+}
@@ -0,0 +1,54 @@
+package llm
+
+// WithProviderParams passes extra parameters through to the named provider's
+// request, like OpenAI's service_tier or Anthropic's metadata. It's an escape
+// hatch for features that don't have an option yet. The parameters are sent
+// as is, so misspelled or mistyped ones may fail the request. Each provider,
+// including fallbacks, only gets its own parameters.
+func WithProviderParams(provider string, params map[string]any) Option {
+	return func(c *Config) {
+		if c.ProviderParams == nil {
+			c.ProviderParams = map[string]map[string]any{}
+		}
+		if c.ProviderParams[provider] == nil {
+			c.ProviderParams[provider] = map[string]any{}
+		}
+		for key, value := range params {
+			c.ProviderParams[provider][key] = value
+		}
+	}
+}
+
+// withProviderParams returns the request with the provider's parameters
+func withProviderParams(req *ChatRequest, params map[string]any) *ChatRequest {
+	if len(params) == 0 {
+		return req
+	}
+	clone := *req
+	clone.ProviderParams = params
+	return &clone
+}
+
+// WithRawEvents calls fn with each of the provider's native events before
+// they're translated, like the SSE events of OpenAI's Responses API or
+// Anthropic's message stream. Non-streaming fallbacks pass the whole
+// response. It's meant for debugging events the library doesn't handle yet.
+// Event types vary by provider and SDK version, so don't depend on them.
+func WithRawEvents(fn func(provider string, event any)) Option {
+	return func(c *Config) {
+		c.RawEvents = fn
+	}
+}
+
+// withRawEvents returns the request with a callback for the provider's raw
+// events
+func withRawEvents(req *ChatRequest, provider string, fn func(string, any)) *ChatRequest {
+	if fn == nil {
+		return req
+	}
+	clone := *req
+	clone.RawEvents = func(event any) {
+		fn(provider, event)
+	}
+	return &clone
+}
@@ -0,0 +1,274 @@
+package llm
+
+import (
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// StreamObject turns a chat stream into a stream of partial JSON object
+// snapshots. Each time the assistant's content grows, the accumulated JSON is
+// parsed tolerantly and the current snapshot is yielded, so callers can fill
+// in fields progressively as the model writes them.
+func StreamObject(stream iter.Seq2[*ChatResponse, error]) iter.Seq2[map[string]any, error] {
+	return func(yield func(map[string]any, error) bool) {
+		content := new(strings.Builder)
+		for res, err := range stream {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			// Start over if the model calls a tool, the object comes after
+			if res.ToolCall != nil {
+				content.Reset()
+				continue
+			}
+			if res.Role != "assistant" || res.Content == "" {
+				continue
+			}
+			content.WriteString(res.Content)
+			object, err := PartialObject(content.String())
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			if object == nil {
+				continue
+			}
+			if !yield(object, nil) {
+				return
+			}
+		}
+	}
+}
+
+// PartialObject parses a possibly incomplete JSON object. Any leading text
+// before the first "{" (e.g. a markdown fence) is ignored. Incomplete keys,
+// literals and numbers are dropped while incomplete strings are kept as-is. A
+// number at the end of the content is held back until a delimiter arrives,
+// since more digits may still be streaming in. Returns nil if no object has
+// started yet.
+func PartialObject(content string) (map[string]any, error) {
+	start := strings.IndexByte(content, '{')
+	if start < 0 {
+		return nil, nil
+	}
+	p := &partialParser{s: content[start:]}
+	value, _, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	object, _ := value.(map[string]any)
+	return object, nil
+}
+
+// partialParser is a JSON parser that tolerates the input ending at any point
+type partialParser struct {
+	s string
+	i int
+}
+
+func (p *partialParser) eof() bool {
+	return p.i >= len(p.s)
+}
+
+func (p *partialParser) skipSpace() {
+	for !p.eof() {
+		switch p.s[p.i] {
+		case ' ', '\t', '\n', '\r':
+			p.i++
+		default:
+			return
+		}
+	}
+}
+
+func (p *partialParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("llm: invalid json at offset %d: %s", p.i, fmt.Sprintf(format, args...))
+}
+
+// value parses the next value. ok is false if the input ended before a usable
+// value could be parsed.
+func (p *partialParser) value() (v any, ok bool, err error) {
+	p.skipSpace()
+	if p.eof() {
+		return nil, false, nil
+	}
+	switch c := p.s[p.i]; {
+	case c == '{':
+		return p.object()
+	case c == '[':
+		return p.array()
+	case c == '"':
+		s, _, err := p.string()
+		if err != nil {
+			return nil, false, err
+		}
+		return s, true, nil
+	case c == 't':
+		return p.literal("true", true)
+	case c == 'f':
+		return p.literal("false", false)
+	case c == 'n':
+		return p.literal("null", nil)
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.number()
+	default:
+		return nil, false, p.errorf("unexpected character %q", c)
+	}
+}
+
+func (p *partialParser) object() (any, bool, error) {
+	p.i++ // {
+	object := map[string]any{}
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return object, true, nil
+		}
+		switch p.s[p.i] {
+		case '}':
+			p.i++
+			return object, true, nil
+		case ',':
+			p.i++
+			continue
+		case '"':
+		default:
+			return nil, false, p.errorf("expected object key")
+		}
+		key, complete, err := p.string()
+		if err != nil {
+			return nil, false, err
+		} else if !complete {
+			return object, true, nil
+		}
+		p.skipSpace()
+		if p.eof() {
+			return object, true, nil
+		}
+		if p.s[p.i] != ':' {
+			return nil, false, p.errorf("expected ':' after object key")
+		}
+		p.i++
+		value, ok, err := p.value()
+		if err != nil {
+			return nil, false, err
+		} else if !ok {
+			return object, true, nil
+		}
+		object[key] = value
+	}
+}
+
+func (p *partialParser) array() (any, bool, error) {
+	p.i++ // [
+	array := []any{}
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return array, true, nil
+		}
+		switch p.s[p.i] {
+		case ']':
+			p.i++
+			return array, true, nil
+		case ',':
+			p.i++
+			continue
+		}
+		value, ok, err := p.value()
+		if err != nil {
+			return nil, false, err
+		} else if !ok {
+			return array, true, nil
+		}
+		array = append(array, value)
+	}
+}
+
+// string parses a string, returning what has been read so far if the input
+// ends before the closing quote
+func (p *partialParser) string() (string, bool, error) {
+	p.i++ // "
+	out := new(strings.Builder)
+	for !p.eof() {
+		c := p.s[p.i]
+		switch {
+		case c == '"':
+			p.i++
+			return out.String(), true, nil
+		case c == '\\':
+			if p.i+1 >= len(p.s) {
+				return out.String(), false, nil
+			}
+			switch esc := p.s[p.i+1]; esc {
+			case '"', '\\', '/':
+				out.WriteByte(esc)
+			case 'b':
+				out.WriteByte('\b')
+			case 'f':
+				out.WriteByte('\f')
+			case 'n':
+				out.WriteByte('\n')
+			case 'r':
+				out.WriteByte('\r')
+			case 't':
+				out.WriteByte('\t')
+			case 'u':
+				if p.i+6 > len(p.s) {
+					return out.String(), false, nil
+				}
+				code, err := strconv.ParseUint(p.s[p.i+2:p.i+6], 16, 16)
+				if err != nil {
+					return "", false, p.errorf("invalid unicode escape")
+				}
+				out.WriteRune(rune(code))
+				p.i += 4
+			default:
+				return "", false, p.errorf("invalid escape %q", esc)
+			}
+			p.i += 2
+		default:
+			r, size := utf8.DecodeRuneInString(p.s[p.i:])
+			out.WriteRune(r)
+			p.i += size
+		}
+	}
+	return out.String(), false, nil
+}
+
+func (p *partialParser) literal(name string, value any) (any, bool, error) {
+	rest := p.s[p.i:]
+	if strings.HasPrefix(rest, name) {
+		p.i += len(name)
+		return value, true, nil
+	}
+	if strings.HasPrefix(name, rest) {
+		p.i = len(p.s)
+		return nil, false, nil
+	}
+	return nil, false, p.errorf("invalid literal")
+}
+
+func (p *partialParser) number() (any, bool, error) {
+	start := p.i
+	for !p.eof() && strings.IndexByte("+-0123456789.eE", p.s[p.i]) >= 0 {
+		p.i++
+	}
+	// The number may still be streaming in (e.g. "12" before "123")
+	if p.eof() {
+		return nil, false, nil
+	}
+	n, err := strconv.ParseFloat(p.s[start:p.i], 64)
+	if err != nil {
+		return nil, false, p.errorf("invalid number %q", p.s[start:p.i])
+	}
+	return n, true, nil
+}
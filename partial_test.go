@@ -0,0 +1,82 @@
+package llm_test
+
+import (
+	"iter"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func TestPartialObject(t *testing.T) {
+	is := is.New(t)
+
+	object, err := llm.PartialObject("")
+	is.NoErr(err)
+	is.Equal(object, nil)
+
+	object, err = llm.PartialObject("```json\n{\"name\": \"Al")
+	is.NoErr(err)
+	is.Equal(object, map[string]any{"name": "Al"})
+
+	// Trailing numbers are held back until they're complete
+	object, err = llm.PartialObject(`{"name": "Alice", "age": 3`)
+	is.NoErr(err)
+	is.Equal(object, map[string]any{"name": "Alice"})
+
+	object, err = llm.PartialObject(`{"name": "Alice", "age": 32`)
+	is.NoErr(err)
+	is.Equal(object, map[string]any{"name": "Alice"})
+
+	object, err = llm.PartialObject(`{"name": "Alice", "age": 32 `)
+	is.NoErr(err)
+	is.Equal(object, map[string]any{"name": "Alice", "age": 32.0})
+
+	object, err = llm.PartialObject(`{"scores": [1, 23`)
+	is.NoErr(err)
+	is.Equal(object, map[string]any{"scores": []any{1.0}})
+
+	object, err = llm.PartialObject(`{"name": "Alice", "tags": ["a", "b`)
+	is.NoErr(err)
+	is.Equal(object, map[string]any{"name": "Alice", "tags": []any{"a", "b"}})
+
+	object, err = llm.PartialObject(`{"ok": tr`)
+	is.NoErr(err)
+	is.Equal(object, map[string]any{})
+
+	object, err = llm.PartialObject(`{"nested": {"a": null, "b": fal`)
+	is.NoErr(err)
+	is.Equal(object, map[string]any{"nested": map[string]any{"a": nil}})
+
+	object, err = llm.PartialObject(`{"na`)
+	is.NoErr(err)
+	is.Equal(object, map[string]any{})
+
+	_, err = llm.PartialObject(`{"name" "Alice"}`)
+	is.True(err != nil)
+}
+
+func TestStreamObject(t *testing.T) {
+	is := is.New(t)
+
+	chunks := []string{`{"title": "Hel`, `lo", "count"`, `: 1`, `2}`}
+	stream := func(yield func(*llm.ChatResponse, error) bool) {
+		for _, chunk := range chunks {
+			if !yield(&llm.ChatResponse{Role: "assistant", Content: chunk}, nil) {
+				return
+			}
+		}
+		yield(&llm.ChatResponse{Role: "assistant", Done: true}, nil)
+	}
+
+	var snapshots []map[string]any
+	for object, err := range llm.StreamObject(iter.Seq2[*llm.ChatResponse, error](stream)) {
+		is.NoErr(err)
+		snapshots = append(snapshots, object)
+	}
+	is.Equal(len(snapshots), 4)
+	is.Equal(snapshots[0], map[string]any{"title": "Hel"})
+	is.Equal(snapshots[1], map[string]any{"title": "Hello"})
+	is.Equal(snapshots[2], map[string]any{"title": "Hello"}) // 1 may become 12
+	is.Equal(snapshots[3], map[string]any{"title": "Hello", "count": 12.0})
+}
@@ -1,10 +1,17 @@
 package anthropic
 
 import (
+	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 
+	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
 )
 
 func TestNormalizeToolArgumentsEmpty(t *testing.T) {
@@ -24,3 +31,277 @@ func TestNormalizeToolArgumentsValid(t *testing.T) {
 	args := normalizeToolArguments(json.RawMessage(` {"x":1} `))
 	is.Equal(string(args), `{"x":1}`)
 }
+
+func TestToParamsClampsMaxTokens(t *testing.T) {
+	is := is.New(t)
+	params := toParams(&llm.ChatRequest{
+		Model:           "claude-opus-4-1",
+		Thinking:        llm.ThinkingHigh,
+		MaxOutputTokens: 100_000,
+	})
+	is.Equal(params.MaxTokens, int64(32_000))
+	is.Equal(params.Thinking.OfEnabled.BudgetTokens, int64(31_000))
+
+	// The default is clamped for models with small limits
+	params = toParams(&llm.ChatRequest{
+		Model:    "claude-3-haiku-20240307",
+		Thinking: llm.ThinkingNone,
+	})
+	is.Equal(params.MaxTokens, int64(4_000))
+}
+
+func TestToParamsMinThinkingBudget(t *testing.T) {
+	is := is.New(t)
+	meta["small-model"] = &llm.ModelMeta{MaxOutputTokens: 1500}
+	defer delete(meta, "small-model")
+	params := toParams(&llm.ChatRequest{
+		Model:    "small-model",
+		Thinking: llm.ThinkingLow,
+	})
+	is.Equal(params.MaxTokens, int64(1_500))
+	is.Equal(params.Thinking.OfEnabled.BudgetTokens, int64(1_024))
+
+	// Thinking is left off when even the minimum budget doesn't fit
+	meta["small-model"] = &llm.ModelMeta{MaxOutputTokens: 1000}
+	params = toParams(&llm.ChatRequest{
+		Model:    "small-model",
+		Thinking: llm.ThinkingLow,
+	})
+	is.Equal(params.MaxTokens, int64(1_000))
+	is.True(params.Thinking.OfEnabled == nil)
+}
+
+func TestCacheControl(t *testing.T) {
+	is := is.New(t)
+	params := toParams(&llm.ChatRequest{
+		Model: "claude-sonnet-4-6",
+		Messages: []*llm.Message{
+			llm.SystemMessage("You are a calculator"),
+			llm.UserMessage("What is 1+2?"),
+		},
+		Tools: []*llm.ToolSchema{
+			{Type: "function", Function: &llm.ToolFunction{Name: "add", Parameters: &llm.ToolFunctionParameters{Type: "object"}}},
+			{Type: "function", Function: &llm.ToolFunction{Name: "multiply", Parameters: &llm.ToolFunctionParameters{Type: "object"}}},
+		},
+	})
+	cacheControl(&params)
+	is.Equal(string(params.System[0].CacheControl.Type), "ephemeral")
+	is.Equal(string(params.Tools[0].OfTool.CacheControl.Type), "")
+	is.Equal(string(params.Tools[1].OfTool.CacheControl.Type), "ephemeral")
+
+	// Only the last tool is a breakpoint
+	body, err := json.Marshal(params)
+	is.NoErr(err)
+	is.Equal(strings.Count(string(body), `"cache_control":{"type":"ephemeral"}`), 2)
+}
+
+func TestToUsage(t *testing.T) {
+	is := is.New(t)
+	data, err := os.ReadFile("testdata/usage.json")
+	is.NoErr(err)
+	var usage anthropic.MessageDeltaUsage
+	is.NoErr(json.Unmarshal(data, &usage))
+	is.Equal(toUsage(usage), &llm.Usage{
+		InputTokens:       2212,
+		OutputTokens:      388,
+		TotalTokens:       2600,
+		CachedInputTokens: 2000,
+		CacheReadTokens:   2000,
+		CacheWriteTokens:  200,
+	})
+}
+
+func TestMessageStop(t *testing.T) {
+	is := is.New(t)
+	data, err := os.ReadFile("testdata/stream.sse")
+	is.NoErr(err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.URL.Path, "/v1/messages")
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := New("secret", WithBaseURL(server.URL))
+	var content strings.Builder
+	var dones []*llm.ChatResponse
+	for res, err := range client.Chat(context.Background(), &llm.ChatRequest{
+		Model:    "claude-sonnet-4-6",
+		Messages: []*llm.Message{llm.UserMessage("tell me a story")},
+	}) {
+		is.NoErr(err)
+		content.WriteString(res.Content)
+		if res.Done {
+			dones = append(dones, res)
+		}
+	}
+	is.Equal(content.String(), "Once upon a time")
+	is.Equal(len(dones), 1)
+	is.Equal(dones[0].StopReason, "max_tokens")
+	is.Equal(dones[0].Usage.TotalTokens, 28)
+}
+
+func TestToParamsEndUser(t *testing.T) {
+	is := is.New(t)
+	params := toParams(&llm.ChatRequest{
+		Model:   "claude-sonnet-4-6",
+		EndUser: "user-1234",
+	})
+	is.Equal(params.Metadata.UserID.Value, "user-1234")
+}
+
+func TestStreamFallback(t *testing.T) {
+	is := is.New(t)
+	fallback, err := os.ReadFile("testdata/fallback.json")
+	is.NoErr(err)
+	var streamed []bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		stream, _ := body["stream"].(bool)
+		streamed = append(streamed, stream)
+		w.Header().Set("Content-Type", "application/json")
+		// A proxy that blocks streaming
+		if stream {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"type":"error","error":{"type":"permission_error","message":"streaming is blocked"}}`))
+			return
+		}
+		w.Write(fallback)
+	}))
+	defer server.Close()
+
+	client := New("secret", WithBaseURL(server.URL))
+	var responses []*llm.ChatResponse
+	for res, err := range client.Chat(context.Background(), &llm.ChatRequest{
+		Model:          "claude-sonnet-4-6",
+		Messages:       []*llm.Message{llm.UserMessage("What is 20+22?")},
+		StreamFallback: true,
+	}) {
+		is.NoErr(err)
+		responses = append(responses, res)
+	}
+	is.Equal(streamed, []bool{true, false})
+	is.Equal(len(responses), 5)
+	is.True(responses[0].Start)
+	is.Equal(responses[1].Thinking, "Adding the numbers")
+	is.Equal(responses[2].Content, "Let me add those")
+	is.Equal(responses[3].ToolCall.ID, "toolu_1")
+	is.Equal(string(responses[3].ToolCall.Arguments), `{"a":20,"b":22}`)
+	is.True(responses[4].Done)
+	is.Equal(responses[4].StopReason, "") // Only unusual stops are reported
+	is.Equal(responses[4].Usage.TotalTokens, 20)
+}
+
+func TestImages(t *testing.T) {
+	is := is.New(t)
+	stream, err := os.ReadFile("testdata/stream.sse")
+	is.NoErr(err)
+	var body struct {
+		Messages []any `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write(stream)
+	}))
+	defer server.Close()
+
+	message := llm.UserMessage("What's in these images?")
+	message.Parts = []*llm.ContentPart{
+		llm.ImagePart("image/png", []byte("png")),
+		llm.ImageURLPart("image/jpeg", "https://example.com/cat.jpg"),
+	}
+	client := New("secret", WithBaseURL(server.URL))
+	for _, err := range client.Chat(context.Background(), &llm.ChatRequest{
+		Model:    "claude-sonnet-4-6",
+		Messages: []*llm.Message{message},
+	}) {
+		is.NoErr(err)
+	}
+
+	// Inline images are sent as base64 and URLs are left for Anthropic to fetch
+	fixture, err := os.ReadFile("testdata/image.json")
+	is.NoErr(err)
+	var expect []any
+	is.NoErr(json.Unmarshal(fixture, &expect))
+	is.Equal(body.Messages, expect)
+}
+
+func TestToolResultImages(t *testing.T) {
+	is := is.New(t)
+	params := toParams(&llm.ChatRequest{
+		Model: "claude-sonnet-4-6",
+		Messages: []*llm.Message{
+			llm.UserMessage("What's in dot.png?"),
+			{Role: "assistant", ToolCall: &llm.ToolCall{ID: "call_1", Name: "read", Arguments: []byte(`{"path":"dot.png"}`)}},
+			{Role: "tool", ToolCallID: "call_1", Content: `{"path":"dot.png"}`, Parts: []*llm.ContentPart{llm.ImagePart("image/png", []byte("png"))}},
+		},
+	})
+	is.Equal(len(params.Messages), 3)
+	result := params.Messages[2].Content[0].OfToolResult
+	is.Equal(len(result.Content), 2)
+	is.Equal(result.Content[0].OfText.Text, `{"path":"dot.png"}`)
+	is.Equal(result.Content[1].OfImage.Source.OfBase64.Data, "cG5n")
+}
+
+func TestForcedToolChoiceThinking(t *testing.T) {
+	is := is.New(t)
+	toolUse, err := os.ReadFile("testdata/tool_use.sse")
+	is.NoErr(err)
+	stream, err := os.ReadFile("testdata/stream.sse")
+	is.NoErr(err)
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		bodies = append(bodies, body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		if len(bodies) == 1 {
+			w.Write(toolUse)
+			return
+		}
+		w.Write(stream)
+	}))
+	defer server.Close()
+
+	add := llm.Func("add", "Add two numbers", func(ctx context.Context, in struct {
+		A int `json:"a"`
+		B int `json:"b"`
+	}) (int, error) {
+		return in.A + in.B, nil
+	})
+	provider := New("secret", WithBaseURL(server.URL))
+	for _, err := range llm.New(provider).Chat(context.Background(), provider.Name(),
+		llm.WithModel("claude-sonnet-4-6"),
+		llm.WithThinking(llm.ThinkingMedium),
+		llm.WithTool(add),
+		llm.WithToolChoice(llm.ToolChoiceTool("add")),
+		llm.WithMessage(llm.UserMessage("What is 20+22?")),
+	) {
+		is.NoErr(err)
+	}
+	is.Equal(len(bodies), 2)
+
+	// The forced step can't think
+	is.Equal(bodies[0]["tool_choice"].(map[string]any)["type"], "tool")
+	is.Equal(bodies[0]["thinking"], nil)
+
+	// Thinking stays off for the rest of the tool loop
+	is.Equal(bodies[1]["tool_choice"].(map[string]any)["type"], "auto")
+	is.Equal(bodies[1]["thinking"], nil)
+
+	// A new user message can think again
+	params := toParams(&llm.ChatRequest{
+		Model:    "claude-sonnet-4-6",
+		Thinking: llm.ThinkingMedium,
+		Messages: []*llm.Message{
+			llm.UserMessage("What is 20+22?"),
+			{Role: "assistant", ToolCall: &llm.ToolCall{ID: "toolu_1", Name: "add", Arguments: json.RawMessage(`{"a":20,"b":22}`)}},
+			{Role: "tool", ToolCallID: "toolu_1", Content: "42"},
+			llm.AssistantMessage("42"),
+			llm.UserMessage("And 1+2?"),
+		},
+	})
+	is.True(params.Thinking.OfEnabled != nil)
+}
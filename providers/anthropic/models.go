@@ -10,43 +10,47 @@ import (
 )
 
 // https://platform.claude.com/docs/en/about-claude/models/overview
+// https://platform.claude.com/docs/en/about-claude/pricing
 var meta = map[string]*llm.ModelMeta{
 	// Latest models
-	"claude-opus-4-6":           model("Claude Opus 4.6", date(2025, time.May, 31), 200_000, 128_000, true),
-	"claude-sonnet-4-6":         model("Claude Sonnet 4.6", date(2025, time.August, 31), 200_000, 64_000, true),
-	"claude-haiku-4-5":          model("Claude Haiku 4.5", date(2025, time.February, 28), 200_000, 64_000, true),
-	"claude-haiku-4-5-20251001": model("Claude Haiku 4.5", date(2025, time.February, 28), 200_000, 64_000, true),
+	"claude-opus-4-6":           model("Claude Opus 4.6", date(2025, time.May, 31), 200_000, 128_000, true, 5, 25),
+	"claude-sonnet-4-6":         model("Claude Sonnet 4.6", date(2025, time.August, 31), 200_000, 64_000, true, 3, 15).AsDefault(),
+	"claude-haiku-4-5":          model("Claude Haiku 4.5", date(2025, time.February, 28), 200_000, 64_000, true, 1, 5),
+	"claude-haiku-4-5-20251001": model("Claude Haiku 4.5", date(2025, time.February, 28), 200_000, 64_000, true, 1, 5),
 
 	// Legacy models
-	"claude-sonnet-4-5":          model("Claude Sonnet 4.5", date(2025, time.January, 31), 200_000, 64_000, true),
-	"claude-sonnet-4-5-20250929": model("Claude Sonnet 4.5", date(2025, time.January, 31), 200_000, 64_000, true),
+	"claude-sonnet-4-5":          model("Claude Sonnet 4.5", date(2025, time.January, 31), 200_000, 64_000, true, 3, 15),
+	"claude-sonnet-4-5-20250929": model("Claude Sonnet 4.5", date(2025, time.January, 31), 200_000, 64_000, true, 3, 15),
 
-	"claude-opus-4-5":          model("Claude Opus 4.5", date(2025, time.May, 31), 200_000, 64_000, true),
-	"claude-opus-4-5-20251101": model("Claude Opus 4.5", date(2025, time.May, 31), 200_000, 64_000, true),
+	"claude-opus-4-5":          model("Claude Opus 4.5", date(2025, time.May, 31), 200_000, 64_000, true, 5, 25),
+	"claude-opus-4-5-20251101": model("Claude Opus 4.5", date(2025, time.May, 31), 200_000, 64_000, true, 5, 25),
 
-	"claude-opus-4-1":          model("Claude Opus 4.1", date(2025, time.January, 31), 200_000, 32_000, true),
-	"claude-opus-4-1-20250805": model("Claude Opus 4.1", date(2025, time.January, 31), 200_000, 32_000, true),
+	"claude-opus-4-1":          model("Claude Opus 4.1", date(2025, time.January, 31), 200_000, 32_000, true, 15, 75),
+	"claude-opus-4-1-20250805": model("Claude Opus 4.1", date(2025, time.January, 31), 200_000, 32_000, true, 15, 75),
 
-	"claude-sonnet-4-0":        model("Claude Sonnet 4", date(2025, time.January, 31), 200_000, 64_000, true),
-	"claude-sonnet-4-20250514": model("Claude Sonnet 4", date(2025, time.January, 31), 200_000, 64_000, true),
+	"claude-sonnet-4-0":        model("Claude Sonnet 4", date(2025, time.January, 31), 200_000, 64_000, true, 3, 15),
+	"claude-sonnet-4-20250514": model("Claude Sonnet 4", date(2025, time.January, 31), 200_000, 64_000, true, 3, 15),
 
-	"claude-3-7-sonnet-latest":   model("Claude Sonnet 3.7", date(2024, time.October, 31), 200_000, 64_000, true),
-	"claude-3-7-sonnet-20250219": model("Claude Sonnet 3.7", date(2024, time.October, 31), 200_000, 64_000, true),
+	"claude-3-7-sonnet-latest":   model("Claude Sonnet 3.7", date(2024, time.October, 31), 200_000, 64_000, true, 3, 15),
+	"claude-3-7-sonnet-20250219": model("Claude Sonnet 3.7", date(2024, time.October, 31), 200_000, 64_000, true, 3, 15),
 
-	"claude-opus-4-0":        model("Claude Opus 4", date(2025, time.January, 31), 200_000, 32_000, true),
-	"claude-opus-4-20250514": model("Claude Opus 4", date(2025, time.January, 31), 200_000, 32_000, true),
+	"claude-opus-4-0":        model("Claude Opus 4", date(2025, time.January, 31), 200_000, 32_000, true, 15, 75),
+	"claude-opus-4-20250514": model("Claude Opus 4", date(2025, time.January, 31), 200_000, 32_000, true, 15, 75),
 
 	// Anthropic notes a single cutoff date for some Haiku models; we use that date as knowledge cutoff.
-	"claude-3-haiku-20240307": model("Claude Haiku 3", date(2023, time.August, 31), 200_000, 4_000, false),
+	"claude-3-haiku-20240307": model("Claude Haiku 3", date(2023, time.August, 31), 200_000, 4_000, false, 0.25, 1.25),
 }
 
-func model(displayName string, knowledgeCutoff time.Time, contextWindow int, maxOutputTokens int, hasReasoning bool) *llm.ModelMeta {
+func model(displayName string, knowledgeCutoff time.Time, contextWindow int, maxOutputTokens int, hasReasoning bool, inputCostPerMTok, outputCostPerMTok float64) *llm.ModelMeta {
 	return &llm.ModelMeta{
 		DisplayName:     displayName,
 		KnowledgeCutoff: knowledgeCutoff,
 		ContextWindow:   contextWindow,
 		MaxOutputTokens: maxOutputTokens,
 		HasReasoning:    hasReasoning,
+
+		InputCostPerMTok:  inputCostPerMTok,
+		OutputCostPerMTok: outputCostPerMTok,
 	}
 }
 
@@ -54,6 +58,13 @@ func date(year int, month time.Month, day int) time.Time {
 	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
 }
 
+var _ llm.DefaultModeler = (*Client)(nil)
+
+// DefaultModel returns the recommended model
+func (c *Client) DefaultModel() string {
+	return llm.DefaultModelIn(meta)
+}
+
 // Model retrieves a specific model
 func (c *Client) Model(ctx context.Context, id string) (*llm.Model, error) {
 	m, err := c.ac.Models.Get(ctx, id, anthropic.ModelGetParams{})
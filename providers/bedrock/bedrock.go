@@ -0,0 +1,544 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsbedrock "github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/matthewmueller/llm"
+)
+
+// New creates a new Bedrock client from an AWS config, typically loaded from
+// the standard credential chain with config.LoadDefaultConfig
+func New(cfg aws.Config) *Client {
+	return &Client{
+		rc: bedrockruntime.NewFromConfig(cfg),
+		bc: awsbedrock.NewFromConfig(cfg),
+	}
+}
+
+// Client implements the llm.Provider interface for AWS Bedrock
+type Client struct {
+	rc *bedrockruntime.Client
+	bc *awsbedrock.Client
+}
+
+var _ llm.Provider = (*Client)(nil)
+
+func (c *Client) Name() string {
+	return "bedrock"
+}
+
+// thinkingBudget maps thinking levels to token budgets
+func thinkingBudget(level llm.Thinking) int32 {
+	switch level {
+	case llm.ThinkingNone, "":
+		return 0
+	case llm.ThinkingLow:
+		return 4000
+	case llm.ThinkingMedium:
+		return 10000
+	case llm.ThinkingHigh:
+		return 32000
+	default:
+		return 0
+	}
+}
+
+func toUsage(usage *types.TokenUsage) *llm.Usage {
+	if usage == nil {
+		return nil
+	}
+	return &llm.Usage{
+		InputTokens:       int(aws.ToInt32(usage.InputTokens)),
+		OutputTokens:      int(aws.ToInt32(usage.OutputTokens)),
+		TotalTokens:       int(aws.ToInt32(usage.TotalTokens)),
+		CachedInputTokens: int(aws.ToInt32(usage.CacheReadInputTokens)),
+		CacheReadTokens:   int(aws.ToInt32(usage.CacheReadInputTokens)),
+		CacheWriteTokens:  int(aws.ToInt32(usage.CacheWriteInputTokens)),
+	}
+}
+
+func toSchema(prop *llm.ToolProperty) map[string]any {
+	p := map[string]any{
+		"type":        prop.Type,
+		"description": prop.Description,
+	}
+	if len(prop.Enum) > 0 {
+		p["enum"] = prop.Enum
+	}
+	if prop.Items != nil {
+		p["items"] = toSchema(prop.Items)
+	}
+	if len(prop.Properties) > 0 {
+		props := make(map[string]any)
+		for name, child := range prop.Properties {
+			props[name] = toSchema(child)
+		}
+		p["properties"] = props
+		p["required"] = prop.Required
+	}
+	if prop.AdditionalProperties != nil {
+		p["additionalProperties"] = toSchema(prop.AdditionalProperties)
+	}
+	if prop.Minimum != nil {
+		p["minimum"] = *prop.Minimum
+	}
+	if prop.Maximum != nil {
+		p["maximum"] = *prop.Maximum
+	}
+	if prop.MinLength != nil {
+		p["minLength"] = *prop.MinLength
+	}
+	if prop.MaxLength != nil {
+		p["maxLength"] = *prop.MaxLength
+	}
+	if prop.Pattern != "" {
+		p["pattern"] = prop.Pattern
+	}
+	if prop.Default != nil {
+		p["default"] = prop.Default
+	}
+	return p
+}
+
+func toParameters(params *llm.ToolFunctionParameters) map[string]any {
+	props := make(map[string]any)
+	for name, prop := range params.Properties {
+		props[name] = toSchema(prop)
+	}
+	return map[string]any{
+		"type":       params.Type,
+		"properties": props,
+		"required":   params.Required,
+	}
+}
+
+// toImageFormat converts a media type like "image/png" into an image format
+func toImageFormat(mediaType string) (types.ImageFormat, error) {
+	switch mediaType {
+	case "image/png":
+		return types.ImageFormatPng, nil
+	case "image/jpeg", "image/jpg":
+		return types.ImageFormatJpeg, nil
+	case "image/gif":
+		return types.ImageFormatGif, nil
+	case "image/webp":
+		return types.ImageFormatWebp, nil
+	default:
+		return "", fmt.Errorf("bedrock: unsupported image type %q", mediaType)
+	}
+}
+
+// toUserBlocks converts a user message's content and parts into content blocks
+func toUserBlocks(m *llm.Message) (blocks []types.ContentBlock, err error) {
+	if m.Content != "" || len(m.Parts) == 0 {
+		blocks = append(blocks, &types.ContentBlockMemberText{Value: m.Content})
+	}
+	for _, part := range m.Parts {
+		switch part.Type {
+		case "text":
+			blocks = append(blocks, &types.ContentBlockMemberText{Value: part.Text})
+		case "image":
+			image, err := toImageBlock(part)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, &types.ContentBlockMemberImage{Value: image})
+		}
+	}
+	return blocks, nil
+}
+
+// toImageBlock converts an inline image part into an image block
+func toImageBlock(part *llm.ContentPart) (types.ImageBlock, error) {
+	if part.URL != "" {
+		return types.ImageBlock{}, fmt.Errorf("bedrock: image URLs aren't supported, use inline image data")
+	}
+	format, err := toImageFormat(part.MediaType)
+	if err != nil {
+		return types.ImageBlock{}, err
+	}
+	return types.ImageBlock{
+		Format: format,
+		Source: &types.ImageSourceMemberBytes{Value: part.Data},
+	}, nil
+}
+
+// toToolResult converts a tool result into a tool result block, with any
+// images the tool returned after its text
+func toToolResult(m *llm.Message) (*types.ContentBlockMemberToolResult, error) {
+	content := []types.ToolResultContentBlock{
+		&types.ToolResultContentBlockMemberText{Value: m.Content},
+	}
+	for _, part := range m.Parts {
+		if part.Type != "image" {
+			continue
+		}
+		image, err := toImageBlock(part)
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, &types.ToolResultContentBlockMemberImage{Value: image})
+	}
+	return &types.ContentBlockMemberToolResult{
+		Value: types.ToolResultBlock{
+			ToolUseId: aws.String(m.ToolCallID),
+			Content:   content,
+		},
+	}, nil
+}
+
+// toMessages converts messages into Bedrock messages, pulling out the system
+// prompt since Bedrock sends it separately. Bedrock expects roles to
+// alternate, so consecutive messages with the same role are merged. This
+// groups a step's tool calls into one assistant message and their results
+// into one user message.
+func toMessages(messages []*llm.Message) (out []types.Message, system []types.SystemContentBlock, err error) {
+	add := func(role types.ConversationRole, blocks ...types.ContentBlock) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(out); n > 0 && out[n-1].Role == role {
+			out[n-1].Content = append(out[n-1].Content, blocks...)
+			return
+		}
+		out = append(out, types.Message{Role: role, Content: blocks})
+	}
+	for _, m := range messages {
+		switch m.Role {
+		case "system":
+			system = append(system, &types.SystemContentBlockMemberText{Value: m.Content})
+		case "user":
+			blocks, err := toUserBlocks(m)
+			if err != nil {
+				return nil, nil, err
+			}
+			add(types.ConversationRoleUser, blocks...)
+		case "assistant":
+			var blocks []types.ContentBlock
+			if m.Content != "" {
+				blocks = append(blocks, &types.ContentBlockMemberText{Value: m.Content})
+			}
+			if m.ToolCall != nil {
+				input := map[string]any{}
+				if len(m.ToolCall.Arguments) > 0 {
+					if err := json.Unmarshal(m.ToolCall.Arguments, &input); err != nil {
+						return nil, nil, fmt.Errorf("bedrock: unmarshaling tool call arguments: %w", err)
+					}
+				}
+				blocks = append(blocks, &types.ContentBlockMemberToolUse{
+					Value: types.ToolUseBlock{
+						ToolUseId: aws.String(m.ToolCall.ID),
+						Name:      aws.String(m.ToolCall.Name),
+						Input:     document.NewLazyDocument(input),
+					},
+				})
+			}
+			add(types.ConversationRoleAssistant, blocks...)
+		case "tool":
+			block, err := toToolResult(m)
+			if err != nil {
+				return nil, nil, err
+			}
+			add(types.ConversationRoleUser, block)
+		}
+	}
+	return out, system, nil
+}
+
+// isClaude reports whether the model is in the Anthropic family, including
+// cross-region inference profiles like "us.anthropic.claude-sonnet-4-..."
+func isClaude(model string) bool {
+	return strings.Contains(model, "anthropic.claude")
+}
+
+// toInput converts a chat request into ConverseStream input
+func toInput(req *llm.ChatRequest) (*bedrockruntime.ConverseStreamInput, error) {
+	messages, system, err := toMessages(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	input := &bedrockruntime.ConverseStreamInput{
+		ModelId:  aws.String(req.Model),
+		Messages: messages,
+		System:   system,
+	}
+
+	// Bedrock has no "none" tool choice, so tools are left out instead
+	if len(req.Tools) > 0 && req.ToolChoice.Mode != "none" {
+		config := &types.ToolConfiguration{}
+		for _, t := range req.Tools {
+			config.Tools = append(config.Tools, &types.ToolMemberToolSpec{
+				Value: types.ToolSpecification{
+					Name:        aws.String(t.Function.Name),
+					Description: aws.String(t.Function.Description),
+					InputSchema: &types.ToolInputSchemaMemberJson{
+						Value: document.NewLazyDocument(toParameters(t.Function.Parameters)),
+					},
+				},
+			})
+		}
+		switch req.ToolChoice.Mode {
+		case "auto":
+			config.ToolChoice = &types.ToolChoiceMemberAuto{}
+		case "required":
+			config.ToolChoice = &types.ToolChoiceMemberAny{}
+		case "tool":
+			config.ToolChoice = &types.ToolChoiceMemberTool{
+				Value: types.SpecificToolChoice{Name: aws.String(req.ToolChoice.Name)},
+			}
+		}
+		input.ToolConfig = config
+	}
+
+	inference := &types.InferenceConfiguration{}
+	if len(req.StopSequences) > 0 {
+		inference.StopSequences = req.StopSequences
+	}
+	maxTokens := int32(0)
+	if req.MaxOutputTokens > 0 {
+		maxTokens = int32(req.MaxOutputTokens)
+	}
+
+	// Fields for the model that the Converse API doesn't cover
+	fields := map[string]any{}
+
+	// Translate thinking levels to Claude's extended thinking. Claude doesn't
+	// allow extended thinking when forcing a tool call.
+	if budget := thinkingBudget(req.Thinking); budget > 0 && isClaude(req.Model) && !req.ToolChoice.Forced() {
+		if meta := lookupMeta(req.Model); meta != nil && meta.HasReasoning {
+			// Extended thinking requires higher max tokens
+			if maxTokens < budget+1000 {
+				maxTokens = budget + 1000
+			}
+			maxTokens = int32(meta.ClampOutputTokens(int(maxTokens)))
+			// The budget has to fit within the model's max tokens
+			budget = min(budget, maxTokens-1000)
+			fields["thinking"] = map[string]any{
+				"type":          "enabled",
+				"budget_tokens": budget,
+			}
+		}
+	}
+
+	// Provider params go to the model alongside the thinking config
+	for key, value := range req.ProviderParams {
+		fields[key] = value
+	}
+	if len(fields) > 0 {
+		input.AdditionalModelRequestFields = document.NewLazyDocument(fields)
+	}
+	if maxTokens > 0 {
+		inference.MaxTokens = aws.Int32(int32(lookupMeta(req.Model).ClampOutputTokens(int(maxTokens))))
+	}
+	if inference.MaxTokens != nil || len(inference.StopSequences) > 0 {
+		input.InferenceConfig = inference
+	}
+
+	// Constrain the output to the response schema
+	if req.ResponseSchema != nil {
+		schema, err := json.Marshal(toParameters(req.ResponseSchema))
+		if err != nil {
+			return nil, fmt.Errorf("bedrock: marshaling response schema: %w", err)
+		}
+		input.OutputConfig = &types.OutputConfig{
+			TextFormat: &types.OutputFormat{
+				Type: types.OutputFormatTypeJsonSchema,
+				Structure: &types.OutputFormatStructureMemberJsonSchema{
+					Value: types.JsonSchemaDefinition{Schema: aws.String(string(schema))},
+				},
+			},
+		}
+	}
+
+	return input, nil
+}
+
+// Chat sends a chat request to Bedrock using the ConverseStream API
+func (c *Client) Chat(ctx context.Context, req *llm.ChatRequest) iter.Seq2[*llm.ChatResponse, error] {
+	return func(yield func(*llm.ChatResponse, error) bool) {
+		if req.Model == "" {
+			yield(nil, fmt.Errorf("bedrock: required model is empty"))
+			return
+		}
+
+		input, err := toInput(req)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		out, err := c.rc.ConverseStream(ctx, input)
+		if err != nil {
+			if req.StreamFallback {
+				c.fallback(ctx, req, input, yield)
+				return
+			}
+			yield(nil, fmt.Errorf("bedrock: streaming: %w", err))
+			return
+		}
+		stream := out.GetStream()
+		defer stream.Close()
+
+		// Track tool use blocks being built
+		var currentToolUse *llm.ToolCall
+		var toolInput strings.Builder
+		var stopped bool
+
+		for event := range stream.Events() {
+			req.Raw(event)
+			switch evt := event.(type) {
+			case *types.ConverseStreamOutputMemberMessageStart:
+				if !yield(&llm.ChatResponse{
+					Role:  "assistant",
+					Start: true,
+				}, nil) {
+					return
+				}
+
+			case *types.ConverseStreamOutputMemberContentBlockStart:
+				if start, ok := evt.Value.Start.(*types.ContentBlockStartMemberToolUse); ok {
+					currentToolUse = &llm.ToolCall{
+						ID:   aws.ToString(start.Value.ToolUseId),
+						Name: aws.ToString(start.Value.Name),
+					}
+					toolInput.Reset()
+				}
+
+			case *types.ConverseStreamOutputMemberContentBlockDelta:
+				chatResp := &llm.ChatResponse{
+					Role: "assistant",
+				}
+				switch delta := evt.Value.Delta.(type) {
+				case *types.ContentBlockDeltaMemberText:
+					chatResp.Content = delta.Value
+				case *types.ContentBlockDeltaMemberReasoningContent:
+					if text, ok := delta.Value.(*types.ReasoningContentBlockDeltaMemberText); ok {
+						chatResp.Thinking = text.Value
+					}
+				case *types.ContentBlockDeltaMemberToolUse:
+					// Accumulate tool input JSON
+					toolInput.WriteString(aws.ToString(delta.Value.Input))
+					continue
+				}
+				if chatResp.Content != "" || chatResp.Thinking != "" {
+					if !yield(chatResp, nil) {
+						return
+					}
+				}
+
+			case *types.ConverseStreamOutputMemberContentBlockStop:
+				// If we were building a tool use, emit it now
+				if currentToolUse != nil {
+					currentToolUse.Arguments = normalizeToolArguments(toolInput.String())
+					if !yield(&llm.ChatResponse{
+						Role:     "assistant",
+						ToolCall: currentToolUse,
+					}, nil) {
+						return
+					}
+					currentToolUse = nil
+				}
+
+			case *types.ConverseStreamOutputMemberMessageStop:
+				// Usage arrives in the metadata event after the message stops
+				stopped = true
+
+			case *types.ConverseStreamOutputMemberMetadata:
+				if !yield(&llm.ChatResponse{
+					Role:  "assistant",
+					Done:  true,
+					Usage: toUsage(evt.Value.Usage),
+				}, nil) {
+					return
+				}
+				stopped = false
+			}
+		}
+
+		if err := stream.Err(); err != nil {
+			yield(nil, fmt.Errorf("bedrock: streaming: %w", err))
+			return
+		}
+
+		// The stream ended without metadata
+		if stopped {
+			yield(&llm.ChatResponse{Role: "assistant", Done: true}, nil)
+		}
+	}
+}
+
+// normalizeToolArguments ensures tool arguments are a JSON object
+func normalizeToolArguments(args string) json.RawMessage {
+	if strings.TrimSpace(args) == "" || !json.Valid([]byte(args)) {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(args)
+}
+
+// fallback sends a non-streaming request and synthesizes the stream events
+func (c *Client) fallback(ctx context.Context, req *llm.ChatRequest, input *bedrockruntime.ConverseStreamInput, yield func(*llm.ChatResponse, error) bool) {
+	out, err := c.rc.Converse(ctx, &bedrockruntime.ConverseInput{
+		ModelId:                      input.ModelId,
+		Messages:                     input.Messages,
+		System:                       input.System,
+		ToolConfig:                   input.ToolConfig,
+		InferenceConfig:              input.InferenceConfig,
+		AdditionalModelRequestFields: input.AdditionalModelRequestFields,
+		OutputConfig:                 input.OutputConfig,
+	})
+	if err != nil {
+		yield(nil, fmt.Errorf("bedrock: non-streaming fallback: %w", err))
+		return
+	}
+	req.Raw(out)
+	if !yield(&llm.ChatResponse{Role: "assistant", Start: true}, nil) {
+		return
+	}
+	if message, ok := out.Output.(*types.ConverseOutputMemberMessage); ok {
+		for _, block := range message.Value.Content {
+			chatResp := &llm.ChatResponse{
+				Role: "assistant",
+			}
+			switch block := block.(type) {
+			case *types.ContentBlockMemberText:
+				chatResp.Content = block.Value
+			case *types.ContentBlockMemberReasoningContent:
+				text, ok := block.Value.(*types.ReasoningContentBlockMemberReasoningText)
+				if !ok {
+					continue
+				}
+				chatResp.Thinking = aws.ToString(text.Value.Text)
+			case *types.ContentBlockMemberToolUse:
+				args, err := block.Value.Input.MarshalSmithyDocument()
+				if err != nil {
+					yield(nil, fmt.Errorf("bedrock: marshaling tool input: %w", err))
+					return
+				}
+				chatResp.ToolCall = &llm.ToolCall{
+					ID:        aws.ToString(block.Value.ToolUseId),
+					Name:      aws.ToString(block.Value.Name),
+					Arguments: normalizeToolArguments(string(args)),
+				}
+			default:
+				continue
+			}
+			if !yield(chatResp, nil) {
+				return
+			}
+		}
+	}
+	yield(&llm.ChatResponse{
+		Role:  "assistant",
+		Done:  true,
+		Usage: toUsage(out.Usage),
+	}, nil)
+}
@@ -0,0 +1,115 @@
+package bedrock_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+	"github.com/matthewmueller/llm/providers/bedrock"
+)
+
+const testModel = `us.anthropic.claude-haiku-4-5-20251001-v1:0`
+
+// loadProvider loads the provider from the standard AWS credential chain. The
+// tests are skipped unless BEDROCK_TEST is set since they need AWS access.
+func loadProvider(t *testing.T) *bedrock.Client {
+	t.Helper()
+	if os.Getenv("BEDROCK_TEST") == "" {
+		t.Skip("BEDROCK_TEST not set")
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		t.Fatalf("bedrock: loading aws config: %v", err)
+	}
+	return bedrock.New(cfg)
+}
+
+func testContext(t *testing.T) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestSimpleChat(t *testing.T) {
+	provider := loadProvider(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	client := llm.New(provider)
+	var content strings.Builder
+	for event, err := range client.Chat(ctx,
+		provider.Name(),
+		llm.WithModel(testModel),
+		llm.WithMessage(llm.UserMessage("What is 2+2? Reply with just the number.")),
+	) {
+		is.NoErr(err)
+		content.WriteString(event.Content)
+	}
+	is.True(strings.Contains(content.String(), "4"))
+}
+
+var addTool = llm.Func("add", "Add two numbers together", func(ctx context.Context, in struct {
+	A int `json:"a" description:"First number" is:"required"`
+	B int `json:"b" description:"Second number" is:"required"`
+}) (int, error) {
+	return in.A + in.B, nil
+})
+
+var multiplyTool = llm.Func("multiply", "Multiply two numbers together", func(ctx context.Context, in struct {
+	A int `json:"a" description:"First number" is:"required"`
+	B int `json:"b" description:"Second number" is:"required"`
+}) (int, error) {
+	return in.A * in.B, nil
+})
+
+func TestToolParallel(t *testing.T) {
+	provider := loadProvider(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	client := llm.New(provider)
+	var calls []string
+	content := new(strings.Builder)
+	for event, err := range client.Chat(ctx,
+		provider.Name(),
+		llm.WithModel(testModel),
+		llm.WithTool(addTool, multiplyTool),
+		llm.WithMessage(llm.UserMessage("Use the tools to compute 2+3 and 4*5, then reply with both results.")),
+	) {
+		is.NoErr(err)
+		if event.ToolCall != nil {
+			calls = append(calls, event.ToolCall.Name)
+		}
+		content.WriteString(event.Content)
+	}
+	is.True(len(calls) >= 2)
+	is.True(strings.Contains(content.String(), "5"))
+	is.True(strings.Contains(content.String(), "20"))
+}
+
+func TestThinking(t *testing.T) {
+	provider := loadProvider(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	client := llm.New(provider)
+	var content, thinking strings.Builder
+	for event, err := range client.Chat(ctx,
+		provider.Name(),
+		llm.WithModel(testModel),
+		llm.WithThinking(llm.ThinkingLow),
+		llm.WithMessage(llm.UserMessage("What is 17*23? Reply with just the number.")),
+	) {
+		is.NoErr(err)
+		content.WriteString(event.Content)
+		thinking.WriteString(event.Thinking)
+	}
+	is.True(strings.Contains(content.String(), "391"))
+	is.True(thinking.Len() > 0)
+}
@@ -0,0 +1,82 @@
+package bedrock
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func TestToMessages(t *testing.T) {
+	is := is.New(t)
+	messages, system, err := toMessages([]*llm.Message{
+		llm.SystemMessage("You are a calculator"),
+		llm.UserMessage("What is 2+3 and 4*5?"),
+		{Role: "assistant", ToolCall: &llm.ToolCall{ID: "call_1", Name: "add", Arguments: json.RawMessage(`{"a":2,"b":3}`)}},
+		{Role: "assistant", ToolCall: &llm.ToolCall{ID: "call_2", Name: "multiply", Arguments: json.RawMessage(`{"a":4,"b":5}`)}},
+		{Role: "tool", ToolCallID: "call_1", Content: "5"},
+		{Role: "tool", ToolCallID: "call_2", Content: "20"},
+		llm.AssistantMessage("5 and 20"),
+	})
+	is.NoErr(err)
+	is.Equal(len(system), 1)
+	is.Equal(system[0].(*types.SystemContentBlockMemberText).Value, "You are a calculator")
+
+	// Roles alternate, so tool calls and results are grouped
+	is.Equal(len(messages), 4)
+	is.Equal(messages[0].Role, types.ConversationRoleUser)
+	is.Equal(messages[0].Content[0].(*types.ContentBlockMemberText).Value, "What is 2+3 and 4*5?")
+
+	is.Equal(messages[1].Role, types.ConversationRoleAssistant)
+	is.Equal(len(messages[1].Content), 2)
+	toolUse := messages[1].Content[0].(*types.ContentBlockMemberToolUse).Value
+	is.Equal(aws.ToString(toolUse.ToolUseId), "call_1")
+	is.Equal(aws.ToString(toolUse.Name), "add")
+	input, err := toolUse.Input.MarshalSmithyDocument()
+	is.NoErr(err)
+	is.Equal(string(input), `{"a":2,"b":3}`)
+	is.Equal(aws.ToString(messages[1].Content[1].(*types.ContentBlockMemberToolUse).Value.Name), "multiply")
+
+	is.Equal(messages[2].Role, types.ConversationRoleUser)
+	is.Equal(len(messages[2].Content), 2)
+	result := messages[2].Content[1].(*types.ContentBlockMemberToolResult).Value
+	is.Equal(aws.ToString(result.ToolUseId), "call_2")
+	is.Equal(result.Content[0].(*types.ToolResultContentBlockMemberText).Value, "20")
+
+	is.Equal(messages[3].Role, types.ConversationRoleAssistant)
+	is.Equal(messages[3].Content[0].(*types.ContentBlockMemberText).Value, "5 and 20")
+}
+
+func TestToMessagesImageURL(t *testing.T) {
+	is := is.New(t)
+	_, _, err := toMessages([]*llm.Message{
+		{Role: "user", Parts: []*llm.ContentPart{{Type: "image", URL: "https://example.com/cat.png"}}},
+	})
+	is.True(err != nil)
+}
+
+func TestToMessagesToolResultImages(t *testing.T) {
+	is := is.New(t)
+	messages, _, err := toMessages([]*llm.Message{
+		llm.UserMessage("What's in dot.png?"),
+		{Role: "assistant", ToolCall: &llm.ToolCall{ID: "call_1", Name: "read", Arguments: json.RawMessage(`{"path":"dot.png"}`)}},
+		{Role: "tool", ToolCallID: "call_1", Content: `{"path":"dot.png"}`, Parts: []*llm.ContentPart{llm.ImagePart("image/png", []byte("png"))}},
+	})
+	is.NoErr(err)
+	is.Equal(len(messages), 3)
+	result := messages[2].Content[0].(*types.ContentBlockMemberToolResult).Value
+	is.Equal(len(result.Content), 2)
+	image := result.Content[1].(*types.ToolResultContentBlockMemberImage).Value
+	is.Equal(image.Format, types.ImageFormatPng)
+	is.Equal(image.Source.(*types.ImageSourceMemberBytes).Value, []byte("png"))
+}
+
+func TestLookupMetaInferenceProfile(t *testing.T) {
+	is := is.New(t)
+	meta := lookupMeta("us.anthropic.claude-sonnet-4-5-20250929-v1:0")
+	is.True(meta != nil)
+	is.Equal(meta.DisplayName, "Claude Sonnet 4.5")
+}
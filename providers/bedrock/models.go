@@ -0,0 +1,127 @@
+package bedrock
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsbedrock "github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+	"github.com/matthewmueller/llm"
+)
+
+// https://docs.aws.amazon.com/bedrock/latest/userguide/models-supported.html
+// https://aws.amazon.com/bedrock/pricing/
+var meta = map[string]*llm.ModelMeta{
+	// Anthropic
+	"anthropic.claude-sonnet-4-5-20250929-v1:0": model("Claude Sonnet 4.5", date(2025, time.January, 31), 200_000, 64_000, true, 3, 15).AsDefault(),
+	"anthropic.claude-haiku-4-5-20251001-v1:0":  model("Claude Haiku 4.5", date(2025, time.February, 28), 200_000, 64_000, true, 1, 5),
+	"anthropic.claude-opus-4-5-20251101-v1:0":   model("Claude Opus 4.5", date(2025, time.May, 31), 200_000, 64_000, true, 5, 25),
+	"anthropic.claude-opus-4-1-20250805-v1:0":   model("Claude Opus 4.1", date(2025, time.January, 31), 200_000, 32_000, true, 15, 75),
+	"anthropic.claude-sonnet-4-20250514-v1:0":   model("Claude Sonnet 4", date(2025, time.January, 31), 200_000, 64_000, true, 3, 15),
+	"anthropic.claude-opus-4-20250514-v1:0":     model("Claude Opus 4", date(2025, time.January, 31), 200_000, 32_000, true, 15, 75),
+	"anthropic.claude-3-7-sonnet-20250219-v1:0": model("Claude Sonnet 3.7", date(2024, time.October, 31), 200_000, 64_000, true, 3, 15),
+	"anthropic.claude-3-5-haiku-20241022-v1:0":  model("Claude Haiku 3.5", date(2024, time.July, 31), 200_000, 8_192, false, 0.8, 4),
+	"anthropic.claude-3-haiku-20240307-v1:0":    model("Claude Haiku 3", date(2023, time.August, 31), 200_000, 4_096, false, 0.25, 1.25),
+
+	// Llama
+	"meta.llama3-1-8b-instruct-v1:0":         model("Llama 3.1 8B", date(2023, time.December, 31), 128_000, 8_192, false, 0.22, 0.22),
+	"meta.llama3-1-70b-instruct-v1:0":        model("Llama 3.1 70B", date(2023, time.December, 31), 128_000, 8_192, false, 0.72, 0.72),
+	"meta.llama3-3-70b-instruct-v1:0":        model("Llama 3.3 70B", date(2023, time.December, 31), 128_000, 8_192, false, 0.72, 0.72),
+	"meta.llama4-scout-17b-instruct-v1:0":    model("Llama 4 Scout", date(2024, time.August, 31), 3_500_000, 8_192, false, 0.17, 0.66),
+	"meta.llama4-maverick-17b-instruct-v1:0": model("Llama 4 Maverick", date(2024, time.August, 31), 1_000_000, 8_192, false, 0.24, 0.97),
+}
+
+func model(displayName string, knowledgeCutoff time.Time, contextWindow int, maxOutputTokens int, hasReasoning bool, inputCostPerMTok, outputCostPerMTok float64) *llm.ModelMeta {
+	return &llm.ModelMeta{
+		DisplayName:     displayName,
+		KnowledgeCutoff: knowledgeCutoff,
+		ContextWindow:   contextWindow,
+		MaxOutputTokens: maxOutputTokens,
+		HasReasoning:    hasReasoning,
+
+		InputCostPerMTok:  inputCostPerMTok,
+		OutputCostPerMTok: outputCostPerMTok,
+	}
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// Cross-region inference profiles prefix the model ID with a geography
+var profilePrefixes = []string{"us.", "us-gov.", "eu.", "apac.", "jp.", "au.", "ca.", "global."}
+
+// lookupMeta finds the metadata for a model or inference profile ID
+func lookupMeta(id string) *llm.ModelMeta {
+	if m, ok := meta[id]; ok {
+		return m
+	}
+	for _, prefix := range profilePrefixes {
+		if rest, ok := strings.CutPrefix(id, prefix); ok {
+			return meta[rest]
+		}
+	}
+	return nil
+}
+
+var _ llm.DefaultModeler = (*Client)(nil)
+
+// DefaultModel returns the recommended model. Newer Claude models are only
+// available through inference profiles, so it uses the global one.
+func (c *Client) DefaultModel() string {
+	return "global." + llm.DefaultModelIn(meta)
+}
+
+// Model gets a model or inference profile by ID
+func (c *Client) Model(ctx context.Context, id string) (*llm.Model, error) {
+	m, err := c.bc.GetFoundationModel(ctx, &awsbedrock.GetFoundationModelInput{
+		ModelIdentifier: aws.String(id),
+	})
+	if err == nil && m.ModelDetails != nil {
+		return &llm.Model{
+			Provider: "bedrock",
+			ID:       aws.ToString(m.ModelDetails.ModelId),
+			Meta:     lookupMeta(aws.ToString(m.ModelDetails.ModelId)),
+		}, nil
+	}
+	// Newer Claude models are only reachable through inference profiles
+	p, perr := c.bc.GetInferenceProfile(ctx, &awsbedrock.GetInferenceProfileInput{
+		InferenceProfileIdentifier: aws.String(id),
+	})
+	if perr != nil {
+		if err == nil {
+			err = perr
+		}
+		return nil, fmt.Errorf("bedrock: getting model %q: %w", id, err)
+	}
+	return &llm.Model{
+		Provider: "bedrock",
+		ID:       aws.ToString(p.InferenceProfileId),
+		Meta:     lookupMeta(aws.ToString(p.InferenceProfileId)),
+	}, nil
+}
+
+// Models lists the available text models that support streaming
+func (c *Client) Models(ctx context.Context) (models []*llm.Model, err error) {
+	res, err := c.bc.ListFoundationModels(ctx, &awsbedrock.ListFoundationModelsInput{
+		ByOutputModality: types.ModelModalityText,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bedrock: listing models: %w", err)
+	}
+	for _, summary := range res.ModelSummaries {
+		if !aws.ToBool(summary.ResponseStreamingSupported) {
+			continue
+		}
+		id := aws.ToString(summary.ModelId)
+		models = append(models, &llm.Model{
+			Provider: "bedrock",
+			ID:       id,
+			Meta:     lookupMeta(id),
+		})
+	}
+	return models, nil
+}
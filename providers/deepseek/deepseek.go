@@ -0,0 +1,43 @@
+package deepseek
+
+import (
+	"github.com/matthewmueller/llm"
+	"github.com/matthewmueller/llm/internal/openaicompat"
+	"github.com/openai/openai-go/option"
+)
+
+// baseURL of DeepSeek's OpenAI-compatible API
+const baseURL = "https://api.deepseek.com/v1"
+
+// New creates a new DeepSeek client
+func New(apiKey string) *Client {
+	return newClient(apiKey, baseURL)
+}
+
+func newClient(apiKey, baseURL string) *Client {
+	return &Client{
+		openaicompat.New(&openaicompat.Config{
+			Name:         "deepseek",
+			BaseURL:      baseURL,
+			APIKey:       apiKey,
+			DefaultModel: llm.DefaultModelIn(meta),
+			Meta:         lookupMeta,
+			Reasoning:    reasoning,
+		}),
+	}
+}
+
+// Client implements the llm.Provider interface for DeepSeek. The reasoner
+// streams its chain of thought in reasoning_content, which is returned as
+// thinking.
+type Client struct {
+	*openaicompat.Client
+}
+
+var _ llm.Provider = (*Client)(nil)
+
+// reasoning has no options since deepseek-reasoner always reasons and doesn't
+// accept a reasoning effort
+func reasoning(level llm.Thinking) []option.RequestOption {
+	return nil
+}
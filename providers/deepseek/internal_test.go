@@ -0,0 +1,49 @@
+package deepseek
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func TestReasoningContent(t *testing.T) {
+	is := is.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]json.RawMessage
+		is.NoErr(json.NewDecoder(r.Body).Decode(&req))
+		_, ok := req["reasoning_effort"]
+		is.True(!ok) // deepseek-reasoner doesn't take a reasoning effort
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"choices":[{"index":0,"delta":{"role":"assistant","content":null,"reasoning_content":"2 plus 2"}}]}`,
+			`{"choices":[{"index":0,"delta":{"reasoning_content":" is 4"}}]}`,
+			`{"choices":[{"index":0,"delta":{"content":"4","reasoning_content":null}}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider := newClient("secret", server.URL)
+	var thinking, content strings.Builder
+	for res, err := range provider.Chat(context.Background(), &llm.ChatRequest{
+		Model:    "deepseek-reasoner",
+		Thinking: llm.ThinkingHigh,
+		Messages: []*llm.Message{llm.UserMessage("What is 2+2?")},
+	}) {
+		is.NoErr(err)
+		thinking.WriteString(res.Thinking)
+		content.WriteString(res.Content)
+	}
+	is.Equal(thinking.String(), "2 plus 2 is 4")
+	is.Equal(content.String(), "4")
+}
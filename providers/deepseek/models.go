@@ -0,0 +1,30 @@
+package deepseek
+
+import (
+	"time"
+
+	"github.com/matthewmueller/llm"
+)
+
+// https://api-docs.deepseek.com/quick_start/pricing
+var meta = map[string]*llm.ModelMeta{
+	"deepseek-chat":     model("DeepSeek V3.2", time.Time{}, 128_000, 8_000, false, 0.28, 0.42).AsDefault(),
+	"deepseek-reasoner": model("DeepSeek V3.2 (Thinking)", time.Time{}, 128_000, 64_000, true, 0.28, 0.42),
+}
+
+func model(displayName string, knowledgeCutoff time.Time, contextWindow int, maxOutputTokens int, hasReasoning bool, inputCostPerMTok, outputCostPerMTok float64) *llm.ModelMeta {
+	return &llm.ModelMeta{
+		DisplayName:     displayName,
+		KnowledgeCutoff: knowledgeCutoff,
+		ContextWindow:   contextWindow,
+		MaxOutputTokens: maxOutputTokens,
+		HasReasoning:    hasReasoning,
+
+		InputCostPerMTok:  inputCostPerMTok,
+		OutputCostPerMTok: outputCostPerMTok,
+	}
+}
+
+func lookupMeta(id string) *llm.ModelMeta {
+	return meta[id]
+}
@@ -0,0 +1,38 @@
+package deepseek_test
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm/providers/deepseek"
+)
+
+func TestModels(t *testing.T) {
+	e := loadEnv(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	provider := deepseek.New(e.DeepSeekKey)
+	models, err := provider.Models(ctx)
+	is.NoErr(err)
+	is.True(len(models) > 0)
+
+	for _, m := range models {
+		is.Equal(m.Provider, "deepseek")
+		is.True(m.ID != "")
+	}
+}
+
+func TestModel(t *testing.T) {
+	e := loadEnv(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	provider := deepseek.New(e.DeepSeekKey)
+	m, err := provider.Model(ctx, testModel)
+	is.NoErr(err)
+	is.Equal(m.Provider, "deepseek")
+	is.Equal(m.ID, testModel)
+	is.True(m.Meta != nil)
+	is.Equal(m.Meta.DisplayName, "DeepSeek V3.2")
+}
@@ -4,24 +4,44 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"iter"
-	"log/slog"
+	"net/http"
+	"strings"
 
 	"github.com/matthewmueller/llm"
+	"github.com/matthewmueller/llm/internal/established"
 	"google.golang.org/genai"
 )
 
-// Config for the Gemini provider
-type Config struct {
-	APIKey string
-	Log    *slog.Logger
+// Option configures the client
+type Option func(*config)
+
+type config struct {
+	baseURL string
+}
+
+// WithBaseURL sends requests to another base URL, like a self-hosted gateway
+// or proxy. An empty URL keeps the default.
+func WithBaseURL(url string) Option {
+	return func(c *config) {
+		c.baseURL = url
+	}
 }
 
 // New creates a new Gemini client
-func New(apiKey string) *Client {
+func New(apiKey string, options ...Option) *Client {
+	config := new(config)
+	for _, option := range options {
+		option(config)
+	}
 	gc, _ := genai.NewClient(context.Background(), &genai.ClientConfig{
-		APIKey:  apiKey,
-		Backend: genai.BackendGeminiAPI,
+		APIKey:     apiKey,
+		Backend:    genai.BackendGeminiAPI,
+		HTTPClient: established.Client(http.DefaultClient),
+		HTTPOptions: genai.HTTPOptions{
+			BaseURL: config.baseURL,
+		},
 	})
 	return &Client{
 		gc,
@@ -69,9 +89,12 @@ func toUsage(usage *genai.GenerateContentResponseUsageMetadata) *llm.Usage {
 		total = input + output
 	}
 	return &llm.Usage{
-		InputTokens:  input,
-		OutputTokens: output,
-		TotalTokens:  total,
+		InputTokens:       input,
+		OutputTokens:      output,
+		TotalTokens:       total,
+		CachedInputTokens: int(usage.CachedContentTokenCount),
+		ReasoningTokens:   int(usage.ThoughtsTokenCount),
+		CacheReadTokens:   int(usage.CachedContentTokenCount),
 	}
 }
 
@@ -86,74 +109,203 @@ func toGeminiSchema(prop *llm.ToolProperty) *genai.Schema {
 	if prop.Items != nil {
 		schema.Items = toGeminiSchema(prop.Items)
 	}
+	if len(prop.Properties) > 0 {
+		schema.Properties = make(map[string]*genai.Schema)
+		for name, child := range prop.Properties {
+			schema.Properties[name] = toGeminiSchema(child)
+		}
+		schema.Required = prop.Required
+	}
+	if prop.Minimum != nil {
+		schema.Minimum = prop.Minimum
+	}
+	if prop.Maximum != nil {
+		schema.Maximum = prop.Maximum
+	}
+	if prop.MinLength != nil {
+		n := int64(*prop.MinLength)
+		schema.MinLength = &n
+	}
+	if prop.MaxLength != nil {
+		n := int64(*prop.MaxLength)
+		schema.MaxLength = &n
+	}
+	schema.Pattern = prop.Pattern
+	schema.Default = prop.Default
+	// Gemini's schema subset doesn't support additionalProperties, so map value
+	// types are left open
 	return schema
 }
 
-// Chat sends a chat request to Gemini
-func (c *Client) Chat(ctx context.Context, req *llm.ChatRequest) iter.Seq2[*llm.ChatResponse, error] {
-	return func(yield func(*llm.ChatResponse, error) bool) {
-		// Convert messages to Gemini format
-		var contents []*genai.Content
-		var systemInstruction *genai.Content
+func toGeminiParameters(params *llm.ToolFunctionParameters) *genai.Schema {
+	props := make(map[string]*genai.Schema)
+	for name, prop := range params.Properties {
+		props[name] = toGeminiSchema(prop)
+	}
+	return &genai.Schema{
+		Type:       genai.TypeObject,
+		Properties: props,
+		Required:   params.Required,
+	}
+}
 
-		for _, m := range req.Messages {
-			switch m.Role {
-			case "system":
-				systemInstruction = &genai.Content{
-					Parts: []*genai.Part{{Text: m.Content}},
-					Role:  genai.RoleUser, // System uses user role internally
-				}
-			case "user":
-				contents = append(contents, &genai.Content{
-					Parts: []*genai.Part{{Text: m.Content}},
-					Role:  genai.RoleUser,
+// maxImageSize caps the size of the images we fetch to send inline
+const maxImageSize = 20 << 20
+
+// fetchImage downloads an image to send inline, since Gemini only reads file
+// data from its own Files API and Cloud Storage
+func fetchImage(ctx context.Context, url, mediaType string) (*genai.Blob, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("gemini: fetching image %q: %w", url, err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("gemini: fetching image %q: %w", url, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gemini: fetching image %q: unexpected status %d", url, res.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxImageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("gemini: fetching image %q: %w", url, err)
+	}
+	if len(data) > maxImageSize {
+		return nil, fmt.Errorf("gemini: image %q is over %d bytes", url, maxImageSize)
+	}
+	if mediaType == "" {
+		mediaType = res.Header.Get("Content-Type")
+	}
+	return &genai.Blob{Data: data, MIMEType: mediaType}, nil
+}
+
+// fileURI returns true for URLs Gemini can read as file data itself
+func fileURI(url string) bool {
+	return strings.HasPrefix(url, "gs://") || strings.HasPrefix(url, "https://generativelanguage.googleapis.com/")
+}
+
+// toUserParts converts a user message's content and parts into Gemini parts.
+// Image URLs are fetched and sent inline unless Gemini can read them itself.
+func toUserParts(ctx context.Context, m *llm.Message) (parts []*genai.Part, err error) {
+	if m.Content != "" || len(m.Parts) == 0 {
+		parts = append(parts, &genai.Part{Text: m.Content})
+	}
+	for _, part := range m.Parts {
+		switch part.Type {
+		case "text":
+			parts = append(parts, &genai.Part{Text: part.Text})
+		case "image":
+			if part.URL != "" && fileURI(part.URL) {
+				parts = append(parts, &genai.Part{
+					FileData: &genai.FileData{FileURI: part.URL, MIMEType: part.MediaType},
 				})
-			case "assistant":
-				var parts []*genai.Part
-				if m.Content != "" {
-					parts = append(parts, &genai.Part{Text: m.Content})
+				continue
+			}
+			if part.URL != "" {
+				blob, err := fetchImage(ctx, part.URL, part.MediaType)
+				if err != nil {
+					return nil, err
 				}
-				// Include function call if present
-				if m.ToolCall != nil {
-					var args map[string]any
-					if len(m.ToolCall.Arguments) > 0 {
-						json.Unmarshal(m.ToolCall.Arguments, &args)
-					}
-					part := &genai.Part{
-						FunctionCall: &genai.FunctionCall{
-							Name: m.ToolCall.Name,
-							Args: args,
-						},
-					}
-					if len(m.ToolCall.ThoughtSignature) > 0 {
-						part.ThoughtSignature = m.ToolCall.ThoughtSignature
-					}
-					parts = append(parts, part)
+				parts = append(parts, &genai.Part{InlineData: blob})
+				continue
+			}
+			parts = append(parts, &genai.Part{
+				InlineData: &genai.Blob{Data: part.Data, MIMEType: part.MediaType},
+			})
+		}
+	}
+	return parts, nil
+}
+
+// toContents converts messages into Gemini contents, pulling out the system
+// instruction since Gemini sends it separately. Each system message becomes a
+// part of the instruction.
+func toContents(ctx context.Context, messages []*llm.Message) (contents []*genai.Content, systemInstruction *genai.Content, err error) {
+	for _, m := range messages {
+		switch m.Role {
+		case "system":
+			if systemInstruction == nil {
+				systemInstruction = &genai.Content{
+					Role: genai.RoleUser, // System uses user role internally
 				}
-				if len(parts) > 0 {
-					contents = append(contents, &genai.Content{
-						Parts: parts,
-						Role:  genai.RoleModel,
-					})
+			}
+			systemInstruction.Parts = append(systemInstruction.Parts, &genai.Part{Text: m.Content})
+		case "user":
+			parts, err := toUserParts(ctx, m)
+			if err != nil {
+				return nil, nil, err
+			}
+			contents = append(contents, &genai.Content{
+				Parts: parts,
+				Role:  genai.RoleUser,
+			})
+		case "assistant":
+			var parts []*genai.Part
+			if m.Content != "" {
+				parts = append(parts, &genai.Part{Text: m.Content})
+			}
+			// Include function call if present
+			if m.ToolCall != nil {
+				var args map[string]any
+				if len(m.ToolCall.Arguments) > 0 {
+					json.Unmarshal(m.ToolCall.Arguments, &args)
 				}
-			case "tool":
-				// Tool results as function response
-				// Parse the content as JSON to pass as response data
-				var responseData map[string]any
-				if err := json.Unmarshal([]byte(m.Content), &responseData); err != nil {
-					// If not valid JSON, wrap in a result field
-					responseData = map[string]any{"result": m.Content}
+				part := &genai.Part{
+					FunctionCall: &genai.FunctionCall{
+						Name: m.ToolCall.Name,
+						Args: args,
+					},
 				}
+				if len(m.ToolCall.ThoughtSignature) > 0 {
+					part.ThoughtSignature = m.ToolCall.ThoughtSignature
+				}
+				parts = append(parts, part)
+			}
+			if len(parts) > 0 {
 				contents = append(contents, &genai.Content{
-					Parts: []*genai.Part{{
-						FunctionResponse: &genai.FunctionResponse{
-							Name:     m.ToolCallID, // Gemini uses function name, not call ID
-							Response: responseData,
-						},
-					}},
-					Role: genai.RoleUser,
+					Parts: parts,
+					Role:  genai.RoleModel,
 				})
 			}
+		case "tool":
+			// Tool results as function response
+			// Parse the content as JSON to pass as response data
+			var responseData map[string]any
+			if err := json.Unmarshal([]byte(m.Content), &responseData); err != nil {
+				// If not valid JSON, wrap in a result field
+				responseData = map[string]any{"result": m.Content}
+			}
+			parts := []*genai.Part{{
+				FunctionResponse: &genai.FunctionResponse{
+					Name:     m.ToolCallID, // Gemini uses function name, not call ID
+					Response: responseData,
+				},
+			}}
+			// Images the tool returned follow its response
+			if len(m.Parts) > 0 {
+				images, err := toUserParts(ctx, &llm.Message{Parts: m.Parts})
+				if err != nil {
+					return nil, nil, err
+				}
+				parts = append(parts, images...)
+			}
+			contents = append(contents, &genai.Content{
+				Parts: parts,
+				Role:  genai.RoleUser,
+			})
+		}
+	}
+	return contents, systemInstruction, nil
+}
+
+// Chat sends a chat request to Gemini
+func (c *Client) Chat(ctx context.Context, req *llm.ChatRequest) iter.Seq2[*llm.ChatResponse, error] {
+	return func(yield func(*llm.ChatResponse, error) bool) {
+		contents, systemInstruction, err := toContents(ctx, req.Messages)
+		if err != nil {
+			yield(nil, err)
+			return
 		}
 
 		// Build config
@@ -163,6 +315,11 @@ func (c *Client) Chat(ctx context.Context, req *llm.ChatRequest) iter.Seq2[*llm.
 			config.SystemInstruction = systemInstruction
 		}
 
+		// Merge the provider params into the request body
+		if len(req.ProviderParams) > 0 {
+			config.HTTPOptions = &genai.HTTPOptions{ExtraBody: req.ProviderParams}
+		}
+
 		// Enable thinking if set
 		if budget := thinkingBudget(req.Thinking); budget > 0 {
 			b := int32(budget)
@@ -176,19 +333,10 @@ func (c *Client) Chat(ctx context.Context, req *llm.ChatRequest) iter.Seq2[*llm.
 		if len(req.Tools) > 0 {
 			var funcs []*genai.FunctionDeclaration
 			for _, t := range req.Tools {
-				props := make(map[string]*genai.Schema)
-				for name, prop := range t.Function.Parameters.Properties {
-					props[name] = toGeminiSchema(prop)
-				}
-
 				funcs = append(funcs, &genai.FunctionDeclaration{
 					Name:        t.Function.Name,
 					Description: t.Function.Description,
-					Parameters: &genai.Schema{
-						Type:       genai.TypeObject,
-						Properties: props,
-						Required:   t.Function.Parameters.Required,
-					},
+					Parameters:  toGeminiParameters(t.Function.Parameters),
 				})
 			}
 
@@ -197,74 +345,193 @@ func (c *Client) Chat(ctx context.Context, req *llm.ChatRequest) iter.Seq2[*llm.
 			}
 		}
 
-		// Stream response
-		stream := c.gc.Models.GenerateContentStream(ctx, req.Model, contents, config)
+		switch req.ToolChoice.Mode {
+		case "auto":
+			config.ToolConfig = toolConfig(genai.FunctionCallingConfigModeAuto)
+		case "none":
+			config.ToolConfig = toolConfig(genai.FunctionCallingConfigModeNone)
+		case "required":
+			config.ToolConfig = toolConfig(genai.FunctionCallingConfigModeAny)
+		case "tool":
+			config.ToolConfig = toolConfig(genai.FunctionCallingConfigModeAny, req.ToolChoice.Name)
+		}
+
+		if len(req.StopSequences) > 0 {
+			config.StopSequences = req.StopSequences
+		}
 
+		if req.MaxOutputTokens > 0 {
+			config.MaxOutputTokens = int32(lookupMeta(req.Model).ClampOutputTokens(req.MaxOutputTokens))
+		}
+
+		// Constrain the output to the response schema
+		if req.ResponseSchema != nil {
+			config.ResponseMIMEType = "application/json"
+			config.ResponseSchema = toGeminiParameters(req.ResponseSchema)
+		}
+
+		// Start the response as soon as the stream connects, since the SDK only
+		// surfaces the stream with its first event
+		connected, stopped := false, false
+		streamCtx := established.Context(ctx, func() error {
+			connected = true
+			if !yield(&llm.ChatResponse{Role: "assistant", Start: true}, nil) {
+				stopped = true
+				return context.Canceled
+			}
+			return nil
+		})
+
+		// Stream response
+		stream := c.gc.Models.GenerateContentStream(streamCtx, req.Model, contents, config)
 		for resp, err := range stream {
+			if stopped {
+				return
+			}
 			if err != nil {
+				if !connected && req.StreamFallback {
+					c.fallback(ctx, req, contents, config, yield)
+					return
+				}
 				yield(nil, fmt.Errorf("gemini: streaming: %w", err))
 				return
 			}
-			usage := toUsage(resp.UsageMetadata)
+			req.Raw(resp)
+			if !emit(resp, yield) {
+				return
+			}
+		}
+	}
+}
+
+// toolConfig restricts function calling to the mode and allowed functions
+func toolConfig(mode genai.FunctionCallingConfigMode, allowed ...string) *genai.ToolConfig {
+	return &genai.ToolConfig{
+		FunctionCallingConfig: &genai.FunctionCallingConfig{
+			Mode:                 mode,
+			AllowedFunctionNames: allowed,
+		},
+	}
+}
+
+var _ llm.TokenCounter = (*Client)(nil)
+
+// CountTokens counts the input tokens in a chat request. The Gemini API
+// doesn't count system instructions or tools separately, so the system
+// instruction is counted as a leading user turn and tools aren't counted.
+func (c *Client) CountTokens(ctx context.Context, req *llm.ChatRequest) (int, error) {
+	contents, systemInstruction, err := toContents(ctx, req.Messages)
+	if err != nil {
+		return 0, err
+	}
+	if systemInstruction != nil {
+		contents = append([]*genai.Content{systemInstruction}, contents...)
+	}
+	res, err := c.gc.Models.CountTokens(ctx, req.Model, contents, nil)
+	if err != nil {
+		return 0, fmt.Errorf("gemini: counting tokens: %w", err)
+	}
+	return int(res.TotalTokens), nil
+}
+
+var _ llm.Embedder = (*Client)(nil)
+
+// Embed embeds each input as a vector
+func (c *Client) Embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	contents := make([]*genai.Content, len(inputs))
+	for i, input := range inputs {
+		contents[i] = genai.NewContentFromText(input, genai.RoleUser)
+	}
+	res, err := c.gc.Models.EmbedContent(ctx, model, contents, nil)
+	if err != nil {
+		return nil, fmt.Errorf("gemini: embedding: %w", err)
+	}
+	if len(res.Embeddings) != len(inputs) {
+		return nil, fmt.Errorf("gemini: expected %d embeddings but got %d", len(inputs), len(res.Embeddings))
+	}
+	vectors := make([][]float32, len(res.Embeddings))
+	for i, embedding := range res.Embeddings {
+		vectors[i] = embedding.Values
+	}
+	return vectors, nil
+}
+
+// fallback sends a non-streaming request and synthesizes the stream events
+func (c *Client) fallback(ctx context.Context, req *llm.ChatRequest, contents []*genai.Content, config *genai.GenerateContentConfig, yield func(*llm.ChatResponse, error) bool) {
+	resp, err := c.gc.Models.GenerateContent(ctx, req.Model, contents, config)
+	if err != nil {
+		yield(nil, fmt.Errorf("gemini: non-streaming fallback: %w", err))
+		return
+	}
+	req.Raw(resp)
+	if !yield(&llm.ChatResponse{Role: "assistant", Start: true}, nil) {
+		return
+	}
+	emit(resp, yield)
+}
+
+// emit yields the chat responses for a single Gemini response. Returns false
+// if the caller should stop.
+func emit(resp *genai.GenerateContentResponse, yield func(*llm.ChatResponse, error) bool) bool {
+	usage := toUsage(resp.UsageMetadata)
+
+	for _, candidate := range resp.Candidates {
+		if candidate.Content == nil {
+			continue
+		}
+
+		var lastThoughtSignature []byte
+
+		for _, part := range candidate.Content.Parts {
+			chatResp := &llm.ChatResponse{
+				Role:  "assistant",
+				Usage: usage,
+			}
+
+			// Handle text content
+			if part.Text != "" {
+				chatResp.Content = part.Text
+			}
+
+			// Handle thinking content (for thinking models)
+			if part.Thought {
+				chatResp.Thinking = part.Text
+				chatResp.Content = "" // Move to thinking
+				if len(part.ThoughtSignature) > 0 {
+					lastThoughtSignature = part.ThoughtSignature
+				}
+			}
 
-			for _, candidate := range resp.Candidates {
-				if candidate.Content == nil {
-					continue
+			// Handle function calls
+			if part.FunctionCall != nil {
+				args, err := json.Marshal(part.FunctionCall.Args)
+				if err != nil {
+					yield(nil, fmt.Errorf("gemini: marshaling function args: %w", err))
+					return false
+				}
+				thoughtSignature := part.ThoughtSignature
+				if len(thoughtSignature) == 0 {
+					thoughtSignature = lastThoughtSignature
+				}
+				chatResp.ToolCall = &llm.ToolCall{
+					ID:               part.FunctionCall.Name, // Gemini uses function name for correlation
+					Name:             part.FunctionCall.Name,
+					Arguments:        args,
+					ThoughtSignature: thoughtSignature,
 				}
+			}
+
+			// Check finish reason
+			if candidate.FinishReason != "" {
+				chatResp.Done = true
+			}
 
-				var lastThoughtSignature []byte
-
-				for _, part := range candidate.Content.Parts {
-					chatResp := &llm.ChatResponse{
-						Role:  "assistant",
-						Usage: usage,
-					}
-
-					// Handle text content
-					if part.Text != "" {
-						chatResp.Content = part.Text
-					}
-
-					// Handle thinking content (for thinking models)
-					if part.Thought {
-						chatResp.Thinking = part.Text
-						chatResp.Content = "" // Move to thinking
-						if len(part.ThoughtSignature) > 0 {
-							lastThoughtSignature = part.ThoughtSignature
-						}
-					}
-
-					// Handle function calls
-					if part.FunctionCall != nil {
-						args, err := json.Marshal(part.FunctionCall.Args)
-						if err != nil {
-							yield(nil, fmt.Errorf("gemini: marshaling function args: %w", err))
-							return
-						}
-						thoughtSignature := part.ThoughtSignature
-						if len(thoughtSignature) == 0 {
-							thoughtSignature = lastThoughtSignature
-						}
-						chatResp.ToolCall = &llm.ToolCall{
-							ID:               part.FunctionCall.Name, // Gemini uses function name for correlation
-							Name:             part.FunctionCall.Name,
-							Arguments:        args,
-							ThoughtSignature: thoughtSignature,
-						}
-					}
-
-					// Check finish reason
-					if candidate.FinishReason != "" {
-						chatResp.Done = true
-					}
-
-					if chatResp.Content != "" || chatResp.Thinking != "" || chatResp.ToolCall != nil || chatResp.Done {
-						if !yield(chatResp, nil) {
-							return
-						}
-					}
+			if chatResp.Content != "" || chatResp.Thinking != "" || chatResp.ToolCall != nil || chatResp.Done {
+				if !yield(chatResp, nil) {
+					return false
 				}
 			}
 		}
 	}
+	return true
 }
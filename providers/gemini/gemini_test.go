@@ -201,3 +201,85 @@ func TestToolFailOnce(t *testing.T) {
 	}
 	is.True(strings.Contains(content.String(), "noodles"))
 }
+
+func TestStopSequences(t *testing.T) {
+	e := loadEnv(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	provider := gemini.New(e.GeminiKey)
+	client := llm.New(provider)
+	var content strings.Builder
+	done := false
+	for event, err := range client.Chat(ctx,
+		provider.Name(),
+		llm.WithModel(testModel),
+		llm.WithThinking(llm.ThinkingNone),
+		llm.WithMessage(llm.UserMessage("Count from 1 to 10, separated by commas. Reply with just the numbers.")),
+		llm.WithStopSequences("3"),
+	) {
+		is.NoErr(err)
+		content.WriteString(event.Content)
+		done = done || event.Done
+	}
+	is.True(done)
+	is.True(strings.Contains(content.String(), "2"))
+	is.True(!strings.Contains(content.String(), "3"))
+}
+
+func TestToolChoiceNone(t *testing.T) {
+	e := loadEnv(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	provider := gemini.New(e.GeminiKey)
+	client := llm.New(provider)
+	for event, err := range client.Chat(ctx,
+		provider.Name(),
+		llm.WithModel(testModel),
+		llm.WithMessage(llm.UserMessage("Use the add tool to add 17 and 25.")),
+		llm.WithTool(addTool),
+		llm.WithToolChoice(llm.ToolChoiceNone),
+	) {
+		is.NoErr(err)
+		is.Equal(event.ToolCall, nil)
+	}
+}
+
+func TestToolChoiceTool(t *testing.T) {
+	e := loadEnv(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	provider := gemini.New(e.GeminiKey)
+	client := llm.New(provider)
+	var calls []string
+	for event, err := range client.Chat(ctx,
+		provider.Name(),
+		llm.WithModel(testModel),
+		llm.WithMessage(llm.UserMessage("What is 6 times 7?")),
+		llm.WithTool(addTool, multiplyTool),
+		llm.WithToolChoice(llm.ToolChoiceTool("add")),
+	) {
+		is.NoErr(err)
+		if event.ToolCall != nil {
+			calls = append(calls, event.ToolCall.Name)
+		}
+	}
+	is.True(len(calls) > 0)
+	is.Equal(calls[0], "add")
+}
+
+func TestEmbed(t *testing.T) {
+	e := loadEnv(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	provider := gemini.New(e.GeminiKey)
+	client := llm.New(provider)
+	vectors, err := client.Embed(ctx, provider.Name(), "gemini-embedding-001", "hello world", "goodbye world")
+	is.NoErr(err)
+	is.Equal(len(vectors), 2)
+	is.Equal(len(vectors[0]), 3072)
+	is.Equal(len(vectors[1]), 3072)
+}
@@ -0,0 +1,181 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+	"google.golang.org/genai"
+)
+
+func TestToUsage(t *testing.T) {
+	is := is.New(t)
+	data, err := os.ReadFile("testdata/usage.json")
+	is.NoErr(err)
+	usage := new(genai.GenerateContentResponseUsageMetadata)
+	is.NoErr(json.Unmarshal(data, usage))
+	is.Equal(toUsage(usage), &llm.Usage{
+		InputTokens:       1850,
+		OutputTokens:      636,
+		TotalTokens:       2486,
+		CachedInputTokens: 1024,
+		ReasoningTokens:   540,
+		CacheReadTokens:   1024,
+	})
+}
+
+func TestStreamFallback(t *testing.T) {
+	is := is.New(t)
+	fallback, err := os.ReadFile("testdata/fallback.json")
+	is.NoErr(err)
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		// A proxy that blocks streaming
+		if strings.HasSuffix(r.URL.Path, ":streamGenerateContent") {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"code":403,"message":"streaming is blocked","status":"PERMISSION_DENIED"}}`))
+			return
+		}
+		w.Write(fallback)
+	}))
+	defer server.Close()
+
+	client := New("secret", WithBaseURL(server.URL))
+	var responses []*llm.ChatResponse
+	for res, err := range client.Chat(context.Background(), &llm.ChatRequest{
+		Model:          "gemini-2.5-flash",
+		Messages:       []*llm.Message{llm.UserMessage("What is 20+22?")},
+		StreamFallback: true,
+	}) {
+		is.NoErr(err)
+		responses = append(responses, res)
+	}
+	is.Equal(paths, []string{
+		"/v1beta/models/gemini-2.5-flash:streamGenerateContent",
+		"/v1beta/models/gemini-2.5-flash:generateContent",
+	})
+	var thinking, content string
+	var call *llm.ToolCall
+	var done *llm.ChatResponse
+	for _, res := range responses {
+		thinking += res.Thinking
+		content += res.Content
+		if res.ToolCall != nil {
+			call = res.ToolCall
+		}
+		if res.Done {
+			done = res
+		}
+	}
+	is.True(responses[0].Start)
+	is.Equal(thinking, "Adding the numbers")
+	is.Equal(content, "Let me add those")
+	is.Equal(call.Name, "add")
+	is.Equal(string(call.Arguments), `{"a":20,"b":22}`)
+	is.Equal(done.Usage.TotalTokens, 20)
+}
+
+func TestStartEvent(t *testing.T) {
+	is := is.New(t)
+	started := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		// Hold the first chunk back until the client has started
+		<-started
+		w.Write([]byte("data: " + `{"candidates":[{"content":{"role":"model","parts":[{"text":"42"}]},"finishReason":"STOP"}]}` + "\n\n"))
+	}))
+	defer server.Close()
+
+	client := New("secret", WithBaseURL(server.URL))
+	var responses []*llm.ChatResponse
+	for res, err := range client.Chat(context.Background(), &llm.ChatRequest{
+		Model:    "gemini-2.5-flash",
+		Messages: []*llm.Message{llm.UserMessage("What is 20+22?")},
+	}) {
+		is.NoErr(err)
+		if len(responses) == 0 {
+			is.True(res.Start)
+			close(started)
+		}
+		responses = append(responses, res)
+	}
+	is.True(len(responses) > 1)
+	is.Equal(responses[1].Content, "42")
+}
+
+func TestImages(t *testing.T) {
+	is := is.New(t)
+	var body struct {
+		Contents []any `json:"contents"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/cat.jpg" {
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write([]byte("jpg"))
+			return
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: " + `{"candidates":[{"content":{"role":"model","parts":[{"text":"Cats"}]},"finishReason":"STOP"}]}` + "\n\n"))
+	}))
+	defer server.Close()
+
+	message := llm.UserMessage("What's in these images?")
+	message.Parts = []*llm.ContentPart{
+		llm.ImagePart("image/png", []byte("png")),
+		llm.ImageURLPart("", server.URL+"/cat.jpg"),
+		llm.ImageURLPart("image/jpeg", "gs://bucket/cat.jpg"),
+	}
+	client := New("secret", WithBaseURL(server.URL))
+	for _, err := range client.Chat(context.Background(), &llm.ChatRequest{
+		Model:    "gemini-2.5-flash",
+		Messages: []*llm.Message{message},
+	}) {
+		is.NoErr(err)
+	}
+
+	// Image URLs are fetched and sent inline, unless Gemini can read them
+	fixture, err := os.ReadFile("testdata/image.json")
+	is.NoErr(err)
+	var expect []any
+	is.NoErr(json.Unmarshal(fixture, &expect))
+	is.Equal(body.Contents, expect)
+}
+
+func TestImageNotFound(t *testing.T) {
+	is := is.New(t)
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	message := llm.UserMessage("What's in this image?")
+	message.Parts = []*llm.ContentPart{llm.ImageURLPart("image/jpeg", server.URL+"/cat.jpg")}
+	_, _, err := toContents(context.Background(), []*llm.Message{message})
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "unexpected status 404"))
+}
+
+func TestToolResultImages(t *testing.T) {
+	is := is.New(t)
+	contents, _, err := toContents(context.Background(), []*llm.Message{
+		llm.UserMessage("What's in dot.png?"),
+		{Role: "assistant", ToolCall: &llm.ToolCall{ID: "read", Name: "read", Arguments: []byte(`{"path":"dot.png"}`)}},
+		{Role: "tool", ToolCallID: "read", Content: `{"path":"dot.png"}`, Parts: []*llm.ContentPart{llm.ImagePart("image/png", []byte("png"))}},
+	})
+	is.NoErr(err)
+	is.Equal(len(contents), 3)
+	parts := contents[2].Parts
+	is.Equal(len(parts), 2)
+	is.Equal(parts[0].FunctionResponse.Response["path"], "dot.png")
+	is.Equal(parts[1].InlineData.MIMEType, "image/png")
+	is.Equal(parts[1].InlineData.Data, []byte("png"))
+}
@@ -10,46 +10,51 @@ import (
 )
 
 // https://ai.google.dev/gemini-api/docs/models
+// https://ai.google.dev/gemini-api/docs/pricing
+// Prices are for prompts up to 200k tokens and text output
 var meta = map[string]*llm.ModelMeta{
 	// Gemini 3 (preview)
-	"gemini-3-pro-preview":       model("Gemini 3 Pro Preview", date(2025, time.January, 31), 1_048_576, 65_536, true),
-	"gemini-3-pro-image-preview": model("Gemini 3 Pro Image Preview", date(2025, time.January, 31), 65_536, 32_768, true),
-	"gemini-3-flash-preview":     model("Gemini 3 Flash Preview", date(2025, time.January, 31), 1_048_576, 65_536, true),
+	"gemini-3-pro-preview":       model("Gemini 3 Pro Preview", date(2025, time.January, 31), 1_048_576, 65_536, true, 2, 12),
+	"gemini-3-pro-image-preview": model("Gemini 3 Pro Image Preview", date(2025, time.January, 31), 65_536, 32_768, true, 2, 12),
+	"gemini-3-flash-preview":     model("Gemini 3 Flash Preview", date(2025, time.January, 31), 1_048_576, 65_536, true, 0.5, 3),
 
 	// Gemini 2.5 Flash
-	"gemini-2.5-flash":                              model("Gemini 2.5 Flash", date(2025, time.January, 31), 1_048_576, 65_536, true),
-	"gemini-2.5-flash-preview-09-2025":              model("Gemini 2.5 Flash Preview", date(2025, time.January, 31), 1_048_576, 65_536, true),
-	"gemini-2.5-flash-image":                        model("Gemini 2.5 Flash Image", date(2025, time.June, 30), 65_536, 32_768, false),
-	"gemini-2.5-flash-image-preview":                model("Gemini 2.5 Flash Image Preview", date(2025, time.June, 30), 65_536, 32_768, false),
-	"gemini-2.5-flash-native-audio-preview-12-2025": model("Gemini 2.5 Flash Live", date(2025, time.January, 31), 131_072, 8_192, true),
-	"gemini-2.5-flash-native-audio-preview-09-2025": model("Gemini 2.5 Flash Live Preview", date(2025, time.January, 31), 131_072, 8_192, true),
+	"gemini-2.5-flash":                              model("Gemini 2.5 Flash", date(2025, time.January, 31), 1_048_576, 65_536, true, 0.3, 2.5).AsDefault(),
+	"gemini-2.5-flash-preview-09-2025":              model("Gemini 2.5 Flash Preview", date(2025, time.January, 31), 1_048_576, 65_536, true, 0.3, 2.5),
+	"gemini-2.5-flash-image":                        model("Gemini 2.5 Flash Image", date(2025, time.June, 30), 65_536, 32_768, false, 0.3, 2.5),
+	"gemini-2.5-flash-image-preview":                model("Gemini 2.5 Flash Image Preview", date(2025, time.June, 30), 65_536, 32_768, false, 0.3, 2.5),
+	"gemini-2.5-flash-native-audio-preview-12-2025": model("Gemini 2.5 Flash Live", date(2025, time.January, 31), 131_072, 8_192, true, 0.5, 2),
+	"gemini-2.5-flash-native-audio-preview-09-2025": model("Gemini 2.5 Flash Live Preview", date(2025, time.January, 31), 131_072, 8_192, true, 0.5, 2),
 	// Google does not list a knowledge cutoff in this table row.
-	"gemini-2.5-flash-preview-tts": model("Gemini 2.5 Flash TTS", time.Time{}, 8_192, 16_384, false),
+	"gemini-2.5-flash-preview-tts": model("Gemini 2.5 Flash TTS", time.Time{}, 8_192, 16_384, false, 0.5, 10),
 
 	// Gemini 2.5 Flash-Lite
-	"gemini-2.5-flash-lite":                 model("Gemini 2.5 Flash-Lite", date(2025, time.January, 31), 1_048_576, 65_536, true),
-	"gemini-2.5-flash-lite-preview-09-2025": model("Gemini 2.5 Flash-Lite Preview", date(2025, time.January, 31), 1_048_576, 65_536, true),
+	"gemini-2.5-flash-lite":                 model("Gemini 2.5 Flash-Lite", date(2025, time.January, 31), 1_048_576, 65_536, true, 0.1, 0.4),
+	"gemini-2.5-flash-lite-preview-09-2025": model("Gemini 2.5 Flash-Lite Preview", date(2025, time.January, 31), 1_048_576, 65_536, true, 0.1, 0.4),
 
 	// Gemini 2.5 Pro
-	"gemini-2.5-pro": model("Gemini 2.5 Pro", date(2025, time.January, 31), 1_048_576, 65_536, true),
+	"gemini-2.5-pro": model("Gemini 2.5 Pro", date(2025, time.January, 31), 1_048_576, 65_536, true, 1.25, 10),
 	// Google does not list a knowledge cutoff in this table row.
-	"gemini-2.5-pro-preview-tts": model("Gemini 2.5 Pro TTS", time.Time{}, 8_192, 16_384, false),
+	"gemini-2.5-pro-preview-tts": model("Gemini 2.5 Pro TTS", time.Time{}, 8_192, 16_384, false, 1, 20),
 
 	// Gemini 2.0
-	"gemini-2.0-flash":          model("Gemini 2.0 Flash", date(2024, time.August, 31), 1_048_576, 8_192, true), // Thinking is marked experimental.
-	"gemini-2.0-flash-001":      model("Gemini 2.0 Flash", date(2024, time.August, 31), 1_048_576, 8_192, true),
-	"gemini-2.0-flash-exp":      model("Gemini 2.0 Flash Experimental", date(2024, time.August, 31), 1_048_576, 8_192, true),
-	"gemini-2.0-flash-lite":     model("Gemini 2.0 Flash-Lite", date(2024, time.August, 31), 1_048_576, 8_192, false),
-	"gemini-2.0-flash-lite-001": model("Gemini 2.0 Flash-Lite", date(2024, time.August, 31), 1_048_576, 8_192, false),
+	"gemini-2.0-flash":          model("Gemini 2.0 Flash", date(2024, time.August, 31), 1_048_576, 8_192, true, 0.1, 0.4), // Thinking is marked experimental.
+	"gemini-2.0-flash-001":      model("Gemini 2.0 Flash", date(2024, time.August, 31), 1_048_576, 8_192, true, 0.1, 0.4),
+	"gemini-2.0-flash-exp":      model("Gemini 2.0 Flash Experimental", date(2024, time.August, 31), 1_048_576, 8_192, true, 0, 0),
+	"gemini-2.0-flash-lite":     model("Gemini 2.0 Flash-Lite", date(2024, time.August, 31), 1_048_576, 8_192, false, 0.075, 0.3),
+	"gemini-2.0-flash-lite-001": model("Gemini 2.0 Flash-Lite", date(2024, time.August, 31), 1_048_576, 8_192, false, 0.075, 0.3),
 }
 
-func model(displayName string, knowledgeCutoff time.Time, contextWindow int, maxOutputTokens int, hasReasoning bool) *llm.ModelMeta {
+func model(displayName string, knowledgeCutoff time.Time, contextWindow int, maxOutputTokens int, hasReasoning bool, inputCostPerMTok, outputCostPerMTok float64) *llm.ModelMeta {
 	return &llm.ModelMeta{
 		DisplayName:     displayName,
 		KnowledgeCutoff: knowledgeCutoff,
 		ContextWindow:   contextWindow,
 		MaxOutputTokens: maxOutputTokens,
 		HasReasoning:    hasReasoning,
+
+		InputCostPerMTok:  inputCostPerMTok,
+		OutputCostPerMTok: outputCostPerMTok,
 	}
 }
 
@@ -67,6 +72,13 @@ func lookupMeta(id string) *llm.ModelMeta {
 	return nil
 }
 
+var _ llm.DefaultModeler = (*Client)(nil)
+
+// DefaultModel returns the recommended model
+func (c *Client) DefaultModel() string {
+	return llm.DefaultModelIn(meta)
+}
+
 // Model retrieves a specific model
 func (c *Client) Model(ctx context.Context, id string) (*llm.Model, error) {
 	m, err := c.gc.Models.Get(ctx, id, nil)
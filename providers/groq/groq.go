@@ -0,0 +1,29 @@
+package groq
+
+import (
+	"github.com/matthewmueller/llm"
+	"github.com/matthewmueller/llm/internal/openaicompat"
+)
+
+// baseURL of Groq's OpenAI-compatible API
+const baseURL = "https://api.groq.com/openai/v1"
+
+// New creates a new Groq client
+func New(apiKey string) *Client {
+	return &Client{
+		openaicompat.New(&openaicompat.Config{
+			Name:         "groq",
+			BaseURL:      baseURL,
+			APIKey:       apiKey,
+			DefaultModel: llm.DefaultModelIn(meta),
+			Meta:         lookupMeta,
+		}),
+	}
+}
+
+// Client implements the llm.Provider interface for Groq
+type Client struct {
+	*openaicompat.Client
+}
+
+var _ llm.Provider = (*Client)(nil)
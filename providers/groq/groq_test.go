@@ -0,0 +1,114 @@
+package groq_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+	"github.com/matthewmueller/llm/internal/env"
+	"github.com/matthewmueller/llm/providers/groq"
+)
+
+const testModel = `llama-3.3-70b-versatile`
+
+func loadEnv(t *testing.T) *env.Env {
+	t.Helper()
+	e, err := env.Load()
+	if err != nil {
+		t.Fatalf("groq: loading env: %v", err)
+	}
+	if e.GroqKey == "" {
+		t.Fatal("GROQ_API_KEY not set")
+	}
+	return e
+}
+
+func testContext(t *testing.T) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestSimpleChat(t *testing.T) {
+	e := loadEnv(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	provider := groq.New(e.GroqKey)
+	client := llm.New(provider)
+	var content strings.Builder
+	for event, err := range client.Chat(ctx,
+		provider.Name(),
+		llm.WithModel(testModel),
+		llm.WithMessage(llm.UserMessage("What is 2+2? Reply with just the number.")),
+	) {
+		is.NoErr(err)
+		content.WriteString(event.Content)
+	}
+	is.True(strings.Contains(content.String(), "4"))
+}
+
+var addTool = llm.Func("add", "Add two numbers together", func(ctx context.Context, in struct {
+	A int `json:"a" description:"First number" is:"required"`
+	B int `json:"b" description:"Second number" is:"required"`
+}) (int, error) {
+	return in.A + in.B, nil
+})
+
+var multiplyTool = llm.Func("multiply", "Multiply two numbers together", func(ctx context.Context, in struct {
+	A int `json:"a" description:"First number" is:"required"`
+	B int `json:"b" description:"Second number" is:"required"`
+}) (int, error) {
+	return in.A * in.B, nil
+})
+
+func TestToolSingleCall(t *testing.T) {
+	e := loadEnv(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	provider := groq.New(e.GroqKey)
+	lc := llm.New(provider)
+
+	content := new(strings.Builder)
+	for event, err := range lc.Chat(ctx,
+		provider.Name(),
+		llm.WithModel(testModel),
+		llm.WithMessage(llm.UserMessage("Use the multiply tool to multiply 6 and 7, then tell me the result.")),
+		llm.WithTool(multiplyTool),
+	) {
+		is.NoErr(err)
+		content.WriteString(event.Content)
+	}
+	is.True(strings.Contains(content.String(), "42"))
+}
+
+func TestToolMultipleParallel(t *testing.T) {
+	e := loadEnv(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	provider := groq.New(e.GroqKey)
+	client := llm.New(provider)
+
+	content := new(strings.Builder)
+	for res, err := range client.Chat(ctx,
+		provider.Name(),
+		llm.WithModel(testModel),
+		llm.WithMessage(
+			llm.UserMessage("Write a short poem and then call the add tool to add 10 and 5, and the multiply tool to multiply 3 and 4. Give me both results."),
+		),
+		llm.WithTool(addTool),
+		llm.WithTool(multiplyTool),
+	) {
+		is.NoErr(err)
+		content.WriteString(res.Content)
+	}
+
+	is.True(strings.Contains(content.String(), "15"))
+	is.True(strings.Contains(content.String(), "12"))
+}
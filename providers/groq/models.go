@@ -0,0 +1,45 @@
+package groq
+
+import (
+	"time"
+
+	"github.com/matthewmueller/llm"
+)
+
+// https://console.groq.com/docs/models
+// https://groq.com/pricing
+var meta = map[string]*llm.ModelMeta{
+	// Llama
+	"llama-3.1-8b-instant":                          model("Llama 3.1 8B", date(2023, time.December, 31), 131_072, 131_072, false, 0.05, 0.08),
+	"llama-3.3-70b-versatile":                       model("Llama 3.3 70B", date(2023, time.December, 31), 131_072, 32_768, false, 0.59, 0.79).AsDefault(),
+	"meta-llama/llama-4-scout-17b-16e-instruct":     model("Llama 4 Scout", date(2024, time.August, 31), 131_072, 8_192, false, 0.11, 0.34),
+	"meta-llama/llama-4-maverick-17b-128e-instruct": model("Llama 4 Maverick", date(2024, time.August, 31), 131_072, 8_192, false, 0.20, 0.60),
+
+	// Mixtral
+	"mixtral-8x7b-32768": model("Mixtral 8x7B", time.Time{}, 32_768, 32_768, false, 0.24, 0.24),
+
+	// GPT-OSS
+	"openai/gpt-oss-20b":  model("GPT-OSS 20B", date(2024, time.June, 30), 131_072, 65_536, true, 0.075, 0.30),
+	"openai/gpt-oss-120b": model("GPT-OSS 120B", date(2024, time.June, 30), 131_072, 65_536, true, 0.15, 0.60),
+}
+
+func model(displayName string, knowledgeCutoff time.Time, contextWindow int, maxOutputTokens int, hasReasoning bool, inputCostPerMTok, outputCostPerMTok float64) *llm.ModelMeta {
+	return &llm.ModelMeta{
+		DisplayName:     displayName,
+		KnowledgeCutoff: knowledgeCutoff,
+		ContextWindow:   contextWindow,
+		MaxOutputTokens: maxOutputTokens,
+		HasReasoning:    hasReasoning,
+
+		InputCostPerMTok:  inputCostPerMTok,
+		OutputCostPerMTok: outputCostPerMTok,
+	}
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func lookupMeta(id string) *llm.ModelMeta {
+	return meta[id]
+}
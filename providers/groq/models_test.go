@@ -0,0 +1,38 @@
+package groq_test
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm/providers/groq"
+)
+
+func TestModels(t *testing.T) {
+	e := loadEnv(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	provider := groq.New(e.GroqKey)
+	models, err := provider.Models(ctx)
+	is.NoErr(err)
+	is.True(len(models) > 0)
+
+	for _, m := range models {
+		is.Equal(m.Provider, "groq")
+		is.True(m.ID != "")
+	}
+}
+
+func TestModel(t *testing.T) {
+	e := loadEnv(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	provider := groq.New(e.GroqKey)
+	m, err := provider.Model(ctx, testModel)
+	is.NoErr(err)
+	is.Equal(m.Provider, "groq")
+	is.Equal(m.ID, testModel)
+	is.True(m.Meta != nil)
+	is.Equal(m.Meta.DisplayName, "Llama 3.3 70B")
+}
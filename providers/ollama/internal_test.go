@@ -0,0 +1,24 @@
+package ollama
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+	ollama "github.com/ollama/ollama/api"
+)
+
+func TestToUsage(t *testing.T) {
+	is := is.New(t)
+	data, err := os.ReadFile("testdata/usage.json")
+	is.NoErr(err)
+	var res ollama.ChatResponse
+	is.NoErr(json.Unmarshal(data, &res))
+	is.Equal(toUsage(res), &llm.Usage{
+		InputTokens:  58,
+		OutputTokens: 211,
+		TotalTokens:  269,
+	})
+}
@@ -11,20 +11,31 @@ import (
 
 // https://llm-stats.com/
 // TODO: figure out a good way to keep this up to date
+// Models run locally, so they're free
 var meta = map[string]*llm.ModelMeta{
-	"glm-4.7-flash:latest": model("GLM-4.7-Flash", time.Time{}, 128_000, 0, true),
+	"glm-4.7-flash:latest": model("GLM-4.7-Flash", time.Time{}, 128_000, 0, true, 0, 0).AsDefault(),
 }
 
-func model(displayName string, knowledgeCutoff time.Time, contextWindow int, maxOutputTokens int, hasReasoning bool) *llm.ModelMeta {
+func model(displayName string, knowledgeCutoff time.Time, contextWindow int, maxOutputTokens int, hasReasoning bool, inputCostPerMTok, outputCostPerMTok float64) *llm.ModelMeta {
 	return &llm.ModelMeta{
 		DisplayName:     displayName,
 		KnowledgeCutoff: knowledgeCutoff,
 		ContextWindow:   contextWindow,
 		MaxOutputTokens: maxOutputTokens,
 		HasReasoning:    hasReasoning,
+
+		InputCostPerMTok:  inputCostPerMTok,
+		OutputCostPerMTok: outputCostPerMTok,
 	}
 }
 
+var _ llm.DefaultModeler = (*Client)(nil)
+
+// DefaultModel returns a model to try. It has to be pulled first.
+func (c *Client) DefaultModel() string {
+	return llm.DefaultModelIn(meta)
+}
+
 // Model retrieves a specific model
 func (c *Client) Model(ctx context.Context, id string) (*llm.Model, error) {
 	_, err := c.oc.Show(ctx, &ollama.ShowRequest{Model: id})
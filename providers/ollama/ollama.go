@@ -10,27 +10,68 @@ import (
 	"time"
 
 	"github.com/matthewmueller/llm"
+	"github.com/matthewmueller/llm/internal/established"
 	ollama "github.com/ollama/ollama/api"
 )
 
-func Default() *Client {
+// Default creates a client for Ollama running locally
+func Default(options ...Option) *Client {
 	return New(&url.URL{
 		Scheme: "http",
 		Host:   "localhost:11434",
-	})
+	}, options...)
+}
+
+// Option configures the client
+type Option func(*config)
+
+type config struct {
+	options   map[string]any
+	keepAlive time.Duration
+}
+
+// WithOptions sets runtime options like num_ctx or temperature on every
+// request, on top of Ollama's defaults. Options a request sets itself, like
+// stop sequences or the output token limit, still win.
+func WithOptions(options map[string]any) Option {
+	return func(c *config) {
+		for key, value := range options {
+			c.options[key] = value
+		}
+	}
+}
+
+// WithKeepAlive sets how long the model stays loaded after a request. Zero
+// unloads it right away and a negative duration keeps it loaded. Defaults to
+// 30 seconds.
+func WithKeepAlive(keepAlive time.Duration) Option {
+	return func(c *config) {
+		c.keepAlive = keepAlive
+	}
 }
 
 // New creates a new Ollama client
-func New(url *url.URL) *Client {
-	oc := ollama.NewClient(url, http.DefaultClient)
+func New(url *url.URL, options ...Option) *Client {
+	config := &config{
+		options:   map[string]any{},
+		keepAlive: 30 * time.Second,
+	}
+	for _, option := range options {
+		option(config)
+	}
+	oc := ollama.NewClient(url, established.Client(http.DefaultClient))
 	return &Client{
-		oc,
+		oc:        oc,
+		options:   config.options,
+		keepAlive: config.keepAlive,
 	}
 }
 
 // Client implements the llm.Provider interface for Ollama
 type Client struct {
-	oc *ollama.Client
+	oc        *ollama.Client
+	options   map[string]any
+	keepAlive time.Duration
 }
 
 var _ llm.Provider = (*Client)(nil)
@@ -39,6 +80,14 @@ func (c *Client) Name() string {
 	return "ollama"
 }
 
+// Timeout doubles the default timeouts since local models are slower and may
+// need to be loaded into memory first
+func (c *Client) Timeout(thinking llm.Thinking) time.Duration {
+	return 2 * llm.DefaultTimeout(thinking)
+}
+
+// toUsage converts the final response's counts. Ollama doesn't break down
+// reasoning or cache tokens.
 func toUsage(resp ollama.ChatResponse) *llm.Usage {
 	if resp.PromptEvalCount == 0 && resp.EvalCount == 0 {
 		return nil
@@ -96,9 +145,77 @@ func toOllamaSchema(prop *llm.ToolProperty) ollama.ToolProperty {
 	if prop.Items != nil {
 		p.Items = toOllamaSchema(prop.Items)
 	}
+	// Ollama's tool properties don't carry nested required fields, map value
+	// types, defaults or constraints like minimum and pattern
+	if len(prop.Properties) > 0 {
+		p.Properties = ollama.NewToolPropertiesMap()
+		for name, child := range prop.Properties {
+			p.Properties.Set(name, toOllamaSchema(child))
+		}
+	}
 	return p
 }
 
+func toOllamaParameters(params *llm.ToolFunctionParameters) ollama.ToolFunctionParameters {
+	props := ollama.NewToolPropertiesMap()
+	for name, prop := range params.Properties {
+		props.Set(name, toOllamaSchema(prop))
+	}
+	return ollama.ToolFunctionParameters{
+		Type:       params.Type,
+		Properties: props,
+		Required:   params.Required,
+	}
+}
+
+// toMessages converts messages for Ollama. Consecutive assistant messages are
+// merged so a reply's text and tool calls go back as one message, and tool
+// results carry the ID and name of the call they answer.
+func toMessages(messages []*llm.Message) ([]ollama.Message, error) {
+	var out []ollama.Message
+	toolNames := map[string]string{}
+	for _, m := range messages {
+		content := m.Content
+		for _, part := range m.Parts {
+			if part.Type != "text" {
+				return nil, fmt.Errorf("ollama: model does not support %s input", part.Type)
+			}
+			content += part.Text
+		}
+		if m.Role == "assistant" && len(out) > 0 && out[len(out)-1].Role == "assistant" {
+			last := &out[len(out)-1]
+			last.Content += content
+		} else {
+			out = append(out, ollama.Message{
+				Role:       m.Role,
+				Content:    content,
+				ToolCallID: m.ToolCallID,
+				ToolName:   toolNames[m.ToolCallID],
+			})
+		}
+		if m.ToolCall == nil {
+			continue
+		}
+		args := ollama.NewToolCallFunctionArguments()
+		if len(m.ToolCall.Arguments) > 0 {
+			if err := json.Unmarshal(m.ToolCall.Arguments, &args); err != nil {
+				return nil, fmt.Errorf("ollama: unmarshaling tool arguments: %w", err)
+			}
+		}
+		toolNames[m.ToolCall.ID] = m.ToolCall.Name
+		last := &out[len(out)-1]
+		last.ToolCalls = append(last.ToolCalls, ollama.ToolCall{
+			ID: m.ToolCall.ID,
+			Function: ollama.ToolCallFunction{
+				Index:     len(last.ToolCalls),
+				Name:      m.ToolCall.Name,
+				Arguments: args,
+			},
+		})
+	}
+	return out, nil
+}
+
 // Chat sends a chat request to Ollama
 func (c *Client) Chat(ctx context.Context, req *llm.ChatRequest) iter.Seq2[*llm.ChatResponse, error] {
 	return func(yield func(*llm.ChatResponse, error) bool) {
@@ -108,85 +225,185 @@ func (c *Client) Chat(ctx context.Context, req *llm.ChatRequest) iter.Seq2[*llm.
 			return
 		}
 
-		// Convert messages
-		messages := make([]ollama.Message, len(req.Messages))
-		for i, m := range req.Messages {
-			messages[i] = ollama.Message{
-				Role:    m.Role,
-				Content: m.Content,
-			}
+		messages, err := toMessages(req.Messages)
+		if err != nil {
+			yield(nil, err)
+			return
 		}
 
-		// Convert tools
+		// Convert tools. Ollama doesn't support tool choice, so the best we can
+		// do is hide tools the model shouldn't call. Requiring a tool call isn't
+		// enforced.
 		var tools ollama.Tools
 		for _, t := range req.Tools {
-			props := ollama.NewToolPropertiesMap()
-			for name, prop := range t.Function.Parameters.Properties {
-				props.Set(name, toOllamaSchema(prop))
+			if req.ToolChoice.Mode == "none" || (req.ToolChoice.Mode == "tool" && t.Function.Name != req.ToolChoice.Name) {
+				continue
 			}
-
 			tools = append(tools, ollama.Tool{
 				Type: t.Type,
 				Function: ollama.ToolFunction{
 					Name:        t.Function.Name,
 					Description: t.Function.Description,
-					Parameters: ollama.ToolFunctionParameters{
-						Type:       t.Function.Parameters.Type,
-						Properties: props,
-						Required:   t.Function.Parameters.Required,
-					},
+					Parameters:  toOllamaParameters(t.Function.Parameters),
 				},
 			})
 		}
 
+		// Constrain the output to the response schema
+		var format json.RawMessage
+		if req.ResponseSchema != nil {
+			schema, err := json.Marshal(toOllamaParameters(req.ResponseSchema))
+			if err != nil {
+				yield(nil, fmt.Errorf("ollama: marshaling response schema: %w", err))
+				return
+			}
+			format = schema
+		}
+
+		// Ollama's request fields are fixed, so provider params are treated as
+		// runtime options too
+		options := defaultOptions()
+		for key, value := range c.options {
+			options[key] = value
+		}
+		for key, value := range req.ProviderParams {
+			options[key] = value
+		}
+
 		stream := true
 		chatReq := &ollama.ChatRequest{
 			Model:    model,
 			Messages: messages,
 			Tools:    tools,
 			Stream:   &stream,
-			Options:  defaultOptions(),
+			Format:   format,
+			Options:  options,
 			Think:    toThink(req.Thinking),
-			// TODO: make this configurable on the ollama provider.
 			KeepAlive: &ollama.Duration{
-				Duration: 30 * time.Second,
+				Duration: c.keepAlive,
 			},
 		}
 
-		err := c.oc.Chat(ctx, chatReq, func(resp ollama.ChatResponse) error {
+		if len(req.StopSequences) > 0 {
+			chatReq.Options["stop"] = req.StopSequences
+		}
+
+		if req.MaxOutputTokens > 0 {
+			chatReq.Options["num_predict"] = meta[req.Model].ClampOutputTokens(req.MaxOutputTokens)
+		}
+
+		// Stopped is set when the caller stops iterating, so we don't yield again
+		connected, stopped := false, false
+		start := func() error {
+			if connected {
+				return nil
+			}
+			connected = true
+			if !yield(&llm.ChatResponse{Role: "assistant", Start: true}, nil) {
+				stopped = true
+				return context.Canceled
+			}
+			return nil
+		}
+		respond := func(resp ollama.ChatResponse) error {
+			req.Raw(resp)
+			// The non-streaming fallback starts with its only response
+			if err := start(); err != nil {
+				return err
+			}
 			chatResp := &llm.ChatResponse{
-				Role:    resp.Message.Role,
-				Content: resp.Message.Content,
-				Usage:   toUsage(resp),
-				Done:    resp.Done,
+				Role:     resp.Message.Role,
+				Content:  resp.Message.Content,
+				Thinking: resp.Message.Thinking,
+				Usage:    toUsage(resp),
+				Done:     resp.Done,
 			}
-
-			// Handle thinking content if present
-			if resp.Message.Thinking != "" {
-				chatResp.Thinking = resp.Message.Thinking
+			if len(resp.Message.ToolCalls) == 0 {
+				if !yield(chatResp, nil) {
+					stopped = true
+					return context.Canceled
+				}
+				return nil
 			}
 
-			// Handle tool calls
-			if len(resp.Message.ToolCalls) > 0 {
-				tc := resp.Message.ToolCalls[0]
+			// Yield each tool call on its own. The first carries any text and the
+			// last finishes the response.
+			last := len(resp.Message.ToolCalls) - 1
+			for i, tc := range resp.Message.ToolCalls {
 				args, err := json.Marshal(tc.Function.Arguments)
 				if err != nil {
 					return fmt.Errorf("ollama: marshaling tool arguments: %w", err)
 				}
-				chatResp.ToolCall = &llm.ToolCall{
-					Name:      tc.Function.Name,
-					Arguments: args,
+				// Ollama doesn't always send call IDs
+				id := tc.ID
+				if id == "" {
+					id = req.NewID()
+				}
+				toolResp := &llm.ChatResponse{
+					Role: resp.Message.Role,
+					ToolCall: &llm.ToolCall{
+						ID:        id,
+						Name:      tc.Function.Name,
+						Arguments: args,
+					},
+				}
+				if i == 0 {
+					toolResp.Content = chatResp.Content
+					toolResp.Thinking = chatResp.Thinking
+				}
+				if i == last {
+					toolResp.Usage = chatResp.Usage
+					toolResp.Done = chatResp.Done
+				}
+				if !yield(toolResp, nil) {
+					stopped = true
+					return context.Canceled
 				}
-			}
-
-			if !yield(chatResp, nil) {
-				return context.Canceled
 			}
 			return nil
-		})
+		}
 
-		if err != nil {
+		// Start the response as soon as the stream connects
+		err = c.oc.Chat(established.Context(ctx, start), chatReq, respond)
+		if err != nil && !connected && req.StreamFallback {
+			// Retry without streaming, Ollama sends the whole response at once
+			stream = false
+			err = c.oc.Chat(ctx, chatReq, respond)
+		}
+
+		if err != nil && !stopped {
 			yield(nil, fmt.Errorf("ollama: chat: %w", err))
 		}
 	}
 }
+
+var _ llm.Embedder = (*Client)(nil)
+
+// Embed embeds each input as a vector
+func (c *Client) Embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	res, err := c.oc.Embed(ctx, &ollama.EmbedRequest{
+		Model: model,
+		Input: inputs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ollama: embedding: %w", err)
+	}
+	return res.Embeddings, nil
+}
+
+var _ llm.Warmer = (*Client)(nil)
+
+// Warmup loads the model into memory. A chat request without messages loads
+// the model without generating anything.
+func (c *Client) Warmup(ctx context.Context, model string) error {
+	err := c.oc.Chat(ctx, &ollama.ChatRequest{
+		Model: model,
+		KeepAlive: &ollama.Duration{
+			Duration: c.keepAlive,
+		},
+	}, func(ollama.ChatResponse) error { return nil })
+	if err != nil {
+		return fmt.Errorf("ollama: loading model: %w", err)
+	}
+	return nil
+}
@@ -2,7 +2,10 @@ package ollama_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
@@ -12,6 +15,7 @@ import (
 	"github.com/matthewmueller/llm"
 	"github.com/matthewmueller/llm/internal/env"
 	"github.com/matthewmueller/llm/providers/ollama"
+	ollamaapi "github.com/ollama/ollama/api"
 )
 
 const testModel = "glm-4.7-flash:latest"
@@ -231,3 +235,390 @@ func TestToolFailOnce(t *testing.T) {
 	}
 	is.True(strings.Contains(content.String(), "noodles"))
 }
+
+func TestStreamFallback(t *testing.T) {
+	is := is.New(t)
+	ctx := testContext(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Stream *bool `json:"stream"`
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&req))
+		if req.Stream == nil || *req.Stream {
+			http.Error(w, `{"error":"streaming blocked by proxy"}`, http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"model":"test","message":{"role":"assistant","content":"4"},"done":true,"prompt_eval_count":3,"eval_count":1}`)
+	}))
+	defer server.Close()
+
+	host, err := url.Parse(server.URL)
+	is.NoErr(err)
+	provider := ollama.New(host)
+	client := llm.New(provider)
+
+	// Without the fallback the request fails
+	for _, err := range client.Chat(ctx, provider.Name(),
+		llm.WithModel("test"),
+		llm.WithMessage(llm.UserMessage("What is 2+2?")),
+	) {
+		is.True(err != nil)
+	}
+
+	content := new(strings.Builder)
+	for event, err := range client.Chat(ctx, provider.Name(),
+		llm.WithModel("test"),
+		llm.WithMessage(llm.UserMessage("What is 2+2?")),
+		llm.WithStreamFallback(true),
+	) {
+		is.NoErr(err)
+		content.WriteString(event.Content)
+	}
+	is.Equal(content.String(), "4")
+}
+
+func TestStartEvent(t *testing.T) {
+	is := is.New(t)
+	ctx := testContext(t)
+
+	started := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		// Hold the first chunk back until the client has started
+		<-started
+		fmt.Fprintln(w, `{"model":"test","message":{"role":"assistant","content":"4"},"done":true,"prompt_eval_count":3,"eval_count":1}`)
+	}))
+	defer server.Close()
+
+	host, err := url.Parse(server.URL)
+	is.NoErr(err)
+	provider := ollama.New(host)
+
+	var events []*llm.ChatResponse
+	for event, err := range provider.Chat(ctx, &llm.ChatRequest{
+		Model:    "test",
+		Messages: []*llm.Message{llm.UserMessage("What is 2+2?")},
+	}) {
+		is.NoErr(err)
+		if len(events) == 0 {
+			is.True(event.Start)
+			close(started)
+		}
+		events = append(events, event)
+	}
+	is.True(len(events) > 1)
+	is.Equal(events[1].Content, "4")
+}
+
+func TestToolCallsInOneResponse(t *testing.T) {
+	is := is.New(t)
+	ctx := testContext(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"model":"test","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"add","arguments":{"a":10,"b":5}}},{"function":{"name":"multiply","arguments":{"a":3,"b":4}}}]},"done":false}`)
+		fmt.Fprintln(w, `{"model":"test","message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":20,"eval_count":10}`)
+	}))
+	defer server.Close()
+
+	host, err := url.Parse(server.URL)
+	is.NoErr(err)
+	provider := ollama.New(host)
+
+	n := 0
+	var calls []*llm.ToolCall
+	for res, err := range provider.Chat(ctx, &llm.ChatRequest{
+		Model: "test",
+		Messages: []*llm.Message{
+			llm.UserMessage("Add 10 and 5, and multiply 3 and 4."),
+		},
+		IDGenerator: func() string {
+			n++
+			return fmt.Sprintf("call_%d", n)
+		},
+	}) {
+		is.NoErr(err)
+		if res.ToolCall != nil {
+			calls = append(calls, res.ToolCall)
+		}
+	}
+	is.Equal(len(calls), 2)
+	is.Equal(calls[0].ID, "call_1")
+	is.Equal(calls[0].Name, "add")
+	is.Equal(string(calls[0].Arguments), `{"a":10,"b":5}`)
+	is.Equal(calls[1].ID, "call_2")
+	is.Equal(calls[1].Name, "multiply")
+	is.Equal(string(calls[1].Arguments), `{"a":3,"b":4}`)
+}
+
+func TestOptions(t *testing.T) {
+	is := is.New(t)
+	ctx := testContext(t)
+
+	var req struct {
+		Options   map[string]any `json:"options"`
+		KeepAlive string         `json:"keep_alive"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.NoErr(json.NewDecoder(r.Body).Decode(&req))
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"model":"test","message":{"role":"assistant","content":"4"},"done":true}`)
+	}))
+	defer server.Close()
+
+	host, err := url.Parse(server.URL)
+	is.NoErr(err)
+	provider := ollama.New(host,
+		ollama.WithOptions(map[string]any{"num_ctx": 32768, "temperature": 0.2, "num_predict": 100}),
+		ollama.WithKeepAlive(5*time.Minute),
+	)
+	for _, err := range provider.Chat(ctx, &llm.ChatRequest{
+		Model:           "test",
+		Messages:        []*llm.Message{llm.UserMessage("What is 2+2?")},
+		MaxOutputTokens: 50,
+	}) {
+		is.NoErr(err)
+	}
+	is.Equal(req.Options["num_ctx"], 32768.0)  // overridden
+	is.Equal(req.Options["temperature"], 0.2)  // overridden
+	is.Equal(req.Options["num_predict"], 50.0) // the request wins
+	is.Equal(req.Options["top_k"], 40.0)       // Ollama's default
+	is.Equal(req.KeepAlive, "5m0s")
+}
+
+func TestToolCallIDsRoundTrip(t *testing.T) {
+	is := is.New(t)
+	ctx := testContext(t)
+
+	type message struct {
+		Role       string `json:"role"`
+		Content    string `json:"content"`
+		ToolCallID string `json:"tool_call_id"`
+		ToolName   string `json:"tool_name"`
+		ToolCalls  []struct {
+			ID       string `json:"id"`
+			Function struct {
+				Name string `json:"name"`
+			} `json:"function"`
+		} `json:"tool_calls"`
+	}
+	var requests [][]message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []message `json:"messages"`
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&req))
+		requests = append(requests, req.Messages)
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		switch len(requests) {
+		case 1:
+			fmt.Fprintln(w, `{"model":"test","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"add","arguments":{"a":10,"b":5}}}]},"done":true}`)
+		case 2:
+			fmt.Fprintln(w, `{"model":"test","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"multiply","arguments":{"a":15,"b":2}}}]},"done":true}`)
+		default:
+			fmt.Fprintln(w, `{"model":"test","message":{"role":"assistant","content":"30"},"done":true}`)
+		}
+	}))
+	defer server.Close()
+
+	host, err := url.Parse(server.URL)
+	is.NoErr(err)
+	provider := ollama.New(host)
+	client := llm.New(provider)
+
+	// Calls without IDs get them from the configured generator
+	n := 0
+	for _, err := range client.Chat(ctx, provider.Name(),
+		llm.WithModel("test"),
+		llm.WithMessage(llm.UserMessage("Add 10 and 5, then multiply the result by 2.")),
+		llm.WithTool(addTool),
+		llm.WithTool(multiplyTool),
+		llm.WithIDGenerator(func() string {
+			n++
+			return fmt.Sprintf("call_%d", n)
+		}),
+	) {
+		is.NoErr(err)
+	}
+	is.Equal(len(requests), 3)
+
+	// The second request replays the first call and its result
+	second := requests[1]
+	is.Equal(len(second), 3)
+	is.Equal(second[1].Role, "assistant")
+	is.Equal(len(second[1].ToolCalls), 1)
+	is.Equal(second[1].ToolCalls[0].ID, "call_1")
+	is.Equal(second[2].Role, "tool")
+	is.Equal(second[2].ToolCallID, "call_1")
+	is.Equal(second[2].ToolName, "add")
+	is.Equal(second[2].Content, "15")
+
+	// The third request replays the next call with its own ID
+	third := requests[2]
+	is.Equal(len(third), 5)
+	is.Equal(third[3].ToolCalls[0].ID, "call_2")
+	is.Equal(third[4].ToolCallID, "call_2")
+	is.Equal(third[4].ToolName, "multiply")
+	is.Equal(third[4].Content, "30")
+}
+
+func TestImageUnsupported(t *testing.T) {
+	is := is.New(t)
+	ctx := testContext(t)
+
+	host, err := url.Parse("http://127.0.0.1:0")
+	is.NoErr(err)
+	provider := ollama.New(host)
+	message := llm.UserMessage("What's in this image?")
+	message.Parts = []*llm.ContentPart{llm.ImagePart("image/png", []byte("png"))}
+	for _, err := range provider.Chat(ctx, &llm.ChatRequest{
+		Model:    "test",
+		Messages: []*llm.Message{message},
+	}) {
+		is.True(err != nil)
+		is.Equal(err.Error(), "ollama: model does not support image input")
+	}
+}
+
+func TestStopSequences(t *testing.T) {
+	host := loadHost(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	provider := ollama.New(host)
+	client := llm.New(provider)
+	var content strings.Builder
+	done := false
+	for event, err := range client.Chat(ctx,
+		provider.Name(),
+		llm.WithModel(testModel),
+		llm.WithThinking(llm.ThinkingNone),
+		llm.WithMessage(llm.UserMessage("Count from 1 to 10, separated by commas. Reply with just the numbers.")),
+		llm.WithStopSequences("3"),
+	) {
+		is.NoErr(err)
+		content.WriteString(event.Content)
+		done = done || event.Done
+	}
+	is.True(done)
+	is.True(strings.Contains(content.String(), "2"))
+	is.True(!strings.Contains(content.String(), "3"))
+}
+
+func TestToolChoiceNone(t *testing.T) {
+	host := loadHost(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	provider := ollama.New(host)
+	client := llm.New(provider)
+	for event, err := range client.Chat(ctx,
+		provider.Name(),
+		llm.WithModel(testModel),
+		llm.WithMessage(llm.UserMessage("Use the add tool to add 17 and 25.")),
+		llm.WithTool(addTool),
+		llm.WithToolChoice(llm.ToolChoiceNone),
+	) {
+		is.NoErr(err)
+		is.Equal(event.ToolCall, nil)
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	host := loadHost(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	provider := ollama.New(host)
+	client := llm.New(provider)
+	vectors, err := client.Embed(ctx, provider.Name(), "nomic-embed-text", "hello world", "goodbye world")
+	is.NoErr(err)
+	is.Equal(len(vectors), 2)
+	is.Equal(len(vectors[0]), 768)
+	is.Equal(len(vectors[1]), 768)
+}
+
+func TestEmbedRequest(t *testing.T) {
+	is := is.New(t)
+	ctx := testContext(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.URL.Path, "/api/embed")
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&req))
+		is.Equal(req.Model, "nomic-embed-text")
+		is.Equal(req.Input, []string{"a", "b"})
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"model":"nomic-embed-text","embeddings":[[0.1,0.2],[0.3,0.4]]}`)
+	}))
+	defer server.Close()
+
+	host, err := url.Parse(server.URL)
+	is.NoErr(err)
+	provider := ollama.New(host)
+	vectors, err := llm.New(provider).Embed(ctx, provider.Name(), "nomic-embed-text", "a", "b")
+	is.NoErr(err)
+	is.Equal(vectors, [][]float32{{0.1, 0.2}, {0.3, 0.4}})
+}
+
+func TestWarmupRequest(t *testing.T) {
+	is := is.New(t)
+	ctx := testContext(t)
+
+	var req struct {
+		Model     string         `json:"model"`
+		Messages  []*llm.Message `json:"messages"`
+		KeepAlive string         `json:"keep_alive"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.URL.Path, "/api/chat")
+		is.NoErr(json.NewDecoder(r.Body).Decode(&req))
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"model":"test","message":{"role":"assistant","content":""},"done_reason":"load","done":true}`)
+	}))
+	defer server.Close()
+
+	host, err := url.Parse(server.URL)
+	is.NoErr(err)
+	provider := ollama.New(host, ollama.WithKeepAlive(time.Minute))
+	is.NoErr(llm.New(provider).Warmup(ctx, provider.Name(), "test"))
+	is.Equal(req.Model, "test")
+	is.Equal(len(req.Messages), 0)
+	is.Equal(req.KeepAlive, "1m0s")
+}
+
+func TestRawEvents(t *testing.T) {
+	is := is.New(t)
+	ctx := testContext(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"model":"test","message":{"role":"assistant","content":"4"},"done":false}`)
+		fmt.Fprintln(w, `{"model":"test","message":{"role":"assistant","content":""},"done_reason":"stop","done":true}`)
+	}))
+	defer server.Close()
+
+	host, err := url.Parse(server.URL)
+	is.NoErr(err)
+	var events []ollamaapi.ChatResponse
+	provider := ollama.New(host)
+	for _, err := range provider.Chat(ctx, &llm.ChatRequest{
+		Model:    "test",
+		Messages: []*llm.Message{llm.UserMessage("What is 2+2?")},
+		RawEvents: func(event any) {
+			events = append(events, event.(ollamaapi.ChatResponse))
+		},
+	}) {
+		is.NoErr(err)
+	}
+	is.Equal(len(events), 2)
+	is.Equal(events[0].Message.Content, "4")
+	is.Equal(events[1].DoneReason, "stop")
+}
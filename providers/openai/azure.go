@@ -0,0 +1,35 @@
+package openai
+
+import (
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/azure"
+	"github.com/openai/openai-go/option"
+)
+
+// Deployment maps an Azure OpenAI deployment to the model it serves
+type Deployment struct {
+	Name  string
+	Model string
+}
+
+// NewAzure creates a client for Azure OpenAI. Chat requests use the deployment
+// name as the model. Deployments map their names to models for metadata, and
+// when given they're listed as the available models.
+func NewAzure(endpoint, apiKey, apiVersion string, deployments ...*Deployment) *Client {
+	oc := openai.NewClient(
+		azure.WithEndpoint(endpoint, apiVersion),
+		azure.WithAPIKey(apiKey),
+		// Don't send an OPENAI_API_KEY from the environment to Azure
+		option.WithHeaderDel("Authorization"),
+	)
+	models := make(map[string]string, len(deployments))
+	for _, deployment := range deployments {
+		models[deployment.Name] = deployment.Model
+	}
+	return &Client{
+		oc:          &oc,
+		name:        "azure",
+		deployments: deployments,
+		models:      models,
+	}
+}
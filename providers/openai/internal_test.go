@@ -0,0 +1,591 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+	"github.com/openai/openai-go/responses"
+)
+
+func TestToInputOrder(t *testing.T) {
+	is := is.New(t)
+	input := toInput([]*llm.Message{
+		llm.SystemMessage("You are a calculator"),
+		llm.UserMessage("What is 1+2?"),
+		{
+			Role:     "assistant",
+			Thinking: "I should use the add tool",
+			Content:  "Let me add those.",
+			ToolCall: &llm.ToolCall{
+				ID:        "call_1",
+				Name:      "add",
+				Arguments: json.RawMessage(`{"a":1,"b":2}`),
+			},
+		},
+		{Role: "tool", Content: "3", ToolCallID: "call_1"},
+		llm.AssistantMessage("The answer is 3"),
+	})
+	is.Equal(len(input), 6)
+	is.True(input[0].OfMessage != nil)
+	is.Equal(string(input[0].OfMessage.Role), "system")
+	is.True(input[1].OfMessage != nil)
+	is.Equal(string(input[1].OfMessage.Role), "user")
+
+	// The message item comes before the function call item
+	is.True(input[2].OfMessage != nil)
+	is.Equal(string(input[2].OfMessage.Role), "assistant")
+	is.Equal(input[2].OfMessage.Content.OfString.Value, "Let me add those.")
+	is.True(input[3].OfFunctionCall != nil)
+	is.Equal(input[3].OfFunctionCall.CallID, "call_1")
+	is.Equal(input[3].OfFunctionCall.Arguments, `{"a":1,"b":2}`)
+
+	is.True(input[4].OfFunctionCallOutput != nil)
+	is.Equal(input[4].OfFunctionCallOutput.CallID, "call_1")
+	is.True(input[5].OfMessage != nil)
+}
+
+func TestToInputToolResultImages(t *testing.T) {
+	is := is.New(t)
+	image := llm.ImagePart("image/png", []byte("png"))
+	input := toInput([]*llm.Message{
+		llm.UserMessage("Compare a.png and b.txt"),
+		{Role: "assistant", ToolCall: &llm.ToolCall{ID: "call_1", Name: "read"}},
+		{Role: "assistant", ToolCall: &llm.ToolCall{ID: "call_2", Name: "read"}},
+		{Role: "tool", Content: `{"path":"a.png"}`, ToolCallID: "call_1", Parts: []*llm.ContentPart{image}},
+		{Role: "tool", Content: `{"path":"b.txt"}`, ToolCallID: "call_2"},
+		llm.AssistantMessage("They match"),
+	})
+	is.Equal(len(input), 7)
+	is.Equal(input[3].OfFunctionCallOutput.Output, `{"path":"a.png"}`)
+	is.Equal(input[4].OfFunctionCallOutput.Output, `{"path":"b.txt"}`)
+
+	// The images follow the run of outputs so the calls stay answered together
+	is.Equal(string(input[5].OfMessage.Role), "user")
+	content := input[5].OfMessage.Content.OfInputItemContentList
+	is.Equal(len(content), 1)
+	is.Equal(content[0].OfInputImage.ImageURL.Value, "data:image/png;base64,cG5n")
+	is.Equal(string(input[6].OfMessage.Role), "assistant")
+}
+
+func TestToInputToolCallOnly(t *testing.T) {
+	is := is.New(t)
+	input := toInput([]*llm.Message{
+		{Role: "assistant", Thinking: "thinking only"},
+		{Role: "assistant", ToolCall: &llm.ToolCall{ID: "call_1", Name: "add"}},
+	})
+	is.Equal(len(input), 1)
+	is.True(input[0].OfFunctionCall != nil)
+}
+
+func TestToInputImage(t *testing.T) {
+	is := is.New(t)
+	message := llm.UserMessage("What's in this image?")
+	message.Parts = []*llm.ContentPart{
+		llm.ImagePart("image/png", []byte("png")),
+		llm.ImageURLPart("image/jpeg", "https://example.com/cat.jpg"),
+	}
+	input := toInput([]*llm.Message{message})
+	is.Equal(len(input), 1)
+	content := input[0].OfMessage.Content.OfInputItemContentList
+	is.Equal(len(content), 3)
+	is.Equal(content[0].OfInputText.Text, "What's in this image?")
+	is.Equal(content[1].OfInputImage.ImageURL.Value, "data:image/png;base64,cG5n")
+	is.Equal(content[2].OfInputImage.ImageURL.Value, "https://example.com/cat.jpg")
+}
+
+func TestStopper(t *testing.T) {
+	is := is.New(t)
+	stop := newStopper([]string{"STOP", "\n\n"})
+
+	text, stopped := stop.Write("one ST")
+	is.Equal(text, "one ")
+	is.True(!stopped)
+
+	// Held back text is sent once it can't be a stop sequence
+	text, stopped = stop.Write("ART two\n")
+	is.Equal(text, "START two")
+	is.True(!stopped)
+
+	text, stopped = stop.Write("\nthree")
+	is.Equal(text, "")
+	is.True(stopped)
+	is.Equal(stop.Flush(), "")
+
+	// The earliest stop sequence wins
+	stop = newStopper([]string{"b", "a"})
+	text, stopped = stop.Write("xxabc")
+	is.Equal(text, "xx")
+	is.True(stopped)
+
+	// Without a stop sequence the held back text is flushed
+	stop = newStopper([]string{"STOP"})
+	text, _ = stop.Write("almost ST")
+	is.Equal(text, "almost ")
+	is.Equal(stop.Flush(), "ST")
+
+	// Only the text that may start a stop sequence is kept
+	stop = newStopper([]string{"STOP"})
+	sent := ""
+	for range 1000 {
+		text, _ = stop.Write("S T O ")
+		sent += text
+		is.True(len(stop.held) < len("STOP"))
+	}
+	is.Equal(len(sent+stop.Flush()), 6000)
+
+	// Stop sequences can span several deltas
+	stop = newStopper([]string{"STOP"})
+	sent = ""
+	for _, delta := range []string{"go S", "T", "O", "P now"} {
+		text, stopped = stop.Write(delta)
+		sent += text
+	}
+	is.Equal(sent, "go ")
+	is.True(stopped)
+}
+
+func TestStopSequenceUsage(t *testing.T) {
+	is := is.New(t)
+	client := replay(t, "testdata/stop.sse")
+	var content strings.Builder
+	var last *llm.ChatResponse
+	for res, err := range client.Chat(context.Background(), &llm.ChatRequest{
+		Model:         "gpt-5",
+		Messages:      []*llm.Message{llm.UserMessage("say hello")},
+		StopSequences: []string{"STOP"},
+	}) {
+		is.NoErr(err)
+		content.WriteString(res.Content)
+		last = res
+	}
+	is.Equal(content.String(), "Hello ")
+
+	// The stream is read to the end for the usage
+	is.True(last.Done)
+	is.Equal(last.StopReason, "")
+	is.Equal(last.Usage.TotalTokens, 20)
+}
+
+func TestAzure(t *testing.T) {
+	is := is.New(t)
+	t.Setenv("OPENAI_API_KEY", "sk-openai")
+	var req *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"bad request"}}`))
+	}))
+	defer server.Close()
+
+	client := NewAzure(server.URL, "azure-key", "2025-04-01-preview", &Deployment{Name: "prod", Model: "gpt-5"})
+	is.Equal(client.Name(), "azure")
+
+	// Deployments are listed as models with the deployed model's metadata
+	models, err := client.Models(context.Background())
+	is.NoErr(err)
+	is.Equal(len(models), 1)
+	is.Equal(models[0].Provider, "azure")
+	is.Equal(models[0].ID, "prod")
+	is.Equal(models[0].Meta, meta["gpt-5"])
+
+	for _, err := range client.Chat(context.Background(), &llm.ChatRequest{
+		Model:    "prod",
+		Messages: []*llm.Message{llm.UserMessage("hi")},
+	}) {
+		is.True(err != nil)
+	}
+	is.True(req != nil)
+	is.Equal(req.URL.Path, "/openai/responses")
+	is.Equal(req.URL.Query().Get("api-version"), "2025-04-01-preview")
+	is.Equal(req.Header.Get("Api-Key"), "azure-key")
+	is.Equal(req.Header.Get("Authorization"), "")
+
+	// Embeddings are routed to the deployment
+	_, err = client.Embed(context.Background(), "embed", []string{"hi"})
+	is.True(err != nil)
+	is.Equal(req.URL.Path, "/openai/deployments/embed/embeddings")
+}
+
+func TestWithBaseURL(t *testing.T) {
+	is := is.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.URL.Path, "/v1/responses")
+		is.Equal(r.Header.Get("Authorization"), "Bearer secret")
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"response.created","sequence_number":0,"response":{"id":"resp_1","status":"in_progress"}}`,
+			`{"type":"response.output_text.delta","sequence_number":1,"item_id":"msg_1","output_index":0,"content_index":0,"delta":"Hello"}`,
+			`{"type":"response.output_text.delta","sequence_number":2,"item_id":"msg_1","output_index":0,"content_index":0,"delta":" there"}`,
+			`{"type":"response.output_text.done","sequence_number":3,"item_id":"msg_1","output_index":0,"content_index":0,"text":"Hello there"}`,
+			`{"type":"response.completed","sequence_number":4,"response":{"id":"resp_1","status":"completed","usage":{"input_tokens":3,"output_tokens":2,"total_tokens":5}}}`,
+		} {
+			var typ struct{ Type string }
+			is.NoErr(json.Unmarshal([]byte(event), &typ))
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typ.Type, event)
+		}
+	}))
+	defer server.Close()
+
+	client := New("secret", WithBaseURL(server.URL+"/v1"))
+	var content strings.Builder
+	var last *llm.ChatResponse
+	for res, err := range client.Chat(context.Background(), &llm.ChatRequest{
+		Model:    "local-model",
+		Messages: []*llm.Message{llm.UserMessage("hi")},
+	}) {
+		is.NoErr(err)
+		content.WriteString(res.Content)
+		last = res
+	}
+	is.Equal(content.String(), "Hello there")
+	is.True(last.Done)
+	is.Equal(last.Usage.TotalTokens, 5)
+}
+
+func TestToUsage(t *testing.T) {
+	is := is.New(t)
+	data, err := os.ReadFile("testdata/usage.json")
+	is.NoErr(err)
+	var usage responses.ResponseUsage
+	is.NoErr(json.Unmarshal(data, &usage))
+	is.Equal(toUsage(usage), &llm.Usage{
+		InputTokens:       2048,
+		OutputTokens:      412,
+		TotalTokens:       2460,
+		CachedInputTokens: 1536,
+		ReasoningTokens:   320,
+		CacheReadTokens:   1536,
+	})
+}
+
+func TestStatefulResponses(t *testing.T) {
+	is := is.New(t)
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		requests = append(requests, body)
+		if body["previous_response_id"] == "resp_gone" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"message":"Previous response not found.","type":"invalid_request_error"}}`)
+			return
+		}
+		if body["previous_response_id"] == "resp_invalid" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":{"message":"Invalid value for 'temperature'.","type":"invalid_request_error","param":"temperature"}}`)
+			return
+		}
+		// Each reply is different, like a real model's
+		id := fmt.Sprintf("resp_%d", len(requests))
+		text := fmt.Sprintf("Hello %d", len(requests))
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"response.created","sequence_number":0,"response":{"id":"` + id + `","status":"in_progress"}}`,
+			`{"type":"response.output_text.delta","sequence_number":1,"item_id":"msg_1","output_index":0,"content_index":0,"delta":"` + text + `"}`,
+			`{"type":"response.output_text.done","sequence_number":2,"item_id":"msg_1","output_index":0,"content_index":0,"text":"` + text + `"}`,
+			`{"type":"response.completed","sequence_number":3,"response":{"id":"` + id + `","status":"completed"}}`,
+		} {
+			var typ struct{ Type string }
+			is.NoErr(json.Unmarshal([]byte(event), &typ))
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typ.Type, event)
+		}
+	}))
+	defer server.Close()
+
+	client := New("secret", WithBaseURL(server.URL+"/v1"), WithStatefulResponses(true))
+	chat := func(messages ...*llm.Message) map[string]any {
+		for _, err := range client.Chat(context.Background(), &llm.ChatRequest{
+			Model:    "gpt-5",
+			Messages: messages,
+		}) {
+			is.NoErr(err)
+		}
+		return requests[len(requests)-1]
+	}
+
+	// The first turn sends everything
+	req := chat(llm.UserMessage("hi"))
+	is.Equal(req["previous_response_id"], nil)
+	is.Equal(len(req["input"].([]any)), 1)
+
+	// The next turn only sends the new message
+	req = chat(llm.UserMessage("hi"), llm.AssistantMessage("Hello 1"), llm.UserMessage("again"))
+	is.Equal(req["previous_response_id"], "resp_1")
+	input := req["input"].([]any)
+	is.Equal(len(input), 1)
+	is.Equal(input[0].(map[string]any)["content"], "again")
+
+	// Another conversation that starts the same way doesn't collide
+	chat(llm.UserMessage("hi"))
+	req = chat(llm.UserMessage("hi"), llm.AssistantMessage("Hello 3"), llm.UserMessage("again"))
+	is.Equal(req["previous_response_id"], "resp_3")
+	req = chat(llm.UserMessage("hi"), llm.AssistantMessage("Hello 1"), llm.UserMessage("more"))
+	is.Equal(req["previous_response_id"], "resp_1")
+
+	// Replies recorded as streamed chunks continue too
+	req = chat(llm.UserMessage("hi"), llm.AssistantMessage("Hel"), llm.AssistantMessage("lo 1"), llm.UserMessage("again"))
+	is.Equal(req["previous_response_id"], "resp_1")
+
+	// An unknown history sends everything
+	req = chat(llm.UserMessage("hi"), llm.AssistantMessage("Goodbye"), llm.UserMessage("again"))
+	is.Equal(req["previous_response_id"], nil)
+	is.Equal(len(req["input"].([]any)), 3)
+
+	// A stored response that's gone falls back to the full history
+	client.stored.set([]*llm.Message{llm.UserMessage("old")}, []*llm.Message{llm.AssistantMessage("Hello")}, "resp_gone")
+	count := len(requests)
+	req = chat(llm.UserMessage("old"), llm.AssistantMessage("Hello"), llm.UserMessage("again"))
+	is.Equal(len(requests), count+2)
+	is.Equal(requests[count]["previous_response_id"], "resp_gone")
+	is.Equal(req["previous_response_id"], nil)
+	is.Equal(len(req["input"].([]any)), 3)
+
+	// Other errors don't resend the full history
+	client.stored.set([]*llm.Message{llm.UserMessage("bad")}, []*llm.Message{llm.AssistantMessage("Hello")}, "resp_invalid")
+	count = len(requests)
+	var err error
+	for _, err = range client.Chat(context.Background(), &llm.ChatRequest{
+		Model:    "gpt-5",
+		Messages: []*llm.Message{llm.UserMessage("bad"), llm.AssistantMessage("Hello"), llm.UserMessage("again")},
+	}) {
+	}
+	is.True(err != nil)
+	is.Equal(len(requests), count+1)
+}
+
+// replay serves a recorded event stream from testdata
+func replay(t *testing.T, fixture string) *Client {
+	t.Helper()
+	data, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return New("secret", WithBaseURL(server.URL+"/v1"))
+}
+
+func TestIncomplete(t *testing.T) {
+	is := is.New(t)
+	client := replay(t, "testdata/incomplete.sse")
+	var content strings.Builder
+	var last *llm.ChatResponse
+	for res, err := range client.Chat(context.Background(), &llm.ChatRequest{
+		Model:    "gpt-5",
+		Messages: []*llm.Message{llm.UserMessage("tell me a story")},
+	}) {
+		is.NoErr(err)
+		content.WriteString(res.Content)
+		last = res
+	}
+	is.Equal(content.String(), "Once upon a time")
+	is.True(last.Done)
+	is.Equal(last.StopReason, "max_output_tokens")
+	is.Equal(last.Usage.TotalTokens, 28)
+}
+
+func TestRefusal(t *testing.T) {
+	is := is.New(t)
+	client := replay(t, "testdata/refusal.sse")
+	var errs []error
+	for _, err := range client.Chat(context.Background(), &llm.ChatRequest{
+		Model:    "gpt-5",
+		Messages: []*llm.Message{llm.UserMessage("something bad")},
+	}) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	is.Equal(len(errs), 1)
+	is.Equal(errs[0].Error(), "openai: model refused: I can't help with that.")
+}
+
+func TestProviderParams(t *testing.T) {
+	is := is.New(t)
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: response.completed\ndata: {\"type\":\"response.completed\",\"sequence_number\":0,\"response\":{\"id\":\"resp_1\",\"status\":\"completed\"}}\n\n")
+	}))
+	defer server.Close()
+
+	client := New("secret", WithBaseURL(server.URL+"/v1"))
+	for _, err := range client.Chat(context.Background(), &llm.ChatRequest{
+		Model:          "gpt-5",
+		Messages:       []*llm.Message{llm.UserMessage("hi")},
+		ProviderParams: map[string]any{"service_tier": "flex", "metadata": map[string]any{"team": "search"}},
+	}) {
+		is.NoErr(err)
+	}
+	is.Equal(body["service_tier"], "flex")
+	is.Equal(body["metadata"], map[string]any{"team": "search"})
+	is.Equal(body["model"], "gpt-5")
+}
+
+func TestToParamsEndUser(t *testing.T) {
+	is := is.New(t)
+	params := toParams(&llm.ChatRequest{
+		Model:   "gpt-5",
+		EndUser: "user-1234",
+	}, nil)
+	is.Equal(params.SafetyIdentifier.Value, "user-1234")
+}
+
+func TestStreamFallback(t *testing.T) {
+	is := is.New(t)
+	fallback, err := os.ReadFile("testdata/fallback.json")
+	is.NoErr(err)
+	var streamed []bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		stream, _ := body["stream"].(bool)
+		streamed = append(streamed, stream)
+		w.Header().Set("Content-Type", "application/json")
+		// A proxy that blocks streaming
+		if stream {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"error":{"message":"streaming is blocked","type":"invalid_request_error"}}`)
+			return
+		}
+		w.Write(fallback)
+	}))
+	defer server.Close()
+
+	client := New("secret", WithBaseURL(server.URL+"/v1"))
+	var responses []*llm.ChatResponse
+	for res, err := range client.Chat(context.Background(), &llm.ChatRequest{
+		Model:          "gpt-5",
+		Messages:       []*llm.Message{llm.UserMessage("What is 20+22?")},
+		StreamFallback: true,
+	}) {
+		is.NoErr(err)
+		responses = append(responses, res)
+	}
+	is.Equal(streamed, []bool{true, false})
+	is.Equal(len(responses), 5)
+	is.True(responses[0].Start)
+	is.Equal(responses[1].Thinking, "Adding the numbers")
+	is.Equal(responses[2].ToolCall.ID, "call_1")
+	is.Equal(string(responses[2].ToolCall.Arguments), `{"a":20,"b":22}`)
+	is.Equal(responses[2].ToolCall.Reasoning[0].ID, "rs_1")
+	is.Equal(responses[3].Content, "Let me add those")
+	is.True(responses[4].Done)
+	is.Equal(responses[4].Usage.TotalTokens, 20)
+}
+
+func TestToParamsStrictSchema(t *testing.T) {
+	is := is.New(t)
+	params := toParams(&llm.ChatRequest{
+		Model: "gpt-5",
+		ResponseSchema: &llm.ToolFunctionParameters{
+			Type: "object",
+			Properties: map[string]*llm.ToolProperty{
+				"name": {Type: "string", MaxLength: new(int)},
+				"age":  {Type: "integer"},
+				"address": {
+					Type: "object",
+					Properties: map[string]*llm.ToolProperty{
+						"city": {Type: "string"},
+					},
+					Required: []string{"city"},
+				},
+			},
+			Required: []string{"name"},
+		},
+	}, nil)
+	format := params.Text.Format.OfJSONSchema
+	is.Equal(format.Strict.Value, true)
+	is.Equal(format.Schema["additionalProperties"], false)
+	is.Equal(format.Schema["required"], []string{"address", "age", "name"})
+	properties := format.Schema["properties"].(map[string]any)
+	is.Equal(properties["name"].(map[string]any)["type"], "string")
+	is.Equal(properties["name"].(map[string]any)["maxLength"], nil)
+	is.Equal(properties["age"].(map[string]any)["type"], []any{"integer", "null"})
+	address := properties["address"].(map[string]any)
+	is.Equal(address["additionalProperties"], false)
+	is.Equal(address["type"], []any{"object", "null"})
+
+	// Free-form maps can't be strict
+	params = toParams(&llm.ChatRequest{
+		Model: "gpt-5",
+		ResponseSchema: &llm.ToolFunctionParameters{
+			Type: "object",
+			Properties: map[string]*llm.ToolProperty{
+				"labels": {Type: "object", AdditionalProperties: &llm.ToolProperty{Type: "string"}},
+			},
+		},
+	}, nil)
+	is.Equal(params.Text.Format.OfJSONSchema.Strict.Valid(), false)
+}
+
+func TestReasoningReplay(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	fixture, err := os.ReadFile("testdata/reasoning.sse")
+	is.NoErr(err)
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		bodies = append(bodies, body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		if len(bodies) == 1 {
+			w.Write(fixture)
+			return
+		}
+		w.Write([]byte("event: response.completed\n" + `data: {"type":"response.completed","sequence_number":0,"response":{"id":"resp_2","status":"completed"}}` + "\n\n"))
+	}))
+	defer server.Close()
+
+	add := llm.Func("add", "Add two numbers", func(ctx context.Context, in struct {
+		A int `json:"a"`
+		B int `json:"b"`
+	}) (int, error) {
+		return in.A + in.B, nil
+	})
+	provider := New("secret", WithBaseURL(server.URL+"/v1"))
+	for _, err := range llm.New(provider).Chat(ctx, provider.Name(),
+		llm.WithModel("gpt-5"),
+		llm.WithThinking(llm.ThinkingMedium),
+		llm.WithTool(add),
+		llm.WithMessage(llm.UserMessage("What is 20+22?")),
+	) {
+		is.NoErr(err)
+	}
+	is.Equal(len(bodies), 2)
+	is.Equal(bodies[0]["include"], []any{"reasoning.encrypted_content"})
+
+	// The reasoning item is replayed first, then the message and function call
+	input := bodies[1]["input"].([]any)
+	is.Equal(len(input), 5)
+	var items []string
+	for _, item := range input {
+		item := item.(map[string]any)
+		if role, ok := item["role"].(string); ok {
+			items = append(items, role)
+			continue
+		}
+		items = append(items, item["type"].(string))
+	}
+	is.Equal(items, []string{"user", "reasoning", "assistant", "function_call", "function_call_output"})
+	reasoning := input[1].(map[string]any)
+	is.Equal(reasoning["id"], "rs_1")
+	is.Equal(reasoning["encrypted_content"], "gAAAAABencrypted")
+	is.Equal(reasoning["summary"], []any{})
+}
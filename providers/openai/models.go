@@ -9,39 +9,43 @@ import (
 )
 
 // https://developers.openai.com/api/docs/models
+// https://platform.openai.com/docs/pricing
 var meta = map[string]*llm.ModelMeta{
 	// GPT-5.2
-	"gpt-5.2":            model("GPT-5.2", date(2025, time.August, 31), 400_000, 128_000, true),
-	"gpt-5.2-2025-12-11": model("GPT-5.2", date(2025, time.August, 31), 400_000, 128_000, true),
+	"gpt-5.2":            model("GPT-5.2", date(2025, time.August, 31), 400_000, 128_000, true, 1.75, 14).AsDefault(),
+	"gpt-5.2-2025-12-11": model("GPT-5.2", date(2025, time.August, 31), 400_000, 128_000, true, 1.75, 14),
 
 	// GPT-5 mini
-	"gpt-5-mini":            model("GPT-5 mini", date(2024, time.May, 31), 400_000, 128_000, true),
-	"gpt-5-mini-2025-08-07": model("GPT-5 mini", date(2024, time.May, 31), 400_000, 128_000, true),
+	"gpt-5-mini":            model("GPT-5 mini", date(2024, time.May, 31), 400_000, 128_000, true, 0.25, 2),
+	"gpt-5-mini-2025-08-07": model("GPT-5 mini", date(2024, time.May, 31), 400_000, 128_000, true, 0.25, 2),
 
 	// GPT-5 nano
-	"gpt-5-nano":            model("GPT-5 nano", date(2024, time.May, 31), 400_000, 128_000, true),
-	"gpt-5-nano-2025-08-07": model("GPT-5 nano", date(2024, time.May, 31), 400_000, 128_000, true),
+	"gpt-5-nano":            model("GPT-5 nano", date(2024, time.May, 31), 400_000, 128_000, true, 0.05, 0.4),
+	"gpt-5-nano-2025-08-07": model("GPT-5 nano", date(2024, time.May, 31), 400_000, 128_000, true, 0.05, 0.4),
 
 	// GPT-5.2 pro
-	"gpt-5.2-pro":            model("GPT-5.2 pro", date(2025, time.August, 31), 400_000, 128_000, true),
-	"gpt-5.2-pro-2025-12-11": model("GPT-5.2 pro", date(2025, time.August, 31), 400_000, 128_000, true),
+	"gpt-5.2-pro":            model("GPT-5.2 pro", date(2025, time.August, 31), 400_000, 128_000, true, 21, 168),
+	"gpt-5.2-pro-2025-12-11": model("GPT-5.2 pro", date(2025, time.August, 31), 400_000, 128_000, true, 21, 168),
 
 	// GPT-5
-	"gpt-5":            model("GPT-5", date(2024, time.September, 30), 400_000, 128_000, true),
-	"gpt-5-2025-08-07": model("GPT-5", date(2024, time.September, 30), 400_000, 128_000, true),
+	"gpt-5":            model("GPT-5", date(2024, time.September, 30), 400_000, 128_000, true, 1.25, 10),
+	"gpt-5-2025-08-07": model("GPT-5", date(2024, time.September, 30), 400_000, 128_000, true, 1.25, 10),
 
 	// GPT-4.1
-	"gpt-4.1":            model("GPT-4.1", date(2024, time.June, 1), 1_047_576, 32_768, false),
-	"gpt-4.1-2025-04-14": model("GPT-4.1", date(2024, time.June, 1), 1_047_576, 32_768, false),
+	"gpt-4.1":            model("GPT-4.1", date(2024, time.June, 1), 1_047_576, 32_768, false, 2, 8),
+	"gpt-4.1-2025-04-14": model("GPT-4.1", date(2024, time.June, 1), 1_047_576, 32_768, false, 2, 8),
 }
 
-func model(displayName string, knowledgeCutoff time.Time, contextWindow int, maxOutputTokens int, hasReasoning bool) *llm.ModelMeta {
+func model(displayName string, knowledgeCutoff time.Time, contextWindow int, maxOutputTokens int, hasReasoning bool, inputCostPerMTok, outputCostPerMTok float64) *llm.ModelMeta {
 	return &llm.ModelMeta{
 		DisplayName:     displayName,
 		KnowledgeCutoff: knowledgeCutoff,
 		ContextWindow:   contextWindow,
 		MaxOutputTokens: maxOutputTokens,
 		HasReasoning:    hasReasoning,
+
+		InputCostPerMTok:  inputCostPerMTok,
+		OutputCostPerMTok: outputCostPerMTok,
 	}
 }
 
@@ -49,31 +53,70 @@ func date(year int, month time.Month, day int) time.Time {
 	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
 }
 
+// lookupMeta finds the metadata for a model or Azure deployment
+func (c *Client) lookupMeta(id string) *llm.ModelMeta {
+	if model, ok := c.models[id]; ok {
+		return meta[model]
+	}
+	return meta[id]
+}
+
+var _ llm.DefaultModeler = (*Client)(nil)
+
+// DefaultModel returns the recommended model, or the first deployment on Azure
+func (c *Client) DefaultModel() string {
+	if c.name == "azure" {
+		if len(c.deployments) == 0 {
+			return ""
+		}
+		return c.deployments[0].Name
+	}
+	return llm.DefaultModelIn(meta)
+}
+
 // Model retrieves a specific model
 func (c *Client) Model(ctx context.Context, id string) (*llm.Model, error) {
+	// Deployments aren't listed by the models endpoint
+	if _, ok := c.models[id]; ok {
+		return &llm.Model{
+			Provider: c.name,
+			ID:       id,
+			Meta:     c.lookupMeta(id),
+		}, nil
+	}
 	m, err := c.oc.Models.Get(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("openai: getting model %q: %w", id, err)
 	}
 	return &llm.Model{
-		Provider: "openai",
+		Provider: c.name,
 		ID:       m.ID,
-		Meta:     meta[m.ID],
+		Meta:     c.lookupMeta(m.ID),
 	}, nil
 }
 
-// Models lists available models
+// Models lists available models, or the deployments when they're configured
 func (c *Client) Models(ctx context.Context) ([]*llm.Model, error) {
+	var models []*llm.Model
+	if len(c.deployments) > 0 {
+		for _, deployment := range c.deployments {
+			models = append(models, &llm.Model{
+				Provider: c.name,
+				ID:       deployment.Name,
+				Meta:     c.lookupMeta(deployment.Name),
+			})
+		}
+		return models, nil
+	}
 	page, err := c.oc.Models.List(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("openai: listing models: %w", err)
 	}
-	var models []*llm.Model
 	for _, m := range page.Data {
 		models = append(models, &llm.Model{
-			Provider: "openai",
+			Provider: c.name,
 			ID:       m.ID,
-			Meta:     meta[m.ID],
+			Meta:     c.lookupMeta(m.ID),
 		})
 	}
 	return models, nil
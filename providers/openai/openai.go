@@ -2,6 +2,7 @@ package openai
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"iter"
@@ -14,17 +15,63 @@ import (
 	"github.com/openai/openai-go/shared"
 )
 
+// Option configures the client
+type Option func(*config)
+
+type config struct {
+	baseURL  string
+	stateful bool
+}
+
+// WithBaseURL sends requests to another base URL, like a self-hosted gateway
+// or proxy. An empty URL keeps the default.
+func WithBaseURL(url string) Option {
+	return func(c *config) {
+		c.baseURL = url
+	}
+}
+
+// WithStatefulResponses continues each conversation from the response OpenAI
+// stored for the previous turn, sending only the new messages. This saves
+// input tokens on long chats. By default the full history is sent each time.
+func WithStatefulResponses(enabled bool) Option {
+	return func(c *config) {
+		c.stateful = enabled
+	}
+}
+
 // New creates a new OpenAI client
-func New(apiKey string) *Client {
-	oc := openai.NewClient(option.WithAPIKey(apiKey))
-	return &Client{
-		&oc,
+func New(apiKey string, options ...Option) *Client {
+	config := new(config)
+	for _, option := range options {
+		option(config)
+	}
+	requestOptions := []option.RequestOption{option.WithAPIKey(apiKey)}
+	if config.baseURL != "" {
+		requestOptions = append(requestOptions, option.WithBaseURL(config.baseURL))
+	}
+	oc := openai.NewClient(requestOptions...)
+	client := &Client{
+		oc:   &oc,
+		name: "openai",
 	}
+	if config.stateful {
+		client.stored = new(responseIDs)
+	}
+	return client
 }
 
 // Client implements the llm.Provider interface for OpenAI
 type Client struct {
-	oc *openai.Client
+	oc   *openai.Client
+	name string
+
+	// Azure deployments and the models they serve
+	deployments []*Deployment
+	models      map[string]string
+
+	// Stored responses to continue from, when stateful
+	stored *responseIDs
 }
 
 var _ llm.Provider = (*Client)(nil)
@@ -44,7 +91,7 @@ func reasoningEffort(level llm.Thinking) shared.ReasoningEffort {
 }
 
 func (c *Client) Name() string {
-	return "openai"
+	return c.name
 }
 
 func toUsage(usage responses.ResponseUsage) *llm.Usage {
@@ -54,6 +101,7 @@ func toUsage(usage responses.ResponseUsage) *llm.Usage {
 		TotalTokens:       int(usage.TotalTokens),
 		CachedInputTokens: int(usage.InputTokensDetails.CachedTokens),
 		ReasoningTokens:   int(usage.OutputTokensDetails.ReasoningTokens),
+		CacheReadTokens:   int(usage.InputTokensDetails.CachedTokens),
 	}
 }
 
@@ -68,171 +116,558 @@ func toOpenAISchema(prop *llm.ToolProperty) map[string]any {
 	if prop.Items != nil {
 		p["items"] = toOpenAISchema(prop.Items)
 	}
+	if len(prop.Properties) > 0 {
+		props := make(map[string]any)
+		for name, child := range prop.Properties {
+			props[name] = toOpenAISchema(child)
+		}
+		p["properties"] = props
+		p["required"] = prop.Required
+	}
+	if prop.AdditionalProperties != nil {
+		p["additionalProperties"] = toOpenAISchema(prop.AdditionalProperties)
+	}
+	if prop.Minimum != nil {
+		p["minimum"] = *prop.Minimum
+	}
+	if prop.Maximum != nil {
+		p["maximum"] = *prop.Maximum
+	}
+	if prop.MinLength != nil {
+		p["minLength"] = *prop.MinLength
+	}
+	if prop.MaxLength != nil {
+		p["maxLength"] = *prop.MaxLength
+	}
+	if prop.Pattern != "" {
+		p["pattern"] = prop.Pattern
+	}
+	if prop.Default != nil {
+		p["default"] = prop.Default
+	}
 	return p
 }
 
+func toOpenAIParameters(params *llm.ToolFunctionParameters) map[string]any {
+	props := make(map[string]any)
+	for name, prop := range params.Properties {
+		props[name] = toOpenAISchema(prop)
+	}
+	return map[string]any{
+		"type":       params.Type,
+		"properties": props,
+		"required":   params.Required,
+	}
+}
+
+// toContentList converts a user message's content and parts into input content
+func toContentList(m *llm.Message) (content responses.ResponseInputMessageContentListParam) {
+	if m.Content != "" {
+		content = append(content, responses.ResponseInputContentUnionParam{
+			OfInputText: &responses.ResponseInputTextParam{Text: m.Content},
+		})
+	}
+	for _, part := range m.Parts {
+		switch part.Type {
+		case "text":
+			content = append(content, responses.ResponseInputContentUnionParam{
+				OfInputText: &responses.ResponseInputTextParam{Text: part.Text},
+			})
+		case "image":
+			url := part.URL
+			if url == "" {
+				url = "data:" + part.MediaType + ";base64," + base64.StdEncoding.EncodeToString(part.Data)
+			}
+			content = append(content, responses.ResponseInputContentUnionParam{
+				OfInputImage: &responses.ResponseInputImageParam{
+					Detail:   responses.ResponseInputImageDetailAuto,
+					ImageURL: openai.String(url),
+				},
+			})
+		}
+	}
+	return content
+}
+
+// toInput converts messages into Responses API input items. An assistant turn
+// is replayed in the order the model produced it: the reasoning items first,
+// then the message item, then its function call items. Function call outputs
+// can only be text, so images from tool results follow the outputs in a user
+// message.
+func toInput(messages []*llm.Message) (input []responses.ResponseInputItemUnionParam) {
+	// Reasoned is set once the current assistant turn's reasoning is replayed
+	reasoned := false
+	images := &llm.Message{Role: "user"}
+	for i, m := range messages {
+		if m.Role != "assistant" {
+			reasoned = false
+		}
+		if m.Role != "tool" && len(images.Parts) > 0 {
+			input = append(input, responses.ResponseInputItemParamOfMessage(toContentList(images), responses.EasyInputMessageRoleUser))
+			images.Parts = nil
+		}
+		switch m.Role {
+		case "user":
+			if len(m.Parts) > 0 {
+				input = append(input, responses.ResponseInputItemParamOfMessage(toContentList(m), responses.EasyInputMessageRoleUser))
+				continue
+			}
+			input = append(input, responses.ResponseInputItemParamOfMessage(m.Content, responses.EasyInputMessageRoleUser))
+		case "assistant":
+			if !reasoned {
+				reasoned = true
+				input = append(input, toReasoning(messages[i:])...)
+			}
+			if m.Content != "" {
+				input = append(input, responses.ResponseInputItemParamOfMessage(m.Content, responses.EasyInputMessageRoleAssistant))
+			}
+			// Include function call if present
+			if m.ToolCall != nil {
+				input = append(input, responses.ResponseInputItemUnionParam{
+					OfFunctionCall: &responses.ResponseFunctionToolCallParam{
+						CallID:    m.ToolCall.ID,
+						Name:      m.ToolCall.Name,
+						Arguments: string(m.ToolCall.Arguments),
+					},
+				})
+			}
+		case "system":
+			input = append(input, responses.ResponseInputItemParamOfMessage(m.Content, responses.EasyInputMessageRoleSystem))
+		case "tool":
+			// Tool results use function call output
+			input = append(input, responses.ResponseInputItemParamOfFunctionCallOutput(m.ToolCallID, m.Content))
+			images.Parts = append(images.Parts, m.Parts...)
+		}
+	}
+	if len(images.Parts) > 0 {
+		input = append(input, responses.ResponseInputItemParamOfMessage(toContentList(images), responses.EasyInputMessageRoleUser))
+	}
+	return input
+}
+
+// toReasoning collects the reasoning items carried by the tool calls of the
+// assistant turn at the start of messages
+func toReasoning(messages []*llm.Message) (input []responses.ResponseInputItemUnionParam) {
+	for _, m := range messages {
+		if m.Role != "assistant" {
+			break
+		}
+		if m.ToolCall == nil {
+			continue
+		}
+		for _, reasoning := range m.ToolCall.Reasoning {
+			item := responses.ResponseInputItemParamOfReasoning(reasoning.ID, []responses.ResponseReasoningItemSummaryParam{})
+			if reasoning.EncryptedContent != "" {
+				item.OfReasoning.EncryptedContent = openai.String(reasoning.EncryptedContent)
+			}
+			input = append(input, item)
+		}
+	}
+	return input
+}
+
+// toParams converts a chat request into Responses API params
+func toParams(req *llm.ChatRequest, meta *llm.ModelMeta) responses.ResponseNewParams {
+	input := toInput(req.Messages)
+
+	// Convert tools to Responses API format
+	var tools []responses.ToolUnionParam
+	for _, t := range req.Tools {
+		tool := responses.ToolParamOfFunction(
+			t.Function.Name,
+			toOpenAIParameters(t.Function.Parameters),
+			false,
+		)
+		tool.OfFunction.Description = openai.String(t.Function.Description)
+		tools = append(tools, tool)
+	}
+
+	params := responses.ResponseNewParams{
+		Model: shared.ResponsesModel(req.Model),
+		Input: responses.ResponseNewParamsInputUnion{
+			OfInputItemList: input,
+		},
+	}
+
+	if len(tools) > 0 {
+		params.Tools = tools
+	}
+
+	switch req.ToolChoice.Mode {
+	case "auto":
+		params.ToolChoice.OfToolChoiceMode = openai.Opt(responses.ToolChoiceOptionsAuto)
+	case "none":
+		params.ToolChoice.OfToolChoiceMode = openai.Opt(responses.ToolChoiceOptionsNone)
+	case "required":
+		params.ToolChoice.OfToolChoiceMode = openai.Opt(responses.ToolChoiceOptionsRequired)
+	case "tool":
+		params.ToolChoice.OfFunctionTool = &responses.ToolChoiceFunctionParam{Name: req.ToolChoice.Name}
+	}
+
+	if req.MaxOutputTokens > 0 {
+		params.MaxOutputTokens = openai.Int(int64(meta.ClampOutputTokens(req.MaxOutputTokens)))
+	}
+
+	// The safety identifier replaces the deprecated user field
+	if req.EndUser != "" {
+		params.SafetyIdentifier = openai.String(req.EndUser)
+	}
+
+	// Constrain the output to the response schema
+	if req.ResponseSchema != nil {
+		format := responses.ResponseFormatTextConfigParamOfJSONSchema("response", toOpenAIParameters(req.ResponseSchema))
+		// Strict mode guarantees the output matches the schema, rather than
+		// treating it as a suggestion
+		if schema, ok := strictSchema(format.OfJSONSchema.Schema); ok {
+			format.OfJSONSchema.Schema = schema
+			format.OfJSONSchema.Strict = openai.Bool(true)
+		}
+		params.Text = responses.ResponseTextConfigParam{
+			Format: format,
+		}
+	}
+
+	// Configure reasoning for o-series models. The encrypted reasoning is
+	// replayed with the tool calls that follow it, so the model keeps its
+	// train of thought without relying on stored responses.
+	if req.Thinking != "" {
+		params.Reasoning = shared.ReasoningParam{
+			Effort:  reasoningEffort(req.Thinking),
+			Summary: shared.ReasoningSummaryDetailed,
+		}
+		params.Include = []responses.ResponseIncludable{responses.ResponseIncludableReasoningEncryptedContent}
+	}
+
+	return params
+}
+
+// requestOptions sets the request's provider params on the request body
+func requestOptions(req *llm.ChatRequest) (options []option.RequestOption) {
+	for key, value := range req.ProviderParams {
+		options = append(options, option.WithJSONSet(key, value))
+	}
+	return options
+}
+
 // Chat sends a chat request to OpenAI using the Responses API
 func (c *Client) Chat(ctx context.Context, req *llm.ChatRequest) iter.Seq2[*llm.ChatResponse, error] {
 	return func(yield func(*llm.ChatResponse, error) bool) {
-		model := req.Model
-		if model == "" {
+		if req.Model == "" {
 			yield(nil, fmt.Errorf("openai: required model is empty"))
 			return
 		}
 
-		// Convert messages to Responses API input format
-		var input []responses.ResponseInputItemUnionParam
-		for _, m := range req.Messages {
-			switch m.Role {
-			case "user":
-				input = append(input, responses.ResponseInputItemParamOfMessage(m.Content, responses.EasyInputMessageRoleUser))
-			case "assistant":
-				if m.Content != "" {
-					input = append(input, responses.ResponseInputItemParamOfMessage(m.Content, responses.EasyInputMessageRoleAssistant))
-				}
-				// Include function call if present
-				if m.ToolCall != nil {
-					input = append(input, responses.ResponseInputItemUnionParam{
-						OfFunctionCall: &responses.ResponseFunctionToolCallParam{
-							CallID:    m.ToolCall.ID,
-							Name:      m.ToolCall.Name,
-							Arguments: string(m.ToolCall.Arguments),
-						},
-					})
-				}
-			case "system":
-				input = append(input, responses.ResponseInputItemParamOfMessage(m.Content, responses.EasyInputMessageRoleSystem))
-			case "tool":
-				// Tool results use function call output
-				input = append(input, responses.ResponseInputItemParamOfFunctionCallOutput(m.ToolCallID, m.Content))
+		params := toParams(req, c.lookupMeta(req.Model))
+		continued := c.stored != nil && c.continueFrom(&params, req.Messages)
+		err := c.stream(ctx, req, params, yield)
+		if err != nil && continued && staleResponse(err) {
+			// The stored response may have expired, so send the full history
+			params = toParams(req, c.lookupMeta(req.Model))
+			err = c.stream(ctx, req, params, yield)
+		}
+		if err != nil {
+			if req.StreamFallback {
+				c.fallback(ctx, req, params, newStopper(req.StopSequences), yield)
+				return
 			}
+			yield(nil, fmt.Errorf("openai: streaming: %w", err))
 		}
+	}
+}
 
-		// Convert tools to Responses API format
-		var tools []responses.ToolUnionParam
-		for _, t := range req.Tools {
-			props := make(map[string]any)
-			for name, prop := range t.Function.Parameters.Properties {
-				props[name] = toOpenAISchema(prop)
-			}
+// stream the response. Errors before the stream is established are returned
+// so the caller can retry, later ones are yielded.
+func (c *Client) stream(ctx context.Context, req *llm.ChatRequest, params responses.ResponseNewParams, yield func(*llm.ChatResponse, error) bool) error {
+	stream := c.oc.Responses.NewStreaming(ctx, params, requestOptions(req)...)
+	defer stream.Close()
 
-			tool := responses.ToolParamOfFunction(
-				t.Function.Name,
-				map[string]any{
-					"type":       t.Function.Parameters.Type,
-					"properties": props,
-					"required":   t.Function.Parameters.Required,
-				},
-				false,
-			)
-			tool.OfFunction.Description = openai.String(t.Function.Description)
-			tools = append(tools, tool)
-		}
+	// Record the reply to key the stored response by
+	var reply []*llm.Message
+	yield = record(&reply, yield)
 
-		params := responses.ResponseNewParams{
-			Model: shared.ResponsesModel(model),
-			Input: responses.ResponseNewParamsInputUnion{
-				OfInputItemList: input,
-			},
-		}
+	// Stop sequences are applied to the text as it streams
+	stop := newStopper(req.StopSequences)
+
+	// Track function call state across streaming events
+	var currentFunctionCall *llm.ToolCall
+	var functionArgs strings.Builder
+
+	// Reasoning items are attached to the next function call
+	var reasoning []*llm.ReasoningItem
+
+	established := false
+	for stream.Next() {
+		established = true
+		event := stream.Current()
+		req.Raw(event)
 
-		if len(tools) > 0 {
-			params.Tools = tools
+		// Once a stop sequence ends the text, the rest of the output is dropped
+		// but we keep reading until the response completes to get the usage
+		if stop.stopped && event.Type != "response.completed" && event.Type != "response.incomplete" && event.Type != "response.failed" {
+			continue
 		}
 
-		// Configure reasoning for o-series models
-		if req.Thinking != "" {
-			params.Reasoning = shared.ReasoningParam{
-				Effort:  reasoningEffort(req.Thinking),
-				Summary: shared.ReasoningSummaryDetailed,
+		switch event.Type {
+		case "response.created":
+			if !yield(&llm.ChatResponse{
+				Role:  "assistant",
+				Start: true,
+			}, nil) {
+				return nil
 			}
-		}
 
-		stream := c.oc.Responses.NewStreaming(ctx, params)
+		case "response.output_text.delta":
+			// Text content delta
+			delta := event.AsResponseOutputTextDelta()
+			text, stopped := stop.Write(delta.Delta)
+			if text != "" {
+				if !yield(&llm.ChatResponse{
+					Role:    "assistant",
+					Content: text,
+				}, nil) {
+					return nil
+				}
+			}
+			if stopped {
+				continue
+			}
 
-		// Track function call state across streaming events
-		var currentFunctionCall *llm.ToolCall
-		var functionArgs strings.Builder
+		case "response.output_text.done":
+			// Send any text held back while checking for stop sequences
+			if text := stop.Flush(); text != "" {
+				if !yield(&llm.ChatResponse{
+					Role:    "assistant",
+					Content: text,
+				}, nil) {
+					return nil
+				}
+			}
 
-		for stream.Next() {
-			event := stream.Current()
+		case "response.reasoning_summary_text.delta":
+			// Reasoning/thinking content delta
+			delta := event.AsResponseReasoningSummaryTextDelta()
+			if delta.Delta != "" {
+				if !yield(&llm.ChatResponse{
+					Role:     "assistant",
+					Thinking: delta.Delta,
+				}, nil) {
+					return nil
+				}
+			}
 
-			switch event.Type {
-			case "response.output_text.delta":
-				// Text content delta
-				delta := event.AsResponseOutputTextDelta()
-				if delta.Delta != "" {
-					if !yield(&llm.ChatResponse{
-						Role:    "assistant",
-						Content: delta.Delta,
-					}, nil) {
-						return
-					}
+		case "response.output_item.added":
+			// New output item - check if it's a function call
+			added := event.AsResponseOutputItemAdded()
+			if added.Item.Type == "function_call" {
+				currentFunctionCall = &llm.ToolCall{
+					ID:   added.Item.CallID,
+					Name: added.Item.Name,
 				}
+				functionArgs.Reset()
+			}
 
-			case "response.reasoning_summary_text.delta":
-				// Reasoning/thinking content delta
-				delta := event.AsResponseReasoningSummaryTextDelta()
-				if delta.Delta != "" {
-					if !yield(&llm.ChatResponse{
-						Role:     "assistant",
-						Thinking: delta.Delta,
-					}, nil) {
-						return
-					}
+		case "response.function_call_arguments.delta":
+			// Function call arguments delta
+			delta := event.AsResponseFunctionCallArgumentsDelta()
+			functionArgs.WriteString(delta.Delta)
+
+		case "response.output_item.done":
+			// Output item completed - if function call, emit it
+			done := event.AsResponseOutputItemDone()
+			if done.Item.Type == "reasoning" {
+				reasoning = append(reasoning, &llm.ReasoningItem{
+					ID:               done.Item.ID,
+					EncryptedContent: done.Item.EncryptedContent,
+				})
+			}
+			if done.Item.Type == "function_call" && currentFunctionCall != nil {
+				currentFunctionCall.Arguments = json.RawMessage(functionArgs.String())
+				currentFunctionCall.Reasoning, reasoning = reasoning, nil
+				if !yield(&llm.ChatResponse{
+					Role:     "assistant",
+					ToolCall: currentFunctionCall,
+				}, nil) {
+					return nil
 				}
+				currentFunctionCall = nil
+			}
 
-			case "response.output_item.added":
-				// New output item - check if it's a function call
-				added := event.AsResponseOutputItemAdded()
-				if added.Item.Type == "function_call" {
-					currentFunctionCall = &llm.ToolCall{
-						ID:   added.Item.CallID,
-						Name: added.Item.Name,
-					}
-					functionArgs.Reset()
+		case "response.completed":
+			// Response complete
+			completed := event.AsResponseCompleted()
+			if c.stored != nil {
+				c.stored.set(req.Messages, reply, completed.Response.ID)
+			}
+			if !yield(&llm.ChatResponse{
+				Role:  "assistant",
+				Done:  true,
+				Usage: toUsage(completed.Response.Usage),
+			}, nil) {
+				return nil
+			}
+
+		case "response.incomplete":
+			// Response was cut short, like by the output token limit
+			incomplete := event.AsResponseIncomplete()
+			if text := stop.Flush(); text != "" {
+				if !yield(&llm.ChatResponse{
+					Role:    "assistant",
+					Content: text,
+				}, nil) {
+					return nil
 				}
+			}
+			if c.stored != nil {
+				c.stored.set(req.Messages, reply, incomplete.Response.ID)
+			}
+			// A stop sequence ended the text before the response was cut short
+			reason := incomplete.Response.IncompleteDetails.Reason
+			if stop.stopped {
+				reason = ""
+			}
+			yield(&llm.ChatResponse{
+				Role:       "assistant",
+				Done:       true,
+				StopReason: reason,
+				Usage:      toUsage(incomplete.Response.Usage),
+			}, nil)
+			return nil
+
+		case "response.refusal.done":
+			// Model refused to answer
+			refusal := event.AsResponseRefusalDone()
+			yield(nil, fmt.Errorf("openai: model refused: %s", refusal.Refusal))
+			return nil
 
-			case "response.function_call_arguments.delta":
-				// Function call arguments delta
-				delta := event.AsResponseFunctionCallArgumentsDelta()
-				functionArgs.WriteString(delta.Delta)
+		case "response.failed":
+			// Handle failure
+			failed := event.AsResponseFailed()
+			yield(nil, fmt.Errorf("openai: response failed: %s", failed.Response.Status))
+			return nil
+		}
+	}
 
-			case "response.output_item.done":
-				// Output item completed - if function call, emit it
-				done := event.AsResponseOutputItemDone()
-				if done.Item.Type == "function_call" && currentFunctionCall != nil {
-					currentFunctionCall.Arguments = json.RawMessage(functionArgs.String())
+	if err := stream.Err(); err != nil {
+		if !established {
+			return err
+		}
+		yield(nil, fmt.Errorf("openai: streaming: %w", err))
+	}
+	return nil
+}
+
+var _ llm.Embedder = (*Client)(nil)
+
+// Embed embeds each input as a vector
+func (c *Client) Embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	res, err := c.oc.Embeddings.New(ctx, openai.EmbeddingNewParams{
+		Model: openai.EmbeddingModel(model),
+		Input: openai.EmbeddingNewParamsInputUnion{
+			OfArrayOfStrings: inputs,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("openai: embedding: %w", err)
+	}
+	vectors := make([][]float32, len(inputs))
+	for _, embedding := range res.Data {
+		if embedding.Index < 0 || int(embedding.Index) >= len(vectors) {
+			return nil, fmt.Errorf("openai: embedding index %d out of range", embedding.Index)
+		}
+		vector := make([]float32, len(embedding.Embedding))
+		for i, value := range embedding.Embedding {
+			vector[i] = float32(value)
+		}
+		vectors[embedding.Index] = vector
+	}
+	return vectors, nil
+}
+
+// fallback sends a non-streaming request and synthesizes the stream events
+func (c *Client) fallback(ctx context.Context, req *llm.ChatRequest, params responses.ResponseNewParams, stop *stopper, yield func(*llm.ChatResponse, error) bool) {
+	res, err := c.oc.Responses.New(ctx, params, requestOptions(req)...)
+	if err != nil {
+		yield(nil, fmt.Errorf("openai: non-streaming fallback: %w", err))
+		return
+	}
+	req.Raw(res)
+	if !yield(&llm.ChatResponse{Role: "assistant", Start: true}, nil) {
+		return
+	}
+
+	// Record the reply to key the stored response by
+	var reply []*llm.Message
+	yield = record(&reply, yield)
+
+	var reasoning []*llm.ReasoningItem
+output:
+	for _, item := range res.Output {
+		switch item.Type {
+		case "reasoning":
+			reasoning = append(reasoning, &llm.ReasoningItem{
+				ID:               item.ID,
+				EncryptedContent: item.EncryptedContent,
+			})
+			for _, summary := range item.Summary {
+				if !yield(&llm.ChatResponse{
+					Role:     "assistant",
+					Thinking: summary.Text,
+				}, nil) {
+					return
+				}
+			}
+		case "message":
+			for _, content := range item.Content {
+				if content.Type == "refusal" {
+					yield(nil, fmt.Errorf("openai: model refused: %s", content.Refusal))
+					return
+				}
+				if content.Type != "output_text" {
+					continue
+				}
+				text, stopped := stop.Write(content.Text)
+				if !stopped {
+					text += stop.Flush()
+				}
+				if text != "" {
 					if !yield(&llm.ChatResponse{
-						Role:     "assistant",
-						ToolCall: currentFunctionCall,
+						Role:    "assistant",
+						Content: text,
 					}, nil) {
 						return
 					}
-					currentFunctionCall = nil
 				}
-
-			case "response.completed":
-				// Response complete
-				completed := event.AsResponseCompleted()
-				if !yield(&llm.ChatResponse{
-					Role:  "assistant",
-					Done:  true,
-					Usage: toUsage(completed.Response.Usage),
-				}, nil) {
-					return
+				// The rest of the output comes after the stop sequence
+				if stopped {
+					break output
 				}
-
-			case "response.failed":
-				// Handle failure
-				failed := event.AsResponseFailed()
-				yield(nil, fmt.Errorf("openai: response failed: %s", failed.Response.Status))
+			}
+		case "function_call":
+			call := &llm.ToolCall{
+				ID:        item.CallID,
+				Name:      item.Name,
+				Arguments: json.RawMessage(item.Arguments),
+			}
+			call.Reasoning, reasoning = reasoning, nil
+			if !yield(&llm.ChatResponse{
+				Role:     "assistant",
+				ToolCall: call,
+			}, nil) {
 				return
 			}
 		}
-
-		if err := stream.Err(); err != nil {
-			yield(nil, fmt.Errorf("openai: streaming: %w", err))
-		}
 	}
+	if c.stored != nil {
+		c.stored.set(req.Messages, reply, res.ID)
+	}
+	reason := res.IncompleteDetails.Reason
+	if stop.stopped {
+		reason = ""
+	}
+	yield(&llm.ChatResponse{
+		Role:       "assistant",
+		Done:       true,
+		StopReason: reason,
+		Usage:      toUsage(res.Usage),
+	}, nil)
 }
@@ -0,0 +1,134 @@
+package openai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/matthewmueller/llm"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/responses"
+)
+
+// Keep at most this many conversations before starting over
+const maxResponses = 1024
+
+// responseIDs remembers the stored response for each history, keyed by the
+// history up to and including the model's reply, so the next turn can
+// continue from it instead of resending everything
+type responseIDs struct {
+	mu  sync.Mutex
+	ids map[string]string
+}
+
+func (r *responseIDs) get(messages []*llm.Message) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ids[historyKey(messages)]
+}
+
+// set the stored response for the request's messages and the reply to them
+func (r *responseIDs) set(messages, reply []*llm.Message, id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ids == nil || len(r.ids) >= maxResponses {
+		r.ids = map[string]string{}
+	}
+	r.ids[historyKey(append(messages[:len(messages):len(messages)], reply...))] = id
+}
+
+// record wraps yield to collect the reply as it's sent
+func record(reply *[]*llm.Message, yield func(*llm.ChatResponse, error) bool) func(*llm.ChatResponse, error) bool {
+	return func(res *llm.ChatResponse, err error) bool {
+		if res != nil && (res.Content != "" || res.ToolCall != nil) {
+			*reply = append(*reply, &llm.Message{Role: "assistant", Content: res.Content, ToolCall: res.ToolCall})
+		}
+		return yield(res, err)
+	}
+}
+
+// historyKey identifies a history by its messages. Each run of assistant
+// messages is joined into one entry, since callers record replies differently:
+// Client.Chat keeps each streamed chunk while Agent joins them into one message.
+func historyKey(messages []*llm.Message) string {
+	type entry struct {
+		Role       string             `json:"role"`
+		Content    string             `json:"content,omitzero"`
+		Parts      []*llm.ContentPart `json:"parts,omitzero"`
+		ToolCallID string             `json:"tool_call_id,omitzero"`
+		ToolCalls  []string           `json:"tool_calls,omitzero"`
+	}
+	entries := make([]entry, 0, len(messages))
+	var reply *entry
+	for _, m := range messages {
+		if m.Role != "assistant" {
+			reply = nil
+			entries = append(entries, entry{
+				Role:       m.Role,
+				Content:    m.Content,
+				Parts:      m.Parts,
+				ToolCallID: m.ToolCallID,
+			})
+			continue
+		}
+		if reply == nil {
+			entries = append(entries, entry{Role: m.Role})
+			reply = &entries[len(entries)-1]
+		}
+		reply.Content += m.Content
+		if m.ToolCall != nil {
+			reply.ToolCalls = append(reply.ToolCalls, m.ToolCall.ID)
+		}
+	}
+	data, _ := json.Marshal(entries)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// splitTurn splits the messages into the history up to and including the
+// last assistant reply and the new messages that follow that reply. It
+// returns false when there's no earlier reply to continue from.
+func splitTurn(messages []*llm.Message) (history, turn []*llm.Message, ok bool) {
+	end := len(messages) - 1
+	for end >= 0 && messages[end].Role != "assistant" {
+		end--
+	}
+	if end < 0 || end == len(messages)-1 {
+		return nil, nil, false
+	}
+	return messages[:end+1], messages[end+1:], true
+}
+
+// continueFrom sends only the new turn when the previous reply is stored on
+// OpenAI's side. It returns false when the full history is sent instead.
+func (c *Client) continueFrom(params *responses.ResponseNewParams, messages []*llm.Message) bool {
+	history, turn, ok := splitTurn(messages)
+	if !ok {
+		return false
+	}
+	id := c.stored.get(history)
+	if id == "" {
+		return false
+	}
+	params.Input = responses.ResponseNewParamsInputUnion{OfInputItemList: toInput(turn)}
+	params.PreviousResponseID = openai.String(id)
+	return true
+}
+
+// staleResponse returns true when the previous response can't be continued
+// from, like when it expired. Other errors, like rate limits, would fail the
+// same way with the full history.
+func staleResponse(err error) bool {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.StatusCode != http.StatusNotFound && apiErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	return apiErr.Param == "previous_response_id" || strings.Contains(strings.ToLower(apiErr.Message), "previous response")
+}
@@ -0,0 +1,67 @@
+package openai
+
+import "strings"
+
+// stopper ends the text at the first stop sequence. The Responses API doesn't
+// support stop sequences, so they're applied as the text streams in.
+type stopper struct {
+	seqs    []string
+	held    string // Text held back since it may start a stop sequence
+	stopped bool
+}
+
+func newStopper(seqs []string) *stopper {
+	s := &stopper{}
+	for _, seq := range seqs {
+		if seq != "" {
+			s.seqs = append(s.seqs, seq)
+		}
+	}
+	return s
+}
+
+// Write adds a delta and returns the text that's safe to send. Text that may
+// be the start of a stop sequence is held back until we know. Only the held
+// back text and the delta are scanned, since a stop sequence can't start in
+// text that's already been sent.
+func (s *stopper) Write(delta string) (text string, stopped bool) {
+	if s.stopped {
+		return "", true
+	}
+	text = s.held + delta
+	end := -1
+	for _, seq := range s.seqs {
+		if i := strings.Index(text, seq); i >= 0 && (end < 0 || i < end) {
+			end = i
+		}
+	}
+	if end >= 0 {
+		s.stopped = true
+		s.held = ""
+		return text[:end], true
+	}
+	end = len(text) - s.partial(text)
+	s.held = text[end:]
+	return text[:end], false
+}
+
+// Flush returns the held back text once the text is complete
+func (s *stopper) Flush() string {
+	text := s.held
+	s.held = ""
+	return text
+}
+
+// partial returns the length of the longest suffix of the text that's the
+// start of a stop sequence
+func (s *stopper) partial(text string) (n int) {
+	for _, seq := range s.seqs {
+		for k := min(len(seq)-1, len(text)); k > n; k-- {
+			if strings.HasSuffix(text, seq[:k]) {
+				n = k
+				break
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,56 @@
+package openai
+
+import (
+	"maps"
+	"slices"
+)
+
+// strictSchema rewrites the schema so it can be sent with strict: true.
+// Strict mode requires every object to list all of its properties as required
+// and disallow additional ones, so optional properties become nullable
+// instead. Keywords strict mode doesn't support are dropped. Schemas with
+// free-form maps can't be made strict, so ok is false for them.
+func strictSchema(schema map[string]any) (strict map[string]any, ok bool) {
+	strict = make(map[string]any, len(schema))
+	for key, value := range schema {
+		switch key {
+		case "default", "minLength", "maxLength":
+			continue
+		case "additionalProperties":
+			return nil, false
+		}
+		strict[key] = value
+	}
+	if items, isSchema := schema["items"].(map[string]any); isSchema {
+		if strict["items"], ok = strictSchema(items); !ok {
+			return nil, false
+		}
+	}
+	properties, isObject := schema["properties"].(map[string]any)
+	if !isObject {
+		return strict, true
+	}
+	required := map[string]bool{}
+	names, _ := schema["required"].([]string)
+	for _, name := range names {
+		required[name] = true
+	}
+	strictProperties := make(map[string]any, len(properties))
+	for name, property := range properties {
+		property, isSchema := property.(map[string]any)
+		if !isSchema {
+			return nil, false
+		}
+		if property, ok = strictSchema(property); !ok {
+			return nil, false
+		}
+		if !required[name] {
+			property["type"] = []any{property["type"], "null"}
+		}
+		strictProperties[name] = property
+	}
+	strict["properties"] = strictProperties
+	strict["required"] = slices.Sorted(maps.Keys(properties))
+	strict["additionalProperties"] = false
+	return strict, true
+}
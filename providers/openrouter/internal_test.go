@@ -0,0 +1,66 @@
+package openrouter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func TestModelsCatalog(t *testing.T) {
+	is := is.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.URL.Path, "/models")
+		is.Equal(r.Header.Get("Authorization"), "Bearer secret")
+		fmt.Fprint(w, `{"data":[
+			{"id":"anthropic/claude-sonnet-4","name":"Anthropic: Claude Sonnet 4","context_length":200000,"pricing":{"prompt":"0.000003","completion":"0.000015"},"top_provider":{"max_completion_tokens":64000},"supported_parameters":["tools","reasoning"]},
+			{"id":"openrouter/auto","name":"Auto Router","context_length":2000000,"pricing":{"prompt":"-1","completion":"-1"},"top_provider":{}}
+		]}`)
+	}))
+	defer server.Close()
+
+	c := New("secret")
+	c.baseURL = server.URL
+	models, err := c.Models(context.Background())
+	is.NoErr(err)
+	is.Equal(len(models), 2)
+	is.Equal(models[0].Provider, "openrouter")
+	is.Equal(models[0].ID, "anthropic/claude-sonnet-4")
+	is.Equal(models[0].Meta.ContextWindow, 200_000)
+	is.Equal(models[0].Meta.MaxOutputTokens, 64_000)
+	is.True(models[0].Meta.HasReasoning)
+	is.True(models[0].Meta.InputCostPerMTok > 2.99 && models[0].Meta.InputCostPerMTok < 3.01)
+	is.Equal(models[1].Meta.InputCostPerMTok, 0.0)
+	is.True(!models[1].Meta.HasReasoning)
+
+	// The catalog is the source of metadata for chat requests
+	is.Equal(c.lookupMeta("anthropic/claude-sonnet-4"), models[0].Meta)
+	is.Equal(c.lookupMeta("missing"), (*llm.ModelMeta)(nil))
+}
+
+func TestModelCached(t *testing.T) {
+	is := is.New(t)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"data":[{"id":"anthropic/claude-sonnet-4","name":"Anthropic: Claude Sonnet 4"}]}`)
+	}))
+	defer server.Close()
+
+	c := New("secret")
+	c.baseURL = server.URL
+	for range 3 {
+		m, err := c.Model(context.Background(), "anthropic/claude-sonnet-4")
+		is.NoErr(err)
+		is.Equal(m.ID, "anthropic/claude-sonnet-4")
+	}
+	_, err := c.Model(context.Background(), "missing")
+	is.True(err != nil)
+
+	// The catalog is only downloaded once
+	is.Equal(requests, 1)
+}
@@ -0,0 +1,115 @@
+package openrouter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/matthewmueller/llm"
+)
+
+// model is an entry in OpenRouter's model catalog
+// https://openrouter.ai/docs/api-reference/list-available-models
+type model struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	ContextLength int    `json:"context_length"`
+	Pricing       struct {
+		Prompt     string `json:"prompt"`
+		Completion string `json:"completion"`
+	} `json:"pricing"`
+	TopProvider struct {
+		MaxCompletionTokens int `json:"max_completion_tokens"`
+	} `json:"top_provider"`
+	SupportedParameters []string `json:"supported_parameters"`
+}
+
+// toMeta converts a catalog entry into model metadata. OpenRouter doesn't
+// publish knowledge cutoffs.
+func toMeta(m *model) *llm.ModelMeta {
+	return &llm.ModelMeta{
+		DisplayName:       m.Name,
+		ContextWindow:     m.ContextLength,
+		MaxOutputTokens:   m.TopProvider.MaxCompletionTokens,
+		HasReasoning:      slices.Contains(m.SupportedParameters, "reasoning"),
+		InputCostPerMTok:  perMTok(m.Pricing.Prompt),
+		OutputCostPerMTok: perMTok(m.Pricing.Completion),
+	}
+}
+
+// perMTok converts a price per token into a price per million tokens. Routers
+// like openrouter/auto have variable prices, listed as negative.
+func perMTok(price string) float64 {
+	n, err := strconv.ParseFloat(price, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n * 1_000_000
+}
+
+func (c *Client) lookupMeta(id string) *llm.ModelMeta {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.meta[id]
+}
+
+// Model retrieves a specific model from the catalog, which is only downloaded
+// the first time
+func (c *Client) Model(ctx context.Context, id string) (*llm.Model, error) {
+	c.mu.RLock()
+	models := c.models
+	c.mu.RUnlock()
+	if models == nil {
+		var err error
+		if models, err = c.Models(ctx); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range models {
+		if m.ID == id {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("openrouter: model %q not found", id)
+}
+
+// Models lists the models in OpenRouter's catalog, refreshing the cached copy
+func (c *Client) Models(ctx context.Context) ([]*llm.Model, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("openrouter: creating models request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	res, err := c.hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("openrouter: listing models: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("openrouter: listing models: unexpected status %d", res.StatusCode)
+	}
+	var catalog struct {
+		Data []*model `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&catalog); err != nil {
+		return nil, fmt.Errorf("openrouter: decoding models: %w", err)
+	}
+	meta := make(map[string]*llm.ModelMeta, len(catalog.Data))
+	models := make([]*llm.Model, 0, len(catalog.Data))
+	for _, m := range catalog.Data {
+		meta[m.ID] = toMeta(m)
+		models = append(models, &llm.Model{
+			Provider: "openrouter",
+			ID:       m.ID,
+			Meta:     meta[m.ID],
+		})
+	}
+	c.mu.Lock()
+	c.models = models
+	c.meta = meta
+	c.mu.Unlock()
+	return models, nil
+}
@@ -0,0 +1,40 @@
+package openrouter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm/providers/openrouter"
+)
+
+func TestModels(t *testing.T) {
+	e := loadEnv(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	provider := openrouter.New(e.OpenRouterKey)
+	models, err := provider.Models(ctx)
+	is.NoErr(err)
+	is.True(len(models) > 0)
+
+	for _, m := range models {
+		is.Equal(m.Provider, "openrouter")
+		is.True(m.ID != "")
+	}
+}
+
+func TestModel(t *testing.T) {
+	e := loadEnv(t)
+	is := is.New(t)
+	ctx := testContext(t)
+
+	provider := openrouter.New(e.OpenRouterKey)
+	m, err := provider.Model(ctx, testModel)
+	is.NoErr(err)
+	is.Equal(m.Provider, "openrouter")
+	is.Equal(m.ID, testModel)
+	is.True(strings.Contains(m.ID, "/"))
+	is.True(m.Meta != nil)
+	is.True(m.Meta.ContextWindow > 0)
+}
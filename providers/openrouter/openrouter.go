@@ -0,0 +1,80 @@
+package openrouter
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"sync"
+
+	"github.com/matthewmueller/llm"
+	"github.com/matthewmueller/llm/internal/openaicompat"
+	"github.com/openai/openai-go/option"
+)
+
+// baseURL of OpenRouter's OpenAI-compatible API
+const baseURL = "https://openrouter.ai/api/v1"
+
+// New creates a new OpenRouter client
+func New(apiKey string) *Client {
+	c := &Client{
+		apiKey:  apiKey,
+		baseURL: baseURL,
+		hc:      http.DefaultClient,
+	}
+	c.Client = openaicompat.New(&openaicompat.Config{
+		Name:         "openrouter",
+		BaseURL:      baseURL,
+		APIKey:       apiKey,
+		DefaultModel: "openrouter/auto",
+		Meta:         c.lookupMeta,
+		Reasoning:    reasoning,
+	})
+	return c
+}
+
+// Client implements the llm.Provider interface for OpenRouter. Models keep
+// OpenRouter's slash-delimited IDs, e.g. "anthropic/claude-sonnet-4".
+type Client struct {
+	*openaicompat.Client
+	apiKey  string
+	baseURL string
+	hc      *http.Client
+
+	mu     sync.RWMutex
+	models []*llm.Model              // Cached model catalog
+	meta   map[string]*llm.ModelMeta // Loaded from the model catalog
+}
+
+var _ llm.Provider = (*Client)(nil)
+
+// Chat sends a chat request to OpenRouter. The model catalog is loaded first
+// since it's the only source of model metadata, like whether the model
+// supports reasoning.
+func (c *Client) Chat(ctx context.Context, req *llm.ChatRequest) iter.Seq2[*llm.ChatResponse, error] {
+	return func(yield func(*llm.ChatResponse, error) bool) {
+		c.mu.RLock()
+		loaded := c.meta != nil
+		c.mu.RUnlock()
+		if !loaded {
+			// Chat without metadata rather than fail when the catalog is down
+			c.Models(ctx)
+		}
+		for res, err := range c.Client.Chat(ctx, req) {
+			if !yield(res, err) {
+				return
+			}
+		}
+	}
+}
+
+// reasoning maps thinking levels to OpenRouter's reasoning config
+func reasoning(level llm.Thinking) []option.RequestOption {
+	switch level {
+	case llm.ThinkingNone:
+		return []option.RequestOption{option.WithJSONSet("reasoning", map[string]any{"enabled": false})}
+	case llm.ThinkingLow, llm.ThinkingMedium, llm.ThinkingHigh:
+		return []option.RequestOption{option.WithJSONSet("reasoning", map[string]any{"effort": string(level)})}
+	default:
+		return nil
+	}
+}
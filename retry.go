@@ -0,0 +1,182 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	ollama "github.com/ollama/ollama/api"
+	"github.com/openai/openai-go"
+	"google.golang.org/genai"
+)
+
+// Retry configures retrying transient provider errors
+type Retry struct {
+	Attempts int           // Total attempts, including the first
+	Base     time.Duration // Delay before the first retry, doubled each time
+}
+
+// WithRetry retries requests that fail with a transient error, like a
+// connection reset or a 429 or 5xx response, using jittered exponential
+// backoff. Requests are only retried if nothing has been streamed back yet.
+func WithRetry(attempts int, base time.Duration) Option {
+	return func(c *Config) {
+		c.Retry = &Retry{
+			Attempts: attempts,
+			Base:     base,
+		}
+	}
+}
+
+// retry re-issues the chat until it succeeds, fails with an error that isn't
+// transient or runs out of attempts
+func retry(ctx context.Context, config *Retry, chat func() iter.Seq2[*ChatResponse, error]) iter.Seq2[*ChatResponse, error] {
+	if config == nil || config.Attempts <= 1 {
+		return chat()
+	}
+	return func(yield func(*ChatResponse, error) bool) {
+		for attempt := 1; ; attempt++ {
+			streamed, retrying := false, false
+			for res, err := range chat() {
+				if err != nil && !streamed && attempt < config.Attempts && retryable(ctx, err) {
+					retrying = true
+					break
+				}
+				// The start event doesn't carry any content
+				if err == nil && !res.Start {
+					streamed = true
+				}
+				if !yield(res, err) {
+					return
+				}
+			}
+			if !retrying {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				yield(nil, ctx.Err())
+				return
+			case <-time.After(backoff(config.Base, attempt)):
+			}
+		}
+	}
+}
+
+// WithRetryOnEmpty re-requests a step up to n times when the model finishes
+// without any content or tool calls, which models occasionally do for no
+// reason. The Done event of an empty response is held back while retrying.
+func WithRetryOnEmpty(n int) Option {
+	return func(c *Config) {
+		c.RetryOnEmpty = n
+	}
+}
+
+// retryEmpty re-issues the chat while it comes back empty, up to n more times
+func retryEmpty(n int, chat func() iter.Seq2[*ChatResponse, error]) iter.Seq2[*ChatResponse, error] {
+	if n <= 0 {
+		return chat()
+	}
+	return func(yield func(*ChatResponse, error) bool) {
+		for retries := 0; ; retries++ {
+			empty, failed := true, false
+			for res, err := range chat() {
+				if err != nil {
+					failed = true
+				} else if res.Content != "" || res.ToolCall != nil {
+					empty = false
+				}
+				// Hold back the end of an empty response we'll retry, but keep
+				// its usage
+				if err == nil && res.Done && empty && retries < n {
+					if res.Usage == nil {
+						continue
+					}
+					res = &ChatResponse{Role: res.Role, Usage: res.Usage}
+				}
+				if !yield(res, err) {
+					return
+				}
+			}
+			if !empty || failed || retries >= n {
+				return
+			}
+		}
+	}
+}
+
+// maxBackoff caps the delay between attempts
+const maxBackoff = 30 * time.Second
+
+// backoff returns a random delay between zero and base * 2^(attempt-1),
+// capped at maxBackoff. Full jitter spreads out clients that failed together.
+func backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	delay := maxBackoff
+	if attempt-1 < 63 && base <= maxBackoff>>(attempt-1) {
+		delay = base << (attempt - 1)
+	}
+	return rand.N(delay + 1)
+}
+
+// retryable returns true for errors that are likely to succeed if tried again
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	switch statusCode(err) {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+		529: // Anthropic's overloaded status
+		return true
+	}
+	return transient(err)
+}
+
+// transient returns true for connection errors that may clear up on their own
+func transient(err error) bool {
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// statusCode finds the HTTP status code in the provider SDK errors in the
+// chain. Returns 0 when the request never got a response.
+func statusCode(err error) int {
+	var anthropicErr *anthropic.Error
+	if errors.As(err, &anthropicErr) {
+		return anthropicErr.StatusCode
+	}
+	var openaiErr *openai.Error
+	if errors.As(err, &openaiErr) {
+		return openaiErr.StatusCode
+	}
+	var geminiErr genai.APIError
+	if errors.As(err, &geminiErr) {
+		return geminiErr.Code
+	}
+	var ollamaErr ollama.StatusError
+	if errors.As(err, &ollamaErr) {
+		return ollamaErr.StatusCode
+	}
+	var bedrockErr *smithyhttp.ResponseError
+	if errors.As(err, &bedrockErr) {
+		return bedrockErr.HTTPStatusCode()
+	}
+	return 0
+}
@@ -0,0 +1,197 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+	ollama "github.com/ollama/ollama/api"
+	"github.com/openai/openai-go"
+	"google.golang.org/genai"
+)
+
+// statusError is a provider SDK error with an HTTP status
+func statusError(code int) error {
+	return genai.APIError{Code: code, Message: http.StatusText(code)}
+}
+
+func TestRetry(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		fails: []error{
+			fmt.Errorf("fake: %w", statusError(503)),
+			fmt.Errorf("fake: %w", statusError(429)),
+		},
+		turns: [][]*llm.ChatResponse{
+			{text("Hello"), done()},
+		},
+	}
+	client := llm.New(provider)
+
+	content := ""
+	for res, err := range client.Chat(ctx, provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithRetry(3, time.Millisecond),
+	) {
+		is.NoErr(err)
+		content += res.Content
+	}
+	is.Equal(content, "Hello")
+	is.Equal(len(provider.Requests()), 3)
+}
+
+func TestRetryProviderErrors(t *testing.T) {
+	ctx := context.Background()
+	overloaded := &http.Response{StatusCode: http.StatusServiceUnavailable}
+	errs := map[string]error{
+		"anthropic": &anthropic.Error{StatusCode: http.StatusServiceUnavailable},
+		"openai":    &openai.Error{StatusCode: http.StatusServiceUnavailable},
+		"gemini":    genai.APIError{Code: http.StatusServiceUnavailable},
+		"ollama":    ollama.StatusError{StatusCode: http.StatusServiceUnavailable},
+		"bedrock": &awshttp.ResponseError{ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: overloaded},
+			Err:      errors.New("service unavailable"),
+		}},
+	}
+	for name, err := range errs {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			provider := &fakeProvider{
+				fails: []error{fmt.Errorf("%s: %w", name, err)},
+				turns: [][]*llm.ChatResponse{
+					{text("Hello"), done()},
+				},
+			}
+			for _, err := range llm.New(provider).Chat(ctx, provider.Name(),
+				llm.WithModel("fake-model"),
+				llm.WithRetry(2, time.Millisecond),
+			) {
+				is.NoErr(err)
+			}
+			is.Equal(len(provider.Requests()), 2)
+		})
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		fails: []error{statusError(500), statusError(502)},
+		turns: [][]*llm.ChatResponse{
+			{text("Hello"), done()},
+		},
+	}
+	client := llm.New(provider)
+
+	var errs []error
+	for _, err := range client.Chat(ctx, provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithRetry(2, time.Millisecond),
+	) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	is.Equal(len(errs), 1)
+	var apiErr genai.APIError
+	is.True(errors.As(errs[0], &apiErr))
+	is.Equal(apiErr.Code, 502)
+	is.Equal(len(provider.Requests()), 2)
+}
+
+func TestRetryPermanentError(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		fails: []error{statusError(400)},
+		turns: [][]*llm.ChatResponse{
+			{text("Hello"), done()},
+		},
+	}
+	client := llm.New(provider)
+
+	var errs []error
+	for _, err := range client.Chat(ctx, provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithRetry(3, time.Millisecond),
+	) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	is.Equal(len(errs), 1)
+	is.Equal(len(provider.Requests()), 1)
+}
+
+func TestRetryOnEmpty(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	usage := &llm.ChatResponse{Role: "assistant", Done: true, Usage: &llm.Usage{InputTokens: 10, TotalTokens: 10}}
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{done()},
+			{{Role: "assistant", Thinking: "Hmm"}, usage},
+			{text("Hello"), done()},
+		},
+	}
+	client := llm.New(provider)
+
+	content, dones, usages := "", 0, 0
+	for res, err := range client.Chat(ctx, provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithRetryOnEmpty(2),
+	) {
+		is.NoErr(err)
+		content += res.Content
+		if res.Done {
+			dones++
+		}
+		if res.Usage != nil {
+			usages++
+		}
+	}
+	is.Equal(content, "Hello")
+	is.Equal(len(provider.Requests()), 3)
+	is.Equal(dones, 1)  // Only the final response is done
+	is.Equal(usages, 1) // The empty response's usage still comes through
+}
+
+func TestRetryOnEmptyExhausted(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{done()},
+			{done()},
+		},
+	}
+	client := llm.New(provider)
+
+	dones := 0
+	for res, err := range client.Chat(ctx, provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithRetryOnEmpty(1),
+	) {
+		is.NoErr(err)
+		if res.Done {
+			dones++
+		}
+	}
+	is.Equal(len(provider.Requests()), 2)
+	is.Equal(dones, 1)
+}
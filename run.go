@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrMaxTurns is returned by Agent.Run when the goal isn't done within the
+// maximum number of turns
+var ErrMaxTurns = errors.New("llm: reached the maximum number of turns")
+
+// WithMaxTurns sets the maximum number of turns Agent.Run takes. Defaults to
+// 10.
+func WithMaxTurns(max int) Option {
+	return func(c *Config) {
+		c.MaxTurns = max
+	}
+}
+
+const continuePrompt = `Continue working toward the goal. Use your tools to make progress rather than asking me for input.`
+
+// Run works toward the goal on its own, starting a turn with the goal and
+// then asking the model to continue after each turn. It stops once done
+// returns true for a response, which also sees every response as it streams,
+// or returns ErrMaxTurns when the goal isn't done in time. Tool calls that
+// haven't run when it stops are recorded as cancelled.
+func (a *Agent) Run(ctx context.Context, goal string, done func(*ChatResponse) bool) error {
+	config := &Config{}
+	for _, option := range a.options {
+		option(config)
+	}
+	maxTurns := config.MaxTurns
+	if maxTurns <= 0 {
+		maxTurns = 10
+	}
+	prompt := goal
+	for range maxTurns {
+		for res, err := range a.Chat(ctx, prompt) {
+			if err != nil {
+				return err
+			}
+			if done(res) {
+				return nil
+			}
+		}
+		prompt = continuePrompt
+	}
+	return ErrMaxTurns
+}
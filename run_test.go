@@ -0,0 +1,90 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func TestAgentRun(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{toolCall("call_1", "add", `{"a":20,"b":22}`), done()},
+			{text("Still working"), done()},
+			{text("All done"), done()},
+		},
+	}
+	agent := llm.New(provider).Agent(provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithTool(addTool),
+	)
+	err := agent.Run(ctx, "Add 20 and 22", func(res *llm.ChatResponse) bool {
+		return res.Content == "All done"
+	})
+	is.NoErr(err)
+
+	// The goal starts the first turn and the model is asked to continue after
+	requests := provider.Requests()
+	is.Equal(len(requests), 3)
+	messages := agent.Messages()
+	is.Equal(messages[0].Content, "Add 20 and 22")
+	is.Equal(messages[4].Role, "user")
+	is.True(messages[4].Content != "Add 20 and 22")
+}
+
+func TestAgentRunMaxTurns(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{text("Working"), done()},
+			{text("Working"), done()},
+		},
+	}
+	agent := llm.New(provider).Agent(provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithMaxTurns(2),
+	)
+	err := agent.Run(ctx, "Never finish", func(res *llm.ChatResponse) bool { return false })
+	is.True(errors.Is(err, llm.ErrMaxTurns))
+	is.Equal(len(provider.Requests()), 2)
+}
+
+func TestAgentRunStopsOnToolCall(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{toolCall("call_1", "add", `{"a":20,"b":22}`), done()},
+			{text("Sure"), done()},
+		},
+	}
+	agent := llm.New(provider).Agent(provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithTool(addTool),
+	)
+	err := agent.Run(ctx, "Add 20 and 22", func(res *llm.ChatResponse) bool {
+		return res.ToolCall != nil
+	})
+	is.NoErr(err)
+
+	// The tool call that didn't run is answered so the next turn is valid
+	for _, err := range agent.Chat(ctx, "Never mind") {
+		is.NoErr(err)
+	}
+	messages := provider.Requests()[1].Messages
+	is.Equal(len(messages), 4)
+	is.Equal(messages[1].ToolCall.ID, "call_1")
+	is.Equal(messages[2].Role, "tool")
+	is.Equal(messages[2].ToolCallID, "call_1")
+	is.Equal(messages[2].Content, `{"error":"cancelled"}`)
+	is.Equal(messages[3].Content, "Never mind")
+}
@@ -1,11 +1,16 @@
 package container
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os/exec"
 	"path"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/matthewmueller/llm/internal/tty"
 	"github.com/matthewmueller/llm/sandbox"
 )
 
@@ -22,14 +27,23 @@ func WithWorkDir(workdir string) Option {
 	}
 }
 
+// WithAutoStart starts a detached container from the image on the first
+// command and runs every command in it, so files and installed packages carry
+// over between commands. Close the sandbox to remove the container. Without
+// it, each command runs in a fresh container.
+func WithAutoStart() Option {
+	return func(c *Sandbox) {
+		c.autoStart = true
+	}
+}
+
 type Option func(*Sandbox)
 
 // New creates a new local sandbox
 func New(image string, options ...Option) *sandbox.Exec {
 	box := &Sandbox{
-		image,
-		"/",
-		nil,
+		image:   image,
+		workDir: "/",
 	}
 	for _, option := range options {
 		option(box)
@@ -39,9 +53,14 @@ func New(image string, options ...Option) *sandbox.Exec {
 
 // Sandbox executes commands on the local machine.
 type Sandbox struct {
-	image   string
-	workDir string
-	volumes []string
+	image     string
+	workDir   string
+	volumes   []string
+	autoStart bool
+
+	mu      sync.Mutex
+	runtime string
+	id      string // Running container, when auto-starting
 }
 
 var _ sandbox.Executor = (*Sandbox)(nil)
@@ -68,6 +87,100 @@ func resolve(rootDir string, dirs ...string) string {
 	return workDir
 }
 
+// startTimeout bounds starting a container, which may pull the image first
+const startTimeout = 5 * time.Minute
+
+// start the container if it isn't running yet
+func (s *Sandbox) start(ctx context.Context, runtime string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.id != "" {
+		return s.id, nil
+	}
+	args := []string{"run", "-d", "--rm", "-w", s.workDir}
+	for _, volume := range s.volumes {
+		args = append(args, "-v", volume)
+	}
+	// Keep the container running until it's removed
+	args = append(args, s.image, "tail", "-f", "/dev/null")
+	// Cancelling ctx would kill the runtime after it may have already created
+	// the container, leaking it without an ID to remove it by. Let it finish
+	// instead, so Close can clean up.
+	startCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), startTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(startCtx, runtime, args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("container sandbox: starting container: %w", err)
+	}
+	s.runtime = runtime
+	s.id = strings.TrimSpace(string(out))
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("container sandbox: starting container: %w", err)
+	}
+	return s.id, nil
+}
+
+// Close removes the auto-started container, if there is one
+func (s *Sandbox) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.id == "" {
+		return nil
+	}
+	if err := exec.Command(s.runtime, "rm", "-f", s.id).Run(); err != nil {
+		return fmt.Errorf("container sandbox: removing container: %w", err)
+	}
+	s.id = ""
+	return nil
+}
+
+var _ sandbox.Copier = (*Sandbox)(nil)
+
+// CopyTo copies a file on the host into the container. Relative remote paths
+// are resolved from the working directory. Copying needs WithAutoStart, since
+// otherwise there's no container to copy into.
+func (s *Sandbox) CopyTo(ctx context.Context, localPath, remotePath string) error {
+	runtime, id, err := s.container(ctx)
+	if err != nil {
+		return err
+	}
+	target := id + ":" + resolve(s.workDir, remotePath)
+	if out, err := exec.CommandContext(ctx, runtime, "cp", localPath, target).CombinedOutput(); err != nil {
+		return fmt.Errorf("container sandbox: copying to %q: %w: %s", remotePath, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// CopyFrom copies a file out of the container onto the host. Relative remote
+// paths are resolved from the working directory.
+func (s *Sandbox) CopyFrom(ctx context.Context, remotePath, localPath string) error {
+	runtime, id, err := s.container(ctx)
+	if err != nil {
+		return err
+	}
+	source := id + ":" + resolve(s.workDir, remotePath)
+	if out, err := exec.CommandContext(ctx, runtime, "cp", source, localPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("container sandbox: copying from %q: %w: %s", remotePath, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// container returns the runtime and the started container to copy files with
+func (s *Sandbox) container(ctx context.Context) (string, string, error) {
+	if !s.autoStart {
+		return "", "", fmt.Errorf("container sandbox: copying files needs WithAutoStart")
+	}
+	runtime, err := detectRuntime()
+	if err != nil {
+		return "", "", err
+	}
+	id, err := s.start(ctx, runtime)
+	if err != nil {
+		return "", "", err
+	}
+	return runtime, id, nil
+}
+
 func (s *Sandbox) Run(ctx context.Context, c *sandbox.Cmd) error {
 	// docker or podman
 	runtime, err := detectRuntime()
@@ -77,17 +190,47 @@ func (s *Sandbox) Run(ctx context.Context, c *sandbox.Cmd) error {
 
 	workDir := resolve(s.workDir, c.Dir)
 
-	// Setup container arguments
-	args := []string{"run", "--rm", "-i"}
+	// Run the command in the started container or in a new one
+	args, target := []string{"run", "--rm", "-i"}, s.image
+	if s.autoStart {
+		id, err := s.start(ctx, runtime)
+		if err != nil {
+			return err
+		}
+		args, target = []string{"exec", "-i"}, id
+	}
+	if c.TTY {
+		args = append(args, "-t")
+	}
 	args = append(args, "-w", workDir)
-	for _, volume := range s.volumes {
-		args = append(args, "-v", volume)
+	// The started container already has its volumes
+	if !s.autoStart {
+		for _, volume := range s.volumes {
+			args = append(args, "-v", volume)
+		}
 	}
-	args = append(args, s.image, c.Path)
+	for _, env := range c.Env {
+		args = append(args, "-e", env)
+	}
+	args = append(args, target, c.Path)
 	args = append(args, c.Args...)
 
 	// Run the command inside a container
 	cmd := exec.CommandContext(ctx, runtime, args...)
+
+	// The runtime needs a terminal of its own to allocate one in the container.
+	// It passes the terminal's size through to the container.
+	if c.TTY {
+		var rows, cols uint16
+		if c.WindowSize != nil {
+			rows, cols = c.WindowSize.Rows, c.WindowSize.Cols
+		}
+		if err := tty.Run(cmd, c.Stdin, c.Stdout, rows, cols); err != nil {
+			return fmt.Errorf("container sandbox: running command: %w", err)
+		}
+		return nil
+	}
+
 	cmd.Stdin = c.Stdin
 	cmd.Stdout = c.Stdout
 	cmd.Stderr = c.Stderr
@@ -0,0 +1,82 @@
+package container_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm/sandbox/container"
+)
+
+// requireRuntime skips the test unless docker or podman can run containers
+func requireRuntime(t *testing.T) {
+	t.Helper()
+	for _, runtime := range []string{"podman", "docker"} {
+		if _, err := exec.LookPath(runtime); err != nil {
+			continue
+		}
+		if err := exec.Command(runtime, "info").Run(); err == nil {
+			return
+		}
+	}
+	t.Skip("container sandbox: docker or podman isn't available")
+}
+
+func TestAutoStart(t *testing.T) {
+	requireRuntime(t)
+	is := is.New(t)
+	ctx := context.Background()
+
+	sb := container.New("alpine", container.WithAutoStart(), container.WithWorkDir("/tmp"))
+	defer sb.Close()
+
+	out, err := sb.CommandContext(ctx, "echo", "hi").CombinedOutput()
+	is.NoErr(err)
+	is.Equal(string(out), "hi\n")
+
+	// Commands share the container
+	_, err = sb.CommandContext(ctx, "sh", "-c", "echo kept > file.txt").CombinedOutput()
+	is.NoErr(err)
+	out, err = sb.CommandContext(ctx, "cat", "file.txt").CombinedOutput()
+	is.NoErr(err)
+	is.Equal(string(out), "kept\n")
+
+	is.NoErr(sb.Close())
+}
+
+func TestEnv(t *testing.T) {
+	requireRuntime(t)
+	is := is.New(t)
+
+	cmd := container.New("alpine").Command("sh", "-c", "echo $GREETING")
+	cmd.Env = []string{"GREETING=hello"}
+	out, err := cmd.Output()
+	is.NoErr(err)
+	is.Equal(string(out), "hello\n")
+}
+
+func TestCopy(t *testing.T) {
+	requireRuntime(t)
+	is := is.New(t)
+	ctx := context.Background()
+	host := t.TempDir()
+
+	sb := container.New("alpine", container.WithAutoStart(), container.WithWorkDir("/tmp"))
+	defer sb.Close()
+
+	src := filepath.Join(host, "in.txt")
+	is.NoErr(os.WriteFile(src, []byte("hello"), 0644))
+	is.NoErr(sb.CopyTo(ctx, src, "in.txt"))
+	out, err := sb.CommandContext(ctx, "cat", "/tmp/in.txt").Output()
+	is.NoErr(err)
+	is.Equal(string(out), "hello")
+
+	dst := filepath.Join(host, "out.txt")
+	is.NoErr(sb.CopyFrom(ctx, "in.txt", dst))
+	data, err := os.ReadFile(dst)
+	is.NoErr(err)
+	is.Equal(string(data), "hello")
+}
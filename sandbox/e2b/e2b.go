@@ -0,0 +1,603 @@
+package e2b
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matthewmueller/llm/sandbox"
+)
+
+const (
+	defaultAPIURL   = "https://api.e2b.app"
+	defaultDomain   = "e2b.app"
+	defaultTemplate = "code-interpreter-v1"
+	// envdPort is where the daemon that runs commands listens in the sandbox
+	envdPort = 49983
+	// envdUser runs the commands
+	envdUser = "user"
+)
+
+// WithAPIKey authenticates with the given key instead of E2B_API_KEY
+func WithAPIKey(key string) Option {
+	return func(s *Sandbox) {
+		s.apiKey = key
+	}
+}
+
+// WithTemplate creates the sandbox from the given template. Defaults to the
+// code interpreter template.
+func WithTemplate(template string) Option {
+	return func(s *Sandbox) {
+		s.template = template
+	}
+}
+
+// WithSandbox connects to a running sandbox instead of creating one. Closing
+// leaves it running.
+func WithSandbox(id string) Option {
+	return func(s *Sandbox) {
+		s.id = id
+	}
+}
+
+// WithTimeout keeps a created sandbox alive for the given time before E2B
+// shuts it down. Defaults to E2B's default of 5 minutes.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Sandbox) {
+		s.timeout = timeout
+	}
+}
+
+// WithWorkDir runs commands from the given directory in the sandbox. Defaults
+// to the user's home directory.
+func WithWorkDir(workdir string) Option {
+	workdir = path.Clean(workdir)
+	return func(s *Sandbox) {
+		s.workDir = workdir
+	}
+}
+
+// WithAPIURL sends requests to manage the sandbox to the given URL instead of
+// E2B's API
+func WithAPIURL(url string) Option {
+	return func(s *Sandbox) {
+		s.apiURL = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithSandboxURL sends commands and files to the sandbox's daemon at the given
+// URL instead of its E2B address, like for a self-hosted sandbox
+func WithSandboxURL(url string) Option {
+	return func(s *Sandbox) {
+		s.sandboxURL = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithHTTPClient uses the given client to talk to E2B
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Sandbox) {
+		s.client = client
+	}
+}
+
+type Option func(*Sandbox)
+
+// New creates a sandbox that runs commands in an E2B cloud sandbox. The
+// sandbox is created on the first command and shut down when it's closed.
+func New(options ...Option) *sandbox.Exec {
+	box := &Sandbox{
+		apiKey:   os.Getenv("E2B_API_KEY"),
+		apiURL:   defaultAPIURL,
+		template: defaultTemplate,
+		client:   http.DefaultClient,
+	}
+	for _, option := range options {
+		option(box)
+	}
+	return sandbox.New(box)
+}
+
+// Sandbox executes commands in an E2B sandbox
+type Sandbox struct {
+	apiKey     string
+	apiURL     string
+	sandboxURL string
+	template   string
+	timeout    time.Duration
+	workDir    string
+	client     *http.Client
+
+	mu      sync.Mutex
+	id      string
+	token   string // Access token for the sandbox's daemon
+	domain  string
+	ready   bool
+	created bool // Whether we created the sandbox and should shut it down
+}
+
+var _ sandbox.Executor = (*Sandbox)(nil)
+
+// info describes a sandbox in E2B's API
+type info struct {
+	SandboxID       string `json:"sandboxID"`
+	EnvdAccessToken string `json:"envdAccessToken"`
+	Domain          string `json:"domain"`
+}
+
+// start creates the sandbox or connects to the one set with WithSandbox, if
+// it hasn't already
+func (s *Sandbox) start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ready {
+		return nil
+	}
+	var sandbox info
+	if s.id != "" {
+		if err := s.api(ctx, http.MethodGet, "/sandboxes/"+url.PathEscape(s.id), nil, &sandbox); err != nil {
+			return fmt.Errorf("e2b sandbox: connecting to %q: %w", s.id, err)
+		}
+	} else {
+		body := map[string]any{"templateID": s.template}
+		if s.timeout > 0 {
+			body["timeout"] = int(s.timeout.Seconds())
+		}
+		if err := s.api(ctx, http.MethodPost, "/sandboxes", body, &sandbox); err != nil {
+			return fmt.Errorf("e2b sandbox: creating sandbox: %w", err)
+		}
+		s.created = true
+	}
+	s.id = sandbox.SandboxID
+	s.token = sandbox.EnvdAccessToken
+	s.domain = sandbox.Domain
+	s.ready = true
+	return nil
+}
+
+// Close shuts down the sandbox, if it was created by us
+func (s *Sandbox) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.created {
+		return nil
+	}
+	if err := s.api(context.Background(), http.MethodDelete, "/sandboxes/"+url.PathEscape(s.id), nil, nil); err != nil {
+		return fmt.Errorf("e2b sandbox: shutting down %q: %w", s.id, err)
+	}
+	s.created, s.ready = false, false
+	s.id = ""
+	return nil
+}
+
+// api sends a request to E2B's API, decoding the JSON response into out
+func (s *Sandbox) api(ctx context.Context, method, path string, body, out any) error {
+	if s.apiKey == "" {
+		return errors.New("missing API key, set E2B_API_KEY or use WithAPIKey")
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.apiURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", s.apiKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return statusError(res)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// statusError describes a failed response with its body
+func statusError(res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 4<<10))
+	return fmt.Errorf("unexpected status %s: %s", res.Status, bytes.TrimSpace(body))
+}
+
+// envd returns a request to the daemon that runs commands in the sandbox
+func (s *Sandbox) envd(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	base := s.sandboxURL
+	if base == "" {
+		domain := s.domain
+		if domain == "" {
+			domain = defaultDomain
+		}
+		base = "https://" + strconv.Itoa(envdPort) + "-" + s.id + "." + domain
+	}
+	req, err := http.NewRequestWithContext(ctx, method, base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	// The username picks the user that runs the command
+	req.SetBasicAuth(envdUser, "")
+	if s.token != "" {
+		req.Header.Set("X-Access-Token", s.token)
+	}
+	return req, nil
+}
+
+// rpc sends a unary Connect request to the daemon
+func (s *Sandbox) rpc(ctx context.Context, method string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := s.envd(ctx, http.MethodPost, "/process.Process/"+method, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connect-Protocol-Version", "1")
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return statusError(res)
+	}
+	return nil
+}
+
+type startRequest struct {
+	Process processConfig `json:"process"`
+	PTY     *pty          `json:"pty,omitempty"`
+	Stdin   bool          `json:"stdin,omitempty"`
+}
+
+type processConfig struct {
+	Cmd  string            `json:"cmd"`
+	Args []string          `json:"args,omitempty"`
+	Envs map[string]string `json:"envs,omitempty"`
+	Cwd  string            `json:"cwd,omitempty"`
+}
+
+type pty struct {
+	Size ptySize `json:"size"`
+}
+
+type ptySize struct {
+	Cols uint16 `json:"cols"`
+	Rows uint16 `json:"rows"`
+}
+
+// event is a message in the stream of a started process. Output is base64
+// encoded, which decodes into the byte slices.
+type event struct {
+	Event struct {
+		Start *struct {
+			PID uint32 `json:"pid"`
+		} `json:"start"`
+		Data *struct {
+			Stdout []byte `json:"stdout"`
+			Stderr []byte `json:"stderr"`
+			PTY    []byte `json:"pty"`
+		} `json:"data"`
+		End *struct {
+			ExitCode int    `json:"exitCode"`
+			Exited   bool   `json:"exited"`
+			Status   string `json:"status"`
+			Error    string `json:"error"`
+		} `json:"end"`
+	} `json:"event"`
+}
+
+// endStream is the last message of a Connect stream
+type endStream struct {
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Flag on the message that ends a Connect stream
+const endStreamFlag = 0x02
+
+func (s *Sandbox) Run(ctx context.Context, c *sandbox.Cmd) error {
+	if err := s.start(ctx); err != nil {
+		return err
+	}
+	if err := s.run(ctx, c); err != nil {
+		return fmt.Errorf("e2b sandbox: running command: %w", err)
+	}
+	return nil
+}
+
+// run starts the process and streams its output until it exits
+func (s *Sandbox) run(ctx context.Context, c *sandbox.Cmd) error {
+	start := startRequest{
+		Process: processConfig{
+			Cmd:  c.Path,
+			Args: c.Args,
+			Cwd:  resolve(s.workDir, c.Dir),
+		},
+		Stdin: c.Stdin != nil,
+	}
+	for _, env := range c.Env {
+		key, value, _ := strings.Cut(env, "=")
+		if start.Process.Envs == nil {
+			start.Process.Envs = map[string]string{}
+		}
+		start.Process.Envs[key] = value
+	}
+	if c.TTY {
+		start.PTY = &pty{Size: ptySize{Cols: 80, Rows: 24}}
+		if c.WindowSize != nil {
+			start.PTY.Size = ptySize{Cols: c.WindowSize.Cols, Rows: c.WindowSize.Rows}
+		}
+	}
+	message, err := json.Marshal(start)
+	if err != nil {
+		return err
+	}
+	req, err := s.envd(ctx, http.MethodPost, "/process.Process/Start", bytes.NewReader(envelope(0, message)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/connect+json")
+	req.Header.Set("Connect-Protocol-Version", "1")
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return statusError(res)
+	}
+
+	// Stop sending input once the process is done
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	reader := bufio.NewReader(res.Body)
+	for {
+		flags, message, err := readEnvelope(reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return errors.New("stream ended before the command exited")
+			}
+			return err
+		}
+		if flags&endStreamFlag != 0 {
+			var end endStream
+			if err := json.Unmarshal(message, &end); err != nil {
+				return fmt.Errorf("decoding end of stream: %w", err)
+			}
+			if end.Error != nil {
+				return fmt.Errorf("%s: %s", end.Error.Code, end.Error.Message)
+			}
+			return errors.New("stream ended before the command exited")
+		}
+		var e event
+		if err := json.Unmarshal(message, &e); err != nil {
+			return fmt.Errorf("decoding event: %w", err)
+		}
+		switch {
+		case e.Event.Start != nil:
+			if c.Stdin != nil {
+				go s.sendInput(ctx, e.Event.Start.PID, c.Stdin, c.TTY)
+			}
+		case e.Event.Data != nil:
+			if c.Stdout != nil {
+				if _, err := c.Stdout.Write(e.Event.Data.Stdout); err != nil {
+					return err
+				}
+				if _, err := c.Stdout.Write(e.Event.Data.PTY); err != nil {
+					return err
+				}
+			}
+			if c.Stderr != nil {
+				if _, err := c.Stderr.Write(e.Event.Data.Stderr); err != nil {
+					return err
+				}
+			}
+		case e.Event.End != nil:
+			end := e.Event.End
+			if end.Error != "" && !end.Exited {
+				return errors.New(end.Error)
+			}
+			if end.ExitCode != 0 {
+				return &exitError{code: end.ExitCode, status: end.Status}
+			}
+			return nil
+		}
+	}
+}
+
+// sendInput forwards stdin to the process, closing its stdin at the end. The
+// process sees the input as typed into the terminal when it has one.
+func (s *Sandbox) sendInput(ctx context.Context, pid uint32, stdin io.Reader, tty bool) {
+	process := map[string]any{"pid": pid}
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := stdin.Read(buf)
+		if n > 0 {
+			input := map[string][]byte{"stdin": buf[:n]}
+			if tty {
+				input = map[string][]byte{"pty": buf[:n]}
+			}
+			if err := s.rpc(ctx, "SendInput", map[string]any{"process": process, "input": input}); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if !tty {
+		s.rpc(ctx, "CloseStdin", map[string]any{"process": process})
+	}
+}
+
+// envelope frames a Connect streaming message
+func envelope(flags byte, message []byte) []byte {
+	frame := make([]byte, 5, 5+len(message))
+	frame[0] = flags
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+// readEnvelope reads the next framed message of a Connect stream
+func readEnvelope(r io.Reader) (flags byte, message []byte, err error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	message = make([]byte, binary.BigEndian.Uint32(header[1:]))
+	if _, err := io.ReadFull(r, message); err != nil {
+		return 0, nil, err
+	}
+	return header[0], message, nil
+}
+
+// exitError reports the exit code of a command that failed in the sandbox
+type exitError struct {
+	code   int
+	status string
+}
+
+func (e *exitError) Error() string {
+	if e.status != "" {
+		return e.status
+	}
+	return "exit status " + strconv.Itoa(e.code)
+}
+
+func (e *exitError) ExitCode() int {
+	return e.code
+}
+
+var _ sandbox.Copier = (*Sandbox)(nil)
+
+// CopyTo copies a file on the host into the sandbox. Relative remote paths
+// are resolved from the working directory.
+func (s *Sandbox) CopyTo(ctx context.Context, localPath, remotePath string) error {
+	if err := s.start(ctx); err != nil {
+		return err
+	}
+	if err := s.upload(ctx, localPath, resolve(s.workDir, remotePath)); err != nil {
+		return fmt.Errorf("e2b sandbox: copying to %q: %w", remotePath, err)
+	}
+	return nil
+}
+
+func (s *Sandbox) upload(ctx context.Context, localPath, remotePath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	body := new(bytes.Buffer)
+	form := multipart.NewWriter(body)
+	part, err := form.CreateFormFile("file", path.Base(remotePath))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+	req, err := s.envd(ctx, http.MethodPost, "/files?"+filesQuery(remotePath), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return statusError(res)
+	}
+	return nil
+}
+
+// CopyFrom copies a file out of the sandbox onto the host. Relative remote
+// paths are resolved from the working directory.
+func (s *Sandbox) CopyFrom(ctx context.Context, remotePath, localPath string) error {
+	if err := s.start(ctx); err != nil {
+		return err
+	}
+	if err := s.download(ctx, resolve(s.workDir, remotePath), localPath); err != nil {
+		return fmt.Errorf("e2b sandbox: copying from %q: %w", remotePath, err)
+	}
+	return nil
+}
+
+func (s *Sandbox) download(ctx context.Context, remotePath, localPath string) error {
+	req, err := s.envd(ctx, http.MethodGet, "/files?"+filesQuery(remotePath), nil)
+	if err != nil {
+		return err
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return statusError(res)
+	}
+	file, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, res.Body); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// filesQuery selects the file and the user who owns it
+func filesQuery(remotePath string) string {
+	return url.Values{"path": {remotePath}, "username": {envdUser}}.Encode()
+}
+
+func resolve(rootDir string, dirs ...string) string {
+	workDir := rootDir
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		if path.IsAbs(dir) {
+			workDir = dir
+			continue
+		}
+		workDir = path.Join(workDir, dir)
+	}
+	return workDir
+}
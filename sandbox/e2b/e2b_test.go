@@ -0,0 +1,347 @@
+package e2b_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm/sandbox"
+	"github.com/matthewmueller/llm/sandbox/e2b"
+)
+
+// request is a request the fake E2B server received
+type request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   map[string]any
+}
+
+// server replays recorded responses from E2B's API and a sandbox's daemon.
+// Each line of a stream fixture is a message of the command's output. Lines
+// with an error end the stream.
+type server struct {
+	*httptest.Server
+	stream string
+
+	mu       sync.Mutex
+	requests []*request
+	files    map[string][]byte
+	closed   chan struct{} // Closed when the command's stdin is closed
+}
+
+func serve(t *testing.T, stream string) *server {
+	t.Helper()
+	s := &server{stream: stream, files: map[string][]byte{}, closed: make(chan struct{})}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// connect returns a sandbox that talks to the server
+func (s *server) connect(options ...e2b.Option) *sandbox.Exec {
+	options = append([]e2b.Option{
+		e2b.WithAPIKey("e2b_test"),
+		e2b.WithAPIURL(s.URL),
+		e2b.WithSandboxURL(s.URL),
+	}, options...)
+	return e2b.New(options...)
+}
+
+func (s *server) record(r *http.Request, body map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, &request{r.Method, r.URL.Path, r.Header.Clone(), body})
+}
+
+// Requests returns the requests received so far
+func (s *server) Requests() []*request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*request(nil), s.requests...)
+}
+
+func (s *server) handle(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/sandboxes" || strings.HasPrefix(r.URL.Path, "/sandboxes/"):
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		s.record(r, body)
+		switch r.Method {
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodPost:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write(fixture("sandbox.json"))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(fixture("sandbox.json"))
+		}
+	case r.URL.Path == "/process.Process/Start":
+		var header [5]byte
+		io.ReadFull(r.Body, header[:])
+		message := make([]byte, binary.BigEndian.Uint32(header[1:]))
+		io.ReadFull(r.Body, message)
+		var body map[string]any
+		json.Unmarshal(message, &body)
+		s.record(r, body)
+		s.replay(w, body["stdin"] == true)
+	case strings.HasPrefix(r.URL.Path, "/process.Process/"):
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		s.record(r, body)
+		if r.URL.Path == "/process.Process/CloseStdin" {
+			close(s.closed)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	case r.URL.Path == "/files":
+		s.record(r, nil)
+		path := r.URL.Query().Get("path")
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if r.Method == http.MethodGet {
+			data, ok := s.files[path]
+			if !ok {
+				http.Error(w, `{"code":404,"message":"file not found"}`, http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.files[path], _ = io.ReadAll(file)
+		w.Write([]byte(`[{"name":"` + filepath.Base(path) + `","path":"` + path + `","type":"file"}]`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// replay streams the recorded output, holding the rest of it after the
+// start until stdin is closed like a command reading its input would
+func (s *server) replay(w http.ResponseWriter, stdin bool) {
+	w.Header().Set("Content-Type", "application/connect+json")
+	scanner := bufio.NewScanner(bytes.NewReader(fixture(s.stream)))
+	for i := 0; scanner.Scan(); i++ {
+		line := scanner.Bytes()
+		if bytes.HasPrefix(line, []byte(`{"error"`)) {
+			w.Write(envelope(0x02, line))
+			return
+		}
+		w.Write(envelope(0, line))
+		w.(http.Flusher).Flush()
+		if i == 0 && stdin {
+			select {
+			case <-s.closed:
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}
+	w.Write(envelope(0x02, []byte("{}")))
+}
+
+func envelope(flags byte, message []byte) []byte {
+	frame := make([]byte, 5, 5+len(message))
+	frame[0] = flags
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+func fixture(name string) []byte {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func TestRun(t *testing.T) {
+	is := is.New(t)
+	server := serve(t, "echo.jsonl")
+	exec := server.connect(e2b.WithTemplate("base"), e2b.WithTimeout(10*time.Minute))
+
+	cmd := exec.Command("sh", "-c", "echo hello; echo warning >&2")
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	is.NoErr(err)
+	is.Equal(string(out), "hello\n")
+	is.Equal(stderr.String(), "warning\n")
+	is.NoErr(exec.Close())
+
+	reqs := server.Requests()
+	is.Equal(len(reqs), 3)
+
+	// The sandbox is created from the template with the API key
+	is.Equal(reqs[0].Method, http.MethodPost)
+	is.Equal(reqs[0].Path, "/sandboxes")
+	is.Equal(reqs[0].Header.Get("X-API-Key"), "e2b_test")
+	is.Equal(reqs[0].Body, map[string]any{"templateID": "base", "timeout": float64(600)})
+
+	// The command is started on the sandbox's daemon as the user
+	is.Equal(reqs[1].Path, "/process.Process/Start")
+	is.Equal(reqs[1].Header.Get("Content-Type"), "application/connect+json")
+	is.Equal(reqs[1].Header.Get("Connect-Protocol-Version"), "1")
+	is.Equal(reqs[1].Header.Get("X-Access-Token"), "ea_9f2c41d07b8e4a6d")
+	is.Equal(reqs[1].Header.Get("Authorization"), "Basic "+base64.StdEncoding.EncodeToString([]byte("user:")))
+	is.Equal(reqs[1].Body, map[string]any{
+		"process": map[string]any{"cmd": "sh", "args": []any{"-c", "echo hello; echo warning >&2"}},
+	})
+
+	// Closing shuts down the sandbox we created
+	is.Equal(reqs[2].Method, http.MethodDelete)
+	is.Equal(reqs[2].Path, "/sandboxes/i7k2xq9v3w1m8n4p5r6t")
+}
+
+func TestExitCode(t *testing.T) {
+	is := is.New(t)
+	exec := serve(t, "exit.jsonl").connect()
+	cmd := exec.Command("sh", "-c", "exit 3")
+	err := cmd.Run()
+	is.True(err != nil)
+	is.Equal(cmd.ExitCode(), 3)
+	is.True(strings.Contains(err.Error(), "exit status 3"))
+}
+
+func TestStartError(t *testing.T) {
+	is := is.New(t)
+	exec := serve(t, "missing.jsonl").connect()
+	cmd := exec.Command("nope")
+	err := cmd.Run()
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "executable file not found"))
+	is.Equal(cmd.ExitCode(), -1)
+}
+
+func TestEnvAndWorkDir(t *testing.T) {
+	is := is.New(t)
+	server := serve(t, "echo.jsonl")
+	exec := server.connect(e2b.WithWorkDir("/home/user/app"))
+	cmd := exec.Command("pwd")
+	cmd.Dir = "src"
+	cmd.Env = []string{"GREETING=hello"}
+	is.NoErr(cmd.Run())
+	is.Equal(server.Requests()[1].Body, map[string]any{
+		"process": map[string]any{
+			"cmd":  "pwd",
+			"envs": map[string]any{"GREETING": "hello"},
+			"cwd":  "/home/user/app/src",
+		},
+	})
+}
+
+func TestStdin(t *testing.T) {
+	is := is.New(t)
+	server := serve(t, "echo.jsonl")
+	exec := server.connect()
+	cmd := exec.Command("cat")
+	cmd.Stdin = strings.NewReader("hello\n")
+	out, err := cmd.Output()
+	is.NoErr(err)
+	is.Equal(string(out), "hello\n")
+
+	reqs := server.Requests()
+	is.Equal(len(reqs), 4)
+	is.Equal(reqs[1].Body["stdin"], true)
+	// The input is sent to the started process, then its stdin is closed
+	is.Equal(reqs[2].Path, "/process.Process/SendInput")
+	is.Equal(reqs[2].Header.Get("X-Access-Token"), "ea_9f2c41d07b8e4a6d")
+	is.Equal(reqs[2].Body, map[string]any{
+		"process": map[string]any{"pid": float64(412)},
+		"input":   map[string]any{"stdin": base64.StdEncoding.EncodeToString([]byte("hello\n"))},
+	})
+	is.Equal(reqs[3].Path, "/process.Process/CloseStdin")
+	is.Equal(reqs[3].Body, map[string]any{"process": map[string]any{"pid": float64(412)}})
+}
+
+func TestTTY(t *testing.T) {
+	is := is.New(t)
+	server := serve(t, "tty.jsonl")
+	exec := server.connect()
+	cmd := exec.Command("sh")
+	cmd.TTY = true
+	cmd.WindowSize = &sandbox.WindowSize{Rows: 40, Cols: 120}
+	out, err := cmd.Output()
+	is.NoErr(err)
+	is.Equal(string(out), "$ ")
+	is.Equal(server.Requests()[1].Body["pty"], map[string]any{"size": map[string]any{"cols": float64(120), "rows": float64(40)}})
+}
+
+func TestConnect(t *testing.T) {
+	is := is.New(t)
+	server := serve(t, "echo.jsonl")
+	exec := server.connect(e2b.WithSandbox("i7k2xq9v3w1m8n4p5r6t"))
+	is.NoErr(exec.Command("true").Run())
+	is.NoErr(exec.Close())
+
+	// Connecting looks up the running sandbox and leaves it running
+	reqs := server.Requests()
+	is.Equal(len(reqs), 2)
+	is.Equal(reqs[0].Method, http.MethodGet)
+	is.Equal(reqs[0].Path, "/sandboxes/i7k2xq9v3w1m8n4p5r6t")
+	is.Equal(reqs[1].Path, "/process.Process/Start")
+}
+
+func TestCopy(t *testing.T) {
+	is := is.New(t)
+	server := serve(t, "echo.jsonl")
+	exec := server.connect(e2b.WithWorkDir("/home/user/app"))
+
+	dir := t.TempDir()
+	local := filepath.Join(dir, "notes.txt")
+	is.NoErr(os.WriteFile(local, []byte("hello"), 0644))
+	is.NoErr(exec.CopyTo(t.Context(), local, "docs/notes.txt"))
+	is.Equal(string(server.files["/home/user/app/docs/notes.txt"]), "hello")
+
+	copied := filepath.Join(dir, "copied.txt")
+	is.NoErr(exec.CopyFrom(t.Context(), "docs/notes.txt", copied))
+	data, err := os.ReadFile(copied)
+	is.NoErr(err)
+	is.Equal(string(data), "hello")
+
+	err = exec.CopyFrom(t.Context(), "missing.txt", copied)
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "file not found"))
+}
+
+func TestMissingAPIKey(t *testing.T) {
+	is := is.New(t)
+	t.Setenv("E2B_API_KEY", "")
+	err := e2b.New().Command("true").Run()
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "E2B_API_KEY"))
+}
+
+func TestE2B(t *testing.T) {
+	if os.Getenv("E2B_API_KEY") == "" {
+		t.Skip("set E2B_API_KEY to run against E2B")
+	}
+	is := is.New(t)
+	exec := e2b.New()
+	defer exec.Close()
+	cmd := exec.Command("sh", "-c", "echo $GREETING")
+	cmd.Env = []string{"GREETING=hello"}
+	out, err := cmd.Output()
+	is.NoErr(err)
+	is.Equal(string(out), "hello\n")
+	cmd = exec.Command("sh", "-c", "exit 3")
+	is.True(cmd.Run() != nil)
+	is.Equal(cmd.ExitCode(), 3)
+}
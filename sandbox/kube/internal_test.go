@@ -0,0 +1,308 @@
+package kube
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm/sandbox"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	remotecommandconsts "k8s.io/apimachinery/pkg/util/remotecommand"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// pod handles exec requests like a container would, reading stdin and
+// returning the exit code
+type pod func(query url.Values, stdin io.Reader, stdout, stderr io.Writer) (code int)
+
+// stub creates a sandbox whose round-tripper and upgrader serve the exec
+// streams in memory. The requests are recorded.
+func stub(t *testing.T, handler pod, options ...Option) (exec *sandbox.Exec, requests func() []*http.Request) {
+	t.Helper()
+	var mu sync.Mutex
+	var recorded []*http.Request
+	options = append([]Option{WithConfig(&rest.Config{Host: "https://cluster.test"})}, options...)
+	box := &Sandbox{namespace: "ci", pod: "runner-1"}
+	for _, option := range options {
+		option(box)
+	}
+	box.transports = func(config *rest.Config) (http.RoundTripper, spdy.Upgrader, error) {
+		transport := roundTripper(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			recorded = append(recorded, req)
+			mu.Unlock()
+			return &http.Response{
+				StatusCode: http.StatusSwitchingProtocols,
+				Header:     http.Header{httpstream.HeaderProtocolVersion: {remotecommandconsts.StreamProtocolV4Name}},
+				Body:       io.NopCloser(new(bytes.Buffer)),
+				Request:    req,
+			}, nil
+		})
+		return transport, upgrader(func(resp *http.Response) (httpstream.Connection, error) {
+			return newConnection(resp.Request.URL.Query(), handler), nil
+		}), nil
+	}
+	return sandbox.New(box), func() []*http.Request {
+		mu.Lock()
+		defer mu.Unlock()
+		return recorded
+	}
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+type upgrader func(*http.Response) (httpstream.Connection, error)
+
+func (f upgrader) NewConnection(resp *http.Response) (httpstream.Connection, error) { return f(resp) }
+
+// connection runs the pod once the client has opened every stream the exec
+// request asked for
+type connection struct {
+	query   url.Values
+	handler pod
+	closed  chan bool
+
+	mu      sync.Mutex
+	streams map[string]*stream
+}
+
+func newConnection(query url.Values, handler pod) *connection {
+	return &connection{
+		query:   query,
+		handler: handler,
+		closed:  make(chan bool),
+		streams: map[string]*stream{},
+	}
+}
+
+func (c *connection) CreateStream(headers http.Header) (httpstream.Stream, error) {
+	s := newStream(headers)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.streams[headers.Get(corev1.StreamType)] = s
+	if c.ready() {
+		go c.run()
+	}
+	return s, nil
+}
+
+// ready is true when the last stream the client opens has been created
+func (c *connection) ready() bool {
+	last := corev1.StreamTypeError
+	for _, name := range []string{"stdin", "stdout", "stderr"} {
+		if c.query.Get(name) == "true" {
+			last = name
+		}
+	}
+	if c.query.Get("tty") == "true" {
+		last = corev1.StreamTypeResize
+	}
+	return c.streams[last] != nil
+}
+
+func (c *connection) run() {
+	stdin := io.Reader(new(bytes.Buffer))
+	if s := c.streams[corev1.StreamTypeStdin]; s != nil {
+		stdin = s.podIn
+	}
+	stdout, stderr := io.Discard, io.Discard
+	if s := c.streams[corev1.StreamTypeStdout]; s != nil {
+		stdout = s.podOut
+	}
+	if s := c.streams[corev1.StreamTypeStderr]; s != nil {
+		stderr = s.podOut
+	}
+	code := c.handler(c.query, stdin, stdout, stderr)
+	for name, s := range c.streams {
+		if name != corev1.StreamTypeError {
+			s.podOut.Close()
+		}
+	}
+	status := metav1.Status{Status: metav1.StatusSuccess}
+	if code != 0 {
+		status = metav1.Status{
+			Status: metav1.StatusFailure,
+			Reason: remotecommandconsts.NonZeroExitCodeReason,
+			Details: &metav1.StatusDetails{
+				Causes: []metav1.StatusCause{{Type: remotecommandconsts.ExitCodeCauseType, Message: strconv.Itoa(code)}},
+			},
+		}
+	}
+	errorStream := c.streams[corev1.StreamTypeError]
+	json.NewEncoder(errorStream.podOut).Encode(status)
+	errorStream.podOut.Close()
+}
+
+func (c *connection) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+	return nil
+}
+
+func (c *connection) CloseChan() <-chan bool                     { return c.closed }
+func (c *connection) SetIdleTimeout(timeout time.Duration)       {}
+func (c *connection) RemoveStreams(streams ...httpstream.Stream) {}
+
+// stream connects the client's end of a stream to the pod's
+type stream struct {
+	headers http.Header
+	read    *io.PipeReader // What the client reads from the pod
+	write   *io.PipeWriter // What the client writes to the pod
+	podIn   *io.PipeReader
+	podOut  *io.PipeWriter
+}
+
+func newStream(headers http.Header) *stream {
+	podIn, write := io.Pipe()
+	read, podOut := io.Pipe()
+	return &stream{headers: headers.Clone(), read: read, write: write, podIn: podIn, podOut: podOut}
+}
+
+func (s *stream) Read(p []byte) (int, error)  { return s.read.Read(p) }
+func (s *stream) Write(p []byte) (int, error) { return s.write.Write(p) }
+func (s *stream) Close() error                { return s.write.Close() }
+func (s *stream) Headers() http.Header        { return s.headers }
+func (s *stream) Identifier() uint32          { return 0 }
+
+func (s *stream) Reset() error {
+	s.read.Close()
+	return s.write.Close()
+}
+
+func TestRun(t *testing.T) {
+	is := is.New(t)
+	exec, requests := stub(t, func(query url.Values, stdin io.Reader, stdout, stderr io.Writer) int {
+		io.Copy(stdout, stdin)
+		io.WriteString(stdout, "done\n")
+		io.WriteString(stderr, "warning\n")
+		return 0
+	}, WithContainer("app"))
+
+	cmd := exec.Command("sh", "-c", "cat; echo done")
+	cmd.Stdin = bytes.NewReader([]byte("input\n"))
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	is.NoErr(err)
+	is.Equal(string(out), "input\ndone\n")
+	is.Equal(stderr.String(), "warning\n")
+
+	// The command goes to the pod's exec subresource
+	reqs := requests()
+	is.Equal(len(reqs), 1)
+	is.Equal(reqs[0].Method, http.MethodPost)
+	is.Equal(reqs[0].URL.Host, "cluster.test")
+	is.Equal(reqs[0].URL.Path, "/api/v1/namespaces/ci/pods/runner-1/exec")
+	query := reqs[0].URL.Query()
+	is.Equal(query["command"], []string{"sh", "-c", "cat; echo done"})
+	is.Equal(query.Get("container"), "app")
+	is.Equal(query.Get("stdin"), "true")
+	is.Equal(query.Get("stdout"), "true")
+	is.Equal(query.Get("stderr"), "true")
+}
+
+func TestExitCode(t *testing.T) {
+	is := is.New(t)
+	exec, _ := stub(t, func(query url.Values, stdin io.Reader, stdout, stderr io.Writer) int {
+		return 3
+	})
+	cmd := exec.Command("sh", "-c", "exit 3")
+	is.True(cmd.Run() != nil)
+	is.Equal(cmd.ExitCode(), 3)
+}
+
+func TestEnvAndWorkDir(t *testing.T) {
+	is := is.New(t)
+	exec, requests := stub(t, func(query url.Values, stdin io.Reader, stdout, stderr io.Writer) int {
+		return 0
+	}, WithWorkDir("/app"))
+	cmd := exec.Command("pwd")
+	cmd.Dir = "src"
+	cmd.Env = []string{"GREETING=hello"}
+	is.NoErr(cmd.Run())
+	is.Equal(requests()[0].URL.Query()["command"], []string{"env", "GREETING=hello", "sh", "-c", `cd "${0}" && exec "$@"`, "/app/src", "pwd"})
+}
+
+func TestTTY(t *testing.T) {
+	is := is.New(t)
+	exec, requests := stub(t, func(query url.Values, stdin io.Reader, stdout, stderr io.Writer) int {
+		io.WriteString(stdout, "$ ")
+		return 0
+	})
+	cmd := exec.Command("sh")
+	cmd.TTY = true
+	cmd.WindowSize = &sandbox.WindowSize{Rows: 24, Cols: 80}
+	out, err := cmd.Output()
+	is.NoErr(err)
+	is.Equal(string(out), "$ ")
+	query := requests()[0].URL.Query()
+	is.Equal(query.Get("tty"), "true")
+	is.Equal(query.Get("stderr"), "")
+}
+
+func TestCopy(t *testing.T) {
+	is := is.New(t)
+	files := map[string][]byte{}
+	var mu sync.Mutex
+	exec, requests := stub(t, func(query url.Values, stdin io.Reader, stdout, stderr io.Writer) int {
+		// The path comes last, after the command that reads or writes it
+		command := query["command"]
+		path := command[len(command)-1]
+		mu.Lock()
+		defer mu.Unlock()
+		if command[len(command)-2] == "cat" {
+			stdout.Write(files[path])
+			return 0
+		}
+		data, _ := io.ReadAll(stdin)
+		files[path] = data
+		return 0
+	}, WithWorkDir("/app"))
+
+	dir := t.TempDir()
+	local := filepath.Join(dir, "notes.txt")
+	is.NoErr(os.WriteFile(local, []byte("hello"), 0644))
+	is.NoErr(exec.CopyTo(t.Context(), local, "docs/notes.txt"))
+	is.Equal(string(files["/app/docs/notes.txt"]), "hello")
+	command := requests()[0].URL.Query()["command"]
+	is.Equal(command[len(command)-4:], []string{"sh", "-c", `mkdir -p "$(dirname "$0")" && cat > "$0"`, "/app/docs/notes.txt"})
+
+	copied := filepath.Join(dir, "copied.txt")
+	is.NoErr(exec.CopyFrom(t.Context(), "docs/notes.txt", copied))
+	data, err := os.ReadFile(copied)
+	is.NoErr(err)
+	is.Equal(string(data), "hello")
+}
+
+func TestCopyFromMissing(t *testing.T) {
+	is := is.New(t)
+	exec, _ := stub(t, func(query url.Values, stdin io.Reader, stdout, stderr io.Writer) int {
+		io.WriteString(stdout, "partial")
+		return 1
+	})
+	copied := filepath.Join(t.TempDir(), "copied.txt")
+	is.True(exec.CopyFrom(t.Context(), "missing.txt", copied) != nil)
+
+	// The partial file is removed
+	_, err := os.Stat(copied)
+	is.True(os.IsNotExist(err))
+}
@@ -0,0 +1,273 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/matthewmueller/llm/sandbox"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/transport/spdy"
+	"k8s.io/client-go/util/exec"
+)
+
+// WithConfig connects to the cluster with the given REST config instead of
+// loading one from the kubeconfig
+func WithConfig(config *rest.Config) Option {
+	return func(s *Sandbox) {
+		s.config = config
+	}
+}
+
+// WithContainer runs commands in the given container of the pod. Defaults to
+// the pod's default container.
+func WithContainer(name string) Option {
+	return func(s *Sandbox) {
+		s.container = name
+	}
+}
+
+// WithContext uses the given context from the kubeconfig instead of the
+// current context. It's ignored when the config is set with WithConfig.
+func WithContext(name string) Option {
+	return func(s *Sandbox) {
+		s.context = name
+	}
+}
+
+// WithKubeconfig uses the kubeconfig file at the given path instead of the
+// default one. It's ignored when the config is set with WithConfig.
+func WithKubeconfig(path string) Option {
+	return func(s *Sandbox) {
+		s.kubeconfig = path
+	}
+}
+
+// WithWorkDir runs commands from the given directory in the container.
+// Defaults to the container's working directory.
+func WithWorkDir(workdir string) Option {
+	workdir = path.Clean(workdir)
+	return func(s *Sandbox) {
+		s.workDir = workdir
+	}
+}
+
+type Option func(*Sandbox)
+
+// New creates a sandbox that runs commands in a running pod through the
+// Kubernetes exec API, like kubectl exec
+func New(namespace, pod string, options ...Option) *sandbox.Exec {
+	box := &Sandbox{
+		namespace:  namespace,
+		pod:        pod,
+		transports: spdy.RoundTripperFor,
+	}
+	for _, option := range options {
+		option(box)
+	}
+	return sandbox.New(box)
+}
+
+// Sandbox executes commands in a Kubernetes pod
+type Sandbox struct {
+	namespace  string
+	pod        string
+	container  string
+	context    string
+	kubeconfig string
+	workDir    string
+	config     *rest.Config
+	// transports connects to the API server and upgrades the connection to
+	// the streams of the exec
+	transports func(config *rest.Config) (http.RoundTripper, spdy.Upgrader, error)
+}
+
+var _ sandbox.Executor = (*Sandbox)(nil)
+
+// restConfig returns the config set with WithConfig or loads it from the
+// kubeconfig
+func (s *Sandbox) restConfig() (*rest.Config, error) {
+	if s.config != nil {
+		return s.config, nil
+	}
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = s.kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: s.context}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+}
+
+func (s *Sandbox) Run(ctx context.Context, c *sandbox.Cmd) error {
+	if err := s.exec(ctx, c); err != nil {
+		return fmt.Errorf("kube sandbox: running command: %w", err)
+	}
+	return nil
+}
+
+// exec streams the command's input and output through the pod's exec
+// subresource
+func (s *Sandbox) exec(ctx context.Context, c *sandbox.Cmd) error {
+	config, err := s.restConfig()
+	if err != nil {
+		return err
+	}
+	client, err := corev1client.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	url := client.RESTClient().Post().
+		Namespace(s.namespace).
+		Resource("pods").
+		Name(s.pod).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: s.container,
+			Command:   s.command(c),
+			Stdin:     c.Stdin != nil,
+			Stdout:    c.Stdout != nil,
+			Stderr:    c.Stderr != nil && !c.TTY,
+			TTY:       c.TTY,
+		}, scheme.ParameterCodec).
+		URL()
+	transport, upgrader, err := s.transports(config)
+	if err != nil {
+		return err
+	}
+	executor, err := remotecommand.NewSPDYExecutorForTransports(transport, upgrader, http.MethodPost, url)
+	if err != nil {
+		return err
+	}
+	options := remotecommand.StreamOptions{
+		Stdin:  c.Stdin,
+		Stdout: c.Stdout,
+		Stderr: c.Stderr,
+		Tty:    c.TTY,
+	}
+	// Output from stdout and stderr is combined in a terminal
+	if c.TTY {
+		options.Stderr = nil
+		if c.WindowSize != nil {
+			options.TerminalSizeQueue = &sizeQueue{&remotecommand.TerminalSize{
+				Width:  c.WindowSize.Cols,
+				Height: c.WindowSize.Rows,
+			}}
+		}
+	}
+	if err := executor.StreamWithContext(ctx, options); err != nil {
+		var exitErr exec.CodeExitError
+		if errors.As(err, &exitErr) {
+			return &exitError{exitErr}
+		}
+		return err
+	}
+	return nil
+}
+
+// command wraps the command to set its working directory and environment,
+// since the exec API can't set them
+func (s *Sandbox) command(c *sandbox.Cmd) (command []string) {
+	workDir := s.workDir
+	if c.Dir != "" {
+		workDir = resolve(workDir, c.Dir)
+	}
+	if workDir != "" || len(c.Env) > 0 {
+		script := `cd "${0}" && exec "$@"`
+		if workDir == "" {
+			script = `exec "$@"`
+			workDir = "sh"
+		}
+		command = append(command, "env")
+		command = append(command, c.Env...)
+		command = append(command, "sh", "-c", script, workDir)
+	}
+	command = append(command, c.Path)
+	return append(command, c.Args...)
+}
+
+// exitError reports the exit code of a command that failed in the pod
+type exitError struct {
+	exec.CodeExitError
+}
+
+func (e *exitError) ExitCode() int {
+	return e.Code
+}
+
+// sizeQueue sends the terminal's size once, when the command starts
+type sizeQueue struct {
+	size *remotecommand.TerminalSize
+}
+
+func (q *sizeQueue) Next() *remotecommand.TerminalSize {
+	size := q.size
+	q.size = nil
+	return size
+}
+
+var _ sandbox.Copier = (*Sandbox)(nil)
+
+// CopyTo copies a file on the host into the pod. Relative remote paths are
+// resolved from the working directory.
+func (s *Sandbox) CopyTo(ctx context.Context, localPath, remotePath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("kube sandbox: copying to %q: %w", remotePath, err)
+	}
+	defer file.Close()
+	// Write the file through the shell, creating its directory first
+	cmd := &sandbox.Cmd{
+		Path:  "sh",
+		Args:  []string{"-c", `mkdir -p "$(dirname "$0")" && cat > "$0"`, resolve(s.workDir, remotePath)},
+		Stdin: file,
+	}
+	if err := s.exec(ctx, cmd); err != nil {
+		return fmt.Errorf("kube sandbox: copying to %q: %w", remotePath, err)
+	}
+	return nil
+}
+
+// CopyFrom copies a file out of the pod onto the host. Relative remote paths
+// are resolved from the working directory.
+func (s *Sandbox) CopyFrom(ctx context.Context, remotePath, localPath string) error {
+	file, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("kube sandbox: copying from %q: %w", remotePath, err)
+	}
+	cmd := &sandbox.Cmd{
+		Path:   "cat",
+		Args:   []string{resolve(s.workDir, remotePath)},
+		Stdout: file,
+		Stderr: io.Discard,
+	}
+	// Don't leave a partial file behind when the copy fails
+	if err := s.exec(ctx, cmd); err != nil {
+		file.Close()
+		os.Remove(localPath)
+		return fmt.Errorf("kube sandbox: copying from %q: %w", remotePath, err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(localPath)
+		return fmt.Errorf("kube sandbox: copying from %q: %w", remotePath, err)
+	}
+	return nil
+}
+
+func resolve(rootDir string, dirs ...string) string {
+	workDir := rootDir
+	for _, dir := range dirs {
+		if path.IsAbs(dir) {
+			workDir = dir
+			continue
+		}
+		workDir = path.Join(workDir, dir)
+	}
+	return workDir
+}
@@ -3,21 +3,55 @@ package local
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/matthewmueller/llm/internal/tty"
 	"github.com/matthewmueller/llm/sandbox"
 )
 
+// WithAllowedCommands only allows running the given commands. Commands are
+// matched by name (e.g. "go") or by path (e.g. "/usr/local/bin/go").
+//
+// This is a guard against mistakes, not a security boundary. An allowed
+// command like sh can still run anything.
+func WithAllowedCommands(commands ...string) Option {
+	return func(s *Sandbox) {
+		s.allowed = append(s.allowed, commands...)
+	}
+}
+
+// WithDeniedPaths refuses to run commands in or referencing the given paths.
+// Relative paths are resolved from the root.
+//
+// This is a guard against mistakes, not a security boundary. Only the
+// working directory, the command and its arguments are checked, so a command
+// can still reach a denied path indirectly.
+func WithDeniedPaths(paths ...string) Option {
+	return func(s *Sandbox) {
+		s.denied = append(s.denied, paths...)
+	}
+}
+
+type Option func(*Sandbox)
+
 // New creates a new local sandbox
-func New(root string) *sandbox.Exec {
-	return sandbox.New(&Sandbox{root})
+func New(root string, options ...Option) *sandbox.Exec {
+	box := &Sandbox{root: root}
+	for _, option := range options {
+		option(box)
+	}
+	return sandbox.New(box)
 }
 
 // Sandbox executes commands on the local machine.
 type Sandbox struct {
-	root string
+	root    string
+	allowed []string
+	denied  []string
 }
 
 var _ sandbox.Executor = (*Sandbox)(nil)
@@ -41,9 +75,31 @@ func (s *Sandbox) Run(ctx context.Context, c *sandbox.Cmd) error {
 		return fmt.Errorf("sandbox/local: working dir %q is outside of root %q", c.Dir, s.root)
 	}
 
+	if !s.isAllowed(c.Path) {
+		return fmt.Errorf("sandbox/local: command %q is not allowed", c.Path)
+	}
+
+	if path, ok := s.isDenied(rootDir, workDir, c); ok {
+		return fmt.Errorf("sandbox/local: path %q is denied", path)
+	}
+
 	// Run the command
 	cmd := exec.CommandContext(ctx, c.Path, c.Args...)
 	cmd.Dir = workDir
+	// Add to the host's environment rather than replacing it
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	if c.TTY {
+		var rows, cols uint16
+		if c.WindowSize != nil {
+			rows, cols = c.WindowSize.Rows, c.WindowSize.Cols
+		}
+		if err := tty.Run(cmd, c.Stdin, c.Stdout, rows, cols); err != nil {
+			return fmt.Errorf("sandbox/local: running command: %w", err)
+		}
+		return nil
+	}
 	cmd.Stdin = c.Stdin
 	cmd.Stdout = c.Stdout
 	cmd.Stderr = c.Stderr
@@ -54,6 +110,137 @@ func (s *Sandbox) Run(ctx context.Context, c *sandbox.Cmd) error {
 	return nil
 }
 
+var _ sandbox.Copier = (*Sandbox)(nil)
+
+// CopyTo copies a file on the host into the sandbox. Relative remote paths are
+// resolved from the root.
+func (s *Sandbox) CopyTo(ctx context.Context, localPath, remotePath string) error {
+	path, err := s.path(remotePath)
+	if err != nil {
+		return err
+	}
+	if err := copyFile(localPath, path); err != nil {
+		return fmt.Errorf("sandbox/local: copying to %q: %w", remotePath, err)
+	}
+	return nil
+}
+
+// CopyFrom copies a file out of the sandbox onto the host. Relative remote
+// paths are resolved from the root.
+func (s *Sandbox) CopyFrom(ctx context.Context, remotePath, localPath string) error {
+	path, err := s.path(remotePath)
+	if err != nil {
+		return err
+	}
+	if err := copyFile(path, localPath); err != nil {
+		return fmt.Errorf("sandbox/local: copying from %q: %w", remotePath, err)
+	}
+	return nil
+}
+
+// path resolves a path in the sandbox, refusing paths outside of the root or
+// in a denied path
+func (s *Sandbox) path(remotePath string) (string, error) {
+	rootDir, err := filepath.Abs(s.root)
+	if err != nil {
+		return "", fmt.Errorf("sandbox/local: resolving root dir: %w", err)
+	}
+	path, err := resolve(rootDir, remotePath)
+	if err != nil {
+		return "", fmt.Errorf("sandbox/local: resolving path: %w", err)
+	}
+	if isOutside, err := isOutsideRoot(rootDir, path); err != nil {
+		return "", fmt.Errorf("sandbox/local: unable to verify path: %w", err)
+	} else if isOutside {
+		return "", fmt.Errorf("sandbox/local: path %q is outside of root %q", remotePath, s.root)
+	}
+	for _, denied := range s.denied {
+		deniedDir, _ := resolve(rootDir, denied)
+		if isOutside, err := isOutsideRoot(deniedDir, path); err == nil && !isOutside {
+			return "", fmt.Errorf("sandbox/local: path %q is denied", remotePath)
+		}
+	}
+	return path, nil
+}
+
+// copyFile copies the file at src to dst, keeping its permissions
+func copyFile(src, dst string) error {
+	from, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer from.Close()
+	info, err := from.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	to, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(to, from); err != nil {
+		to.Close()
+		return err
+	}
+	return to.Close()
+}
+
+// isAllowed checks the command against the allowlist
+func (s *Sandbox) isAllowed(command string) bool {
+	if len(s.allowed) == 0 {
+		return true
+	}
+	for _, allowed := range s.allowed {
+		if command == allowed || (!strings.ContainsRune(allowed, filepath.Separator) && filepath.Base(command) == allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDenied checks the working directory, the command and every argument
+// that isn't a flag against the denied paths, returning the first denied path
+// it finds. Arguments are resolved from the working directory, so bare names
+// like .env are checked too.
+func (s *Sandbox) isDenied(rootDir, workDir string, c *sandbox.Cmd) (string, bool) {
+	if len(s.denied) == 0 {
+		return "", false
+	}
+	paths := []string{workDir}
+	// Bare command names are looked up in the PATH
+	if strings.ContainsRune(c.Path, filepath.Separator) {
+		path, _ := resolve(workDir, c.Path)
+		paths = append(paths, path)
+	}
+	for _, arg := range c.Args {
+		if strings.HasPrefix(arg, "-") {
+			// Check values of flags like --file=path
+			_, value, ok := strings.Cut(arg, "=")
+			if !ok {
+				continue
+			}
+			arg = value
+		}
+		if arg == "" {
+			continue
+		}
+		path, _ := resolve(workDir, arg)
+		paths = append(paths, path)
+	}
+	for _, denied := range s.denied {
+		deniedDir, _ := resolve(rootDir, denied)
+		for _, path := range paths {
+			if isOutside, err := isOutsideRoot(deniedDir, path); err == nil && !isOutside {
+				return path, true
+			}
+		}
+	}
+	return "", false
+}
+
 func resolve(absDir string, dirs ...string) (string, error) {
 	for _, dir := range dirs {
 		if filepath.IsAbs(dir) {
@@ -1,43 +1,197 @@
 package local_test
 
-// func TestCommandContextStreaming(t *testing.T) {
-// 	is := is.New(t)
-// 	sb := local.New(t.TempDir())
-
-// 	cmd := sb.CommandContext(context.Background(), "sh", "-lc", "printf 'hello\\n'; sleep 0.2; printf 'world\\n'")
-// 	stdout, err := cmd.StdoutPipe()
-// 	is.NoErr(err)
-
-// 	is.NoErr(cmd.Start())
-
-// 	reader := bufio.NewReader(stdout)
-// 	firstLine := make(chan string, 1)
-// 	go func() {
-// 		line, _ := reader.ReadString('\n')
-// 		firstLine <- line
-// 	}()
-
-// 	select {
-// 	case line := <-firstLine:
-// 		is.Equal(line, "hello\n")
-// 	case <-time.After(1 * time.Second):
-// 		t.Fatal("timed out waiting for first streamed line")
-// 	}
-
-// 	rest, err := io.ReadAll(reader)
-// 	is.NoErr(err)
-// 	is.Equal(string(rest), "world\n")
-
-// 	is.NoErr(cmd.Wait())
-// 	is.Equal(cmd.ExitCode(), 0)
-// }
-
-// func TestExecuteNonZeroExit(t *testing.T) {
-// 	is := is.New(t)
-// 	sb := local.New(t.TempDir())
-
-// 	result, err := sb.Execute(context.Background(), "sh", "-lc", "echo 'nope' >&2; exit 42")
-// 	is.NoErr(err)
-// 	is.Equal(result.ExitCode, 42)
-// 	is.Equal(result.Stderr, "nope\n")
-// }
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm/sandbox"
+	"github.com/matthewmueller/llm/sandbox/local"
+)
+
+func TestTTY(t *testing.T) {
+	is := is.New(t)
+	sb := local.New(t.TempDir())
+
+	stdout := new(bytes.Buffer)
+	cmd := sb.CommandContext(context.Background(), "sh", "-c", "test -t 1 && echo tty; stty size")
+	cmd.Stdout = stdout
+	cmd.TTY = true
+	cmd.WindowSize = &sandbox.WindowSize{Rows: 40, Cols: 120}
+	is.NoErr(cmd.Run())
+	is.Equal(stdout.String(), "tty\r\n40 120\r\n")
+}
+
+func TestCombinedOutput(t *testing.T) {
+	is := is.New(t)
+	sb := local.New(t.TempDir())
+
+	cmd := sb.CommandContext(context.Background(), "sh", "-c", "echo one; echo two >&2; echo three")
+	out, err := cmd.CombinedOutput()
+	is.NoErr(err)
+	is.Equal(string(out), "one\ntwo\nthree\n")
+}
+
+func TestOutput(t *testing.T) {
+	is := is.New(t)
+	sb := local.New(t.TempDir())
+
+	cmd := sb.CommandContext(context.Background(), "sh", "-c", "echo out; echo err >&2")
+	out, err := cmd.Output()
+	is.NoErr(err)
+	is.Equal(string(out), "out\n")
+}
+
+func TestAllowedCommands(t *testing.T) {
+	is := is.New(t)
+	sb := local.New(t.TempDir(), local.WithAllowedCommands("echo"))
+
+	out, err := sb.Command("echo", "hi").Output()
+	is.NoErr(err)
+	is.Equal(string(out), "hi\n")
+
+	_, err = sb.Command("sh", "-c", "echo hi").Output()
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), `command "sh" is not allowed`))
+}
+
+func TestDeniedPaths(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	is.NoErr(os.MkdirAll(filepath.Join(dir, "secrets"), 0755))
+	is.NoErr(os.WriteFile(filepath.Join(dir, "secrets", "key"), []byte("shh"), 0644))
+	is.NoErr(os.WriteFile(filepath.Join(dir, "readme"), []byte("hello"), 0644))
+	sb := local.New(dir, local.WithDeniedPaths("secrets"))
+
+	out, err := sb.Command("cat", "./readme").Output()
+	is.NoErr(err)
+	is.Equal(string(out), "hello")
+
+	_, err = sb.Command("cat", "secrets/key").Output()
+	is.True(err != nil)
+
+	_, err = sb.Command("cat", "--file="+filepath.Join(dir, "secrets", "key")).Output()
+	is.True(err != nil)
+
+	cmd := sb.Command("ls")
+	cmd.Dir = "secrets"
+	_, err = cmd.Output()
+	is.True(err != nil)
+}
+
+func TestDeniedBareNames(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	is.NoErr(os.MkdirAll(filepath.Join(dir, "secrets"), 0755))
+	is.NoErr(os.WriteFile(filepath.Join(dir, ".env"), []byte("TOKEN=shh"), 0644))
+	is.NoErr(os.WriteFile(filepath.Join(dir, "readme"), []byte("hello"), 0644))
+	sb := local.New(dir, local.WithDeniedPaths("secrets", ".env"))
+
+	out, err := sb.Command("cat", "readme").Output()
+	is.NoErr(err)
+	is.Equal(string(out), "hello")
+
+	_, err = sb.Command("cat", ".env").Output()
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "is denied"))
+
+	_, err = sb.Command("ls", "-la", "secrets").Output()
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "is denied"))
+}
+
+func TestEnv(t *testing.T) {
+	is := is.New(t)
+	sb := local.New(t.TempDir())
+
+	cmd := sb.Command("sh", "-c", "echo $GREETING $HOME")
+	cmd.Env = []string{"GREETING=hello"}
+	out, err := cmd.Output()
+	is.NoErr(err)
+	is.Equal(string(out), "hello "+os.Getenv("HOME")+"\n")
+}
+
+func TestNoTTY(t *testing.T) {
+	is := is.New(t)
+	sb := local.New(t.TempDir())
+
+	stdout := new(bytes.Buffer)
+	cmd := sb.CommandContext(context.Background(), "sh", "-c", "test -t 1 || echo notty")
+	cmd.Stdout = stdout
+	is.NoErr(cmd.Run())
+	is.Equal(stdout.String(), "notty\n")
+}
+
+func TestCommandContextStreaming(t *testing.T) {
+	is := is.New(t)
+	sb := local.New(t.TempDir())
+
+	cmd := sb.CommandContext(context.Background(), "sh", "-lc", "printf 'hello\\n'; sleep 0.2; printf 'world\\n'")
+	stdout, err := cmd.StdoutPipe()
+	is.NoErr(err)
+
+	is.NoErr(cmd.Start())
+
+	reader := bufio.NewReader(stdout)
+	firstLine := make(chan string, 1)
+	go func() {
+		line, _ := reader.ReadString('\n')
+		firstLine <- line
+	}()
+
+	select {
+	case line := <-firstLine:
+		is.Equal(line, "hello\n")
+	case <-time.After(1 * time.Second):
+		t.Fatal("timed out waiting for first streamed line")
+	}
+
+	rest, err := io.ReadAll(reader)
+	is.NoErr(err)
+	is.Equal(string(rest), "world\n")
+
+	is.NoErr(cmd.Wait())
+	is.Equal(cmd.ExitCode(), 0)
+}
+
+func TestExecuteNonZeroExit(t *testing.T) {
+	is := is.New(t)
+	sb := local.New(t.TempDir())
+
+	stderr := new(bytes.Buffer)
+	cmd := sb.CommandContext(context.Background(), "sh", "-lc", "echo 'nope' >&2; exit 42")
+	cmd.Stderr = stderr
+	is.True(cmd.Run() != nil)
+	is.Equal(cmd.ExitCode(), 42)
+	is.Equal(stderr.String(), "nope\n")
+}
+
+func TestCopy(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	root, host := t.TempDir(), t.TempDir()
+	sb := local.New(root)
+
+	src := filepath.Join(host, "in.txt")
+	is.NoErr(os.WriteFile(src, []byte("hello"), 0644))
+	is.NoErr(sb.CopyTo(ctx, src, "dir/in.txt"))
+	out, err := sb.Command("cat", "dir/in.txt").Output()
+	is.NoErr(err)
+	is.Equal(string(out), "hello")
+
+	dst := filepath.Join(host, "out.txt")
+	is.NoErr(sb.CopyFrom(ctx, "dir/in.txt", dst))
+	data, err := os.ReadFile(dst)
+	is.NoErr(err)
+	is.Equal(string(data), "hello")
+
+	// Paths outside of the root are refused
+	is.True(sb.CopyTo(ctx, src, "../escape.txt") != nil)
+}
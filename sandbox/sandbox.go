@@ -1,8 +1,11 @@
 package sandbox
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"io"
+	"sync"
 )
 
 type Executor interface {
@@ -17,12 +20,46 @@ type Exec struct {
 	exec Executor
 }
 
+// Close releases the executor's resources, like a running container. It's a
+// no-op for executors that don't hold onto any.
+func (e *Exec) Close() error {
+	if closer, ok := e.exec.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Copier is implemented by executors that can copy files in and out of the
+// sandbox
+type Copier interface {
+	CopyTo(ctx context.Context, localPath, remotePath string) error
+	CopyFrom(ctx context.Context, remotePath, localPath string) error
+}
+
+// CopyTo copies a file on the host into the sandbox
+func (e *Exec) CopyTo(ctx context.Context, localPath, remotePath string) error {
+	copier, ok := e.exec.(Copier)
+	if !ok {
+		return errors.New("sandbox: copying files isn't supported")
+	}
+	return copier.CopyTo(ctx, localPath, remotePath)
+}
+
+// CopyFrom copies a file out of the sandbox onto the host
+func (e *Exec) CopyFrom(ctx context.Context, remotePath, localPath string) error {
+	copier, ok := e.exec.(Copier)
+	if !ok {
+		return errors.New("sandbox: copying files isn't supported")
+	}
+	return copier.CopyFrom(ctx, remotePath, localPath)
+}
+
 func (e *Exec) Command(cmd string, args ...string) *Cmd {
 	return e.CommandContext(context.Background(), cmd, args...)
 }
 
 func (e *Exec) CommandContext(ctx context.Context, cmd string, args ...string) *Cmd {
-	return &Cmd{e.exec, ctx, cmd, args, "", nil, nil, nil, nil}
+	return &Cmd{exec: e.exec, ctx: ctx, Path: cmd, Args: args}
 }
 
 type Cmd struct {
@@ -31,12 +68,155 @@ type Cmd struct {
 	Path   string
 	Args   []string
 	Dir    string
-	Env    []string
+	Env    []string // KEY=value pairs added to the sandbox's environment
 	Stdin  io.Reader
 	Stdout io.Writer
 	Stderr io.Writer
+	// TTY runs the command in a pseudo-terminal. Output from stdout and stderr
+	// is combined and written to Stdout.
+	TTY bool
+	// WindowSize of the terminal when TTY is set (nil for the default size)
+	WindowSize *WindowSize
+
+	done    chan struct{} // Closed when the command finishes
+	err     error         // Result of running the command
+	closers []io.Closer   // Pipes to close when the command finishes
+}
+
+// WindowSize of a terminal in characters
+type WindowSize struct {
+	Rows uint16
+	Cols uint16
 }
 
+// Run starts the command and waits for it to finish
 func (c *Cmd) Run() error {
-	return c.exec.Run(c.ctx, c)
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Wait()
+}
+
+// Start the command without waiting for it to finish. Output written to
+// Stdout and Stderr streams as the command runs.
+func (c *Cmd) Start() error {
+	if c.done != nil {
+		return errors.New("sandbox: already started")
+	}
+	c.done = make(chan struct{})
+	go func() {
+		c.err = c.exec.Run(c.ctx, c)
+		for _, closer := range c.closers {
+			closer.Close()
+		}
+		close(c.done)
+	}()
+	return nil
+}
+
+// Wait for a started command to finish. Like os/exec, all reads from the
+// pipes should be done before calling Wait.
+func (c *Cmd) Wait() error {
+	if c.done == nil {
+		return errors.New("sandbox: not started")
+	}
+	<-c.done
+	return c.err
+}
+
+// ExitCode returns the exit code of the finished command, or -1 if it hasn't
+// finished or failed without exiting
+func (c *Cmd) ExitCode() int {
+	if c.done == nil {
+		return -1
+	}
+	select {
+	case <-c.done:
+	default:
+		return -1
+	}
+	if c.err == nil {
+		return 0
+	}
+	var exitErr interface{ ExitCode() int }
+	if errors.As(c.err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// StdoutPipe returns a pipe that's connected to the command's standard output
+// when it starts
+func (c *Cmd) StdoutPipe() (io.ReadCloser, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("sandbox: Stdout already set")
+	}
+	if c.done != nil {
+		return nil, errors.New("sandbox: StdoutPipe after process started")
+	}
+	pr, pw := io.Pipe()
+	c.Stdout = pw
+	c.closers = append(c.closers, pw)
+	return pr, nil
+}
+
+// StderrPipe returns a pipe that's connected to the command's standard error
+// when it starts
+func (c *Cmd) StderrPipe() (io.ReadCloser, error) {
+	if c.Stderr != nil {
+		return nil, errors.New("sandbox: Stderr already set")
+	}
+	if c.done != nil {
+		return nil, errors.New("sandbox: StderrPipe after process started")
+	}
+	pr, pw := io.Pipe()
+	c.Stderr = pw
+	c.closers = append(c.closers, pw)
+	return pr, nil
+}
+
+// Output runs the command and returns its standard output
+func (c *Cmd) Output() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("sandbox: Stdout already set")
+	}
+	stdout := new(bytes.Buffer)
+	c.Stdout = stdout
+	err := c.Run()
+	return stdout.Bytes(), err
+}
+
+// CombinedOutput runs the command and returns its standard output and
+// standard error interleaved in the order they were written, like 2>&1
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("sandbox: Stdout already set")
+	}
+	if c.Stderr != nil {
+		return nil, errors.New("sandbox: Stderr already set")
+	}
+	out := &syncBuffer{}
+	c.Stdout = out
+	c.Stderr = out
+	err := c.Run()
+	return out.Bytes(), err
+}
+
+// syncBuffer is a buffer that's safe for executors to write stdout and stderr
+// to from separate goroutines
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
 }
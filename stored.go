@@ -0,0 +1,117 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"unicode/utf8"
+)
+
+// ResultStore keeps tool results that are too large to send to the model. The
+// model gets a preview and a reference in place of the result, and can page
+// through the rest with the tool in tool/stored. It keeps the most recent
+// results, dropping the oldest once it holds maxStoredResults.
+type ResultStore struct {
+	limit int
+
+	mu      sync.RWMutex
+	results map[string]string
+	refs    []string // Refs in the order they were stored
+}
+
+// maxStoredResults bounds the store for long-lived clients
+const maxStoredResults = 100
+
+// NewResultStore creates a store for tool results larger than limit bytes
+func NewResultStore(limit int) *ResultStore {
+	return &ResultStore{
+		limit:   limit,
+		results: map[string]string{},
+	}
+}
+
+// WithResultStore stores tool results that are larger than the store's limit
+// and replaces them with a preview and a reference. Pair it with the tool in
+// tool/stored so the model can read the full result when it needs to.
+func WithResultStore(store *ResultStore) Option {
+	return func(c *Config) {
+		c.ResultStore = store
+	}
+}
+
+// StoredResult replaces an oversized tool result in the conversation
+type StoredResult struct {
+	Ref     string `json:"ref"`
+	Size    int    `json:"size" description:"The size of the full result in bytes"`
+	Preview string `json:"preview"`
+	Note    string `json:"note"`
+}
+
+// shrink stores the result under the ref when it's over the limit, returning
+// the reference to send in its place
+func (s *ResultStore) shrink(ref, result string) string {
+	if len(result) <= s.limit {
+		return result
+	}
+	s.Put(ref, result)
+	out, err := json.Marshal(&StoredResult{
+		Ref:     ref,
+		Size:    len(result),
+		Preview: prefix(result, s.limit),
+		Note:    "The result was too large to include. Use the get_stored tool with the ref to read the rest.",
+	})
+	if err != nil {
+		return result
+	}
+	return string(out)
+}
+
+// Put stores a result under the ref, replacing any result already stored
+// there. The oldest result is dropped when the store is full.
+func (s *ResultStore) Put(ref, result string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.results[ref]; !ok {
+		s.refs = append(s.refs, ref)
+	}
+	s.results[ref] = result
+	for len(s.refs) > maxStoredResults {
+		delete(s.results, s.refs[0])
+		s.refs = s.refs[1:]
+	}
+}
+
+// Get reads up to limit bytes of a stored result starting at offset. A limit
+// of zero reads up to the store's limit. It returns the offset of the next
+// page, or -1 when there's nothing left to read.
+func (s *ResultStore) Get(ref string, offset, limit int) (content string, next int, err error) {
+	s.mu.RLock()
+	result, ok := s.results[ref]
+	s.mu.RUnlock()
+	if !ok {
+		return "", -1, fmt.Errorf("llm: stored result %q not found", ref)
+	}
+	if offset < 0 || offset > len(result) {
+		return "", -1, fmt.Errorf("llm: offset %d is outside of stored result %q", offset, ref)
+	}
+	if limit <= 0 {
+		limit = s.limit
+	}
+	content = prefix(result[offset:], limit)
+	if offset+len(content) >= len(result) {
+		return content, -1, nil
+	}
+	return content, offset + len(content), nil
+}
+
+// prefix returns up to n bytes from the start of s without splitting a rune
+func prefix(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	end := n
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end]
+}
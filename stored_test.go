@@ -0,0 +1,131 @@
+package llm_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func TestResultStore(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	read := llm.Func("read", "Read a file", func(ctx context.Context, in struct{}) (string, error) {
+		return strings.Repeat("a", 100), nil
+	})
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{toolCall("call_1", "read", `{}`), done()},
+			{text("Done"), done()},
+		},
+	}
+	store := llm.NewResultStore(20)
+	for _, err := range llm.New(provider).Chat(ctx, provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithTool(read),
+		llm.WithResultStore(store),
+		llm.WithIDGenerator(func() string { return "ref_1" }),
+	) {
+		is.NoErr(err)
+	}
+
+	// The model gets a preview and a reference instead of the result
+	messages := provider.Requests()[1].Messages
+	result := new(llm.StoredResult)
+	is.NoErr(json.Unmarshal([]byte(messages[len(messages)-1].Content), result))
+	is.Equal(result.Ref, "ref_1")
+	is.Equal(result.Size, 102) // Quoted as a JSON string
+	is.Equal(len(result.Preview), 20)
+
+	// The full result can be paged through
+	content, next, err := store.Get("ref_1", 0, 60)
+	is.NoErr(err)
+	is.Equal(len(content), 60)
+	is.Equal(next, 60)
+	content, next, err = store.Get("ref_1", next, 60)
+	is.NoErr(err)
+	is.Equal(len(content), 42)
+	is.Equal(next, -1)
+
+	_, _, err = store.Get("missing", 0, 0)
+	is.True(err != nil)
+}
+
+func TestResultStoreUnderLimit(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{toolCall("call_1", "add", `{"a":20,"b":22}`), done()},
+			{text("42"), done()},
+		},
+	}
+	for _, err := range llm.New(provider).Chat(ctx, provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithTool(addTool),
+		llm.WithResultStore(llm.NewResultStore(20)),
+	) {
+		is.NoErr(err)
+	}
+	messages := provider.Requests()[1].Messages
+	is.Equal(messages[len(messages)-1].Content, "42")
+}
+
+func TestResultStoreSameCallID(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	type In struct {
+		Name string `json:"name"`
+	}
+	read := llm.Func("read", "Read a file", func(ctx context.Context, in In) (string, error) {
+		return strings.Repeat(in.Name, 50), nil
+	})
+	// Gemini uses the function name as the tool call ID
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{toolCall("read", "read", `{"name":"a"}`), toolCall("read", "read", `{"name":"b"}`), done()},
+			{text("Done"), done()},
+		},
+	}
+	ids := 0
+	store := llm.NewResultStore(20)
+	for _, err := range llm.New(provider).Chat(ctx, provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithTool(read),
+		llm.WithResultStore(store),
+		llm.WithIDGenerator(func() string { ids++; return fmt.Sprintf("ref_%d", ids) }),
+	) {
+		is.NoErr(err)
+	}
+
+	// Each result is stored under its own ref
+	messages := provider.Requests()[1].Messages
+	for _, message := range messages[len(messages)-2:] {
+		result := new(llm.StoredResult)
+		is.NoErr(json.Unmarshal([]byte(message.Content), result))
+		content, _, err := store.Get(result.Ref, 0, 100)
+		is.NoErr(err)
+		is.Equal(content, `"`+strings.Repeat(result.Preview[1:2], 50)+`"`)
+	}
+}
+
+func TestResultStoreBounded(t *testing.T) {
+	is := is.New(t)
+	store := llm.NewResultStore(20)
+	for i := range 101 {
+		store.Put(fmt.Sprintf("ref_%d", i), "result")
+	}
+	// The oldest result is dropped
+	_, _, err := store.Get("ref_0", 0, 0)
+	is.True(err != nil)
+	content, _, err := store.Get("ref_100", 0, 0)
+	is.NoErr(err)
+	is.Equal(content, "result")
+}
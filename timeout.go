@@ -0,0 +1,71 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"time"
+)
+
+// DefaultTimeout is how long a provider may go without sending anything when
+// the caller's context doesn't have a deadline. The wait restarts with each
+// event, so it bounds the wait for the first token and the gaps between
+// tokens, not the length of the whole response. Reasoning models can think
+// for minutes before the first token, so it grows with the thinking level: 2
+// minutes without thinking, then 3, 5 and 10 minutes for low, medium and high.
+// Set a deadline on the context to use your own timeout instead.
+func DefaultTimeout(thinking Thinking) time.Duration {
+	switch thinking {
+	case ThinkingNone:
+		return 2 * time.Minute
+	case ThinkingLow:
+		return 3 * time.Minute
+	case ThinkingHigh:
+		return 10 * time.Minute
+	default:
+		return 5 * time.Minute
+	}
+}
+
+// timeouter is implemented by providers with their own default timeouts
+type timeouter interface {
+	Timeout(thinking Thinking) time.Duration
+}
+
+// requestTimeout returns the provider's default timeout for the thinking level
+func requestTimeout(provider Provider, thinking Thinking) time.Duration {
+	if t, ok := provider.(timeouter); ok {
+		if timeout := t.Timeout(thinking); timeout > 0 {
+			return timeout
+		}
+	}
+	return DefaultTimeout(thinking)
+}
+
+// withTimeout cancels the chat when the provider goes quiet for longer than
+// the timeout, unless the context already has a deadline. The timer is paused
+// while the caller handles each event so a slow consumer isn't mistaken for a
+// stalled provider.
+func withTimeout(ctx context.Context, timeout time.Duration, chat func(context.Context) iter.Seq2[*ChatResponse, error]) iter.Seq2[*ChatResponse, error] {
+	if _, ok := ctx.Deadline(); ok {
+		return chat(ctx)
+	}
+	return func(yield func(*ChatResponse, error) bool) {
+		ctx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+		// Wrap the deadline error so retries and fallbacks treat it the same
+		idle := fmt.Errorf("llm: no response from the provider for %s: %w", timeout, context.DeadlineExceeded)
+		timer := time.AfterFunc(timeout, func() { cancel(idle) })
+		defer timer.Stop()
+		for res, err := range chat(ctx) {
+			timer.Stop()
+			if err != nil && context.Cause(ctx) == idle {
+				err = idle
+			}
+			if !yield(res, err) {
+				return
+			}
+			timer.Reset(timeout)
+		}
+	}
+}
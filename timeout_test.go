@@ -0,0 +1,90 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+// slowProvider waits before each event of its response
+type slowProvider struct {
+	fakeProvider
+	timeout   time.Duration
+	delays    []time.Duration
+	deadlines []time.Time
+}
+
+func (p *slowProvider) Timeout(thinking llm.Thinking) time.Duration {
+	return p.timeout
+}
+
+func (p *slowProvider) Chat(ctx context.Context, req *llm.ChatRequest) iter.Seq2[*llm.ChatResponse, error] {
+	deadline, _ := ctx.Deadline()
+	p.deadlines = append(p.deadlines, deadline)
+	return func(yield func(*llm.ChatResponse, error) bool) {
+		for _, delay := range p.delays {
+			select {
+			case <-ctx.Done():
+				yield(nil, ctx.Err())
+				return
+			case <-time.After(delay):
+			}
+			if !yield(text("."), nil) {
+				return
+			}
+		}
+		yield(done(), nil)
+	}
+}
+
+func TestDefaultTimeout(t *testing.T) {
+	is := is.New(t)
+	is.Equal(llm.DefaultTimeout(llm.ThinkingNone), 2*time.Minute)
+	is.Equal(llm.DefaultTimeout(llm.ThinkingLow), 3*time.Minute)
+	is.Equal(llm.DefaultTimeout(llm.ThinkingMedium), 5*time.Minute)
+	is.Equal(llm.DefaultTimeout(llm.ThinkingHigh), 10*time.Minute)
+	is.Equal(llm.DefaultTimeout(""), 5*time.Minute)
+}
+
+func TestChatTimeout(t *testing.T) {
+	is := is.New(t)
+	provider := &slowProvider{timeout: 100 * time.Millisecond}
+	client := llm.New(provider)
+	chat := func(ctx context.Context) (content string, err error) {
+		for res, err := range client.Chat(ctx, provider.Name(), llm.WithModel("fake-model")) {
+			if err != nil {
+				return content, err
+			}
+			content += res.Content
+		}
+		return content, nil
+	}
+
+	// The timeout restarts with each event, so a steady stream can run longer
+	provider.delays = []time.Duration{40 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond}
+	content, err := chat(context.Background())
+	is.NoErr(err)
+	is.Equal(content, "....")
+
+	// A provider that goes quiet times out
+	provider.delays = []time.Duration{0, 300 * time.Millisecond}
+	content, err = chat(context.Background())
+	is.True(errors.Is(err, context.DeadlineExceeded))
+	is.True(strings.Contains(err.Error(), "no response from the provider for 100ms"))
+	is.Equal(content, ".")
+
+	// The caller's deadline wins
+	provider.delays = nil
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	_, err = chat(ctx)
+	is.NoErr(err)
+	is.Equal(provider.deadlines[2], deadline)
+}
@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WithTimeZone tells the model the user's time zone and today's date, so it
+// can resolve requests like "3pm tomorrow", and makes tools that read the time
+// zone with TimeZone use it
+func WithTimeZone(location *time.Location) Option {
+	return func(c *Config) {
+		c.TimeZone = location
+	}
+}
+
+type timeZoneKey struct{}
+
+// TimeZone returns the user's time zone for tools to use. It defaults to the
+// local time zone when WithTimeZone isn't set.
+func TimeZone(ctx context.Context) *time.Location {
+	if location, ok := ctx.Value(timeZoneKey{}).(*time.Location); ok {
+		return location
+	}
+	return time.Local
+}
+
+// withTimeZone adds the user's time zone to the context
+func withTimeZone(ctx context.Context, location *time.Location) context.Context {
+	return context.WithValue(ctx, timeZoneKey{}, location)
+}
+
+// timeMessage tells the model the user's time zone and today's date. It goes
+// after any leading system messages so the system prompt stays first. Only
+// the date is included so the message changes once a day rather than every
+// minute, keeping prompt caches and stored responses valid across turns.
+// Tools like clock.Now give the model the exact time when it needs it.
+func timeMessage(messages []*Message, location *time.Location, now time.Time) []*Message {
+	now = now.In(location)
+	message := SystemMessage(fmt.Sprintf("The user's time zone is %s (UTC%s). Today is %s in their time zone.",
+		location, now.Format("-07:00"), now.Format("Monday, January 2, 2006")))
+	i := 0
+	for i < len(messages) && messages[i].Role == "system" {
+		i++
+	}
+	return append(append(append([]*Message{}, messages[:i]...), message), messages[i:]...)
+}
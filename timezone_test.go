@@ -0,0 +1,61 @@
+package llm_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func TestTimeZone(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &fakeProvider{
+		turns: [][]*llm.ChatResponse{
+			{toolCall("call_1", "zone", `{}`), done()},
+			{text("Done"), done()},
+		},
+	}
+	var zone string
+	tool := llm.Func("zone", "Get the user's time zone", func(ctx context.Context, in struct{}) (string, error) {
+		zone = llm.TimeZone(ctx).String()
+		return zone, nil
+	})
+	client := llm.New(provider)
+	for _, err := range client.Chat(ctx, provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithTimeZone(time.FixedZone("JST", 9*60*60)),
+		llm.WithTool(tool),
+		llm.WithMessage(
+			llm.SystemMessage("You are a scheduler"),
+			llm.UserMessage("Schedule lunch for noon tomorrow"),
+		),
+	) {
+		is.NoErr(err)
+	}
+
+	// Tools see the time zone
+	is.Equal(zone, "JST")
+
+	// The model is told the date after the system prompt
+	requests := provider.Requests()
+	messages := requests[0].Messages
+	is.Equal(len(messages), 3)
+	is.Equal(messages[0].Content, "You are a scheduler")
+	is.Equal(messages[1].Role, "system")
+	is.True(strings.HasPrefix(messages[1].Content, "The user's time zone is JST (UTC+09:00). Today is "))
+	is.True(strings.HasSuffix(messages[1].Content, " in their time zone."))
+	is.Equal(messages[2].Content, "Schedule lunch for noon tomorrow")
+
+	// The next step keeps the same prefix so caches stay valid
+	is.Equal(requests[1].Messages[:3], messages)
+}
+
+func TestTimeZoneDefault(t *testing.T) {
+	is := is.New(t)
+	is.Equal(llm.TimeZone(context.Background()), time.Local)
+}
@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// CountTokens counts the input tokens the request built from the options
+// would use with the provider's tokenizer
+func (c *Client) CountTokens(ctx context.Context, provider string, options ...Option) (int, error) {
+	config := &Config{
+		Thinking: ThinkingMedium,
+	}
+	for _, option := range options {
+		option(config)
+	}
+	p, err := c.findProvider(provider)
+	if err != nil {
+		return 0, err
+	}
+	setDefaultModel(config, p)
+	counter, ok := p.(TokenCounter)
+	if !ok {
+		return 0, fmt.Errorf("llm: provider %q doesn't support counting tokens", p.Name())
+	}
+	return counter.CountTokens(ctx, &ChatRequest{
+		Model:    config.Model,
+		Thinking: config.Thinking,
+		Tools:    toolSchemas(config.tools()),
+		Messages: config.Messages,
+	})
+}
+
+// Rough numbers for estimating tokens without a tokenizer
+const (
+	charsPerToken   = 4 // Most tokenizers average about 4 characters per token
+	messageOverhead = 4 // Tokens for the role and message framing
+)
+
+// EstimateTokens approximates the input tokens for the messages and tools
+// without a round trip to the provider. It's meant for warning before a
+// request exceeds the context window, not for exact counts. Use
+// Client.CountTokens when the provider supports it.
+func EstimateTokens(messages []*Message, tools []*ToolSchema) int {
+	chars, overhead := 0, 0
+	for _, message := range messages {
+		overhead += messageOverhead
+		chars += len(message.Content) + len(message.Thinking) + len(message.ToolCallID)
+		if message.ToolCall != nil {
+			chars += len(message.ToolCall.Name) + len(message.ToolCall.Arguments)
+		}
+		for _, part := range message.Parts {
+			chars += len(part.Text)
+		}
+	}
+	for _, tool := range tools {
+		schema, err := json.Marshal(tool)
+		if err != nil {
+			continue
+		}
+		chars += len(schema)
+	}
+	return overhead + (chars+charsPerToken-1)/charsPerToken
+}
@@ -0,0 +1,71 @@
+package llm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+// countingProvider counts each character as a token
+type countingProvider struct {
+	fakeProvider
+	req *llm.ChatRequest
+}
+
+func (p *countingProvider) CountTokens(ctx context.Context, req *llm.ChatRequest) (int, error) {
+	p.req = req
+	count := 0
+	for _, message := range req.Messages {
+		count += len(message.Content)
+	}
+	return count, nil
+}
+
+func TestCountTokens(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	provider := &countingProvider{}
+	client := llm.New(provider)
+	count, err := client.CountTokens(ctx, provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithTool(addTool),
+		llm.WithMessage(llm.SystemMessage("Be brief"), llm.UserMessage("Hello")),
+	)
+	is.NoErr(err)
+	is.Equal(count, 13)
+	is.Equal(provider.req.Model, "fake-model")
+	is.Equal(len(provider.req.Tools), 1)
+}
+
+func TestCountTokensUnsupported(t *testing.T) {
+	is := is.New(t)
+	provider := &fakeProvider{}
+	client := llm.New(provider)
+	_, err := client.CountTokens(context.Background(), provider.Name(), llm.WithModel("fake-model"))
+	is.True(err != nil)
+}
+
+func TestEstimateTokens(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(llm.EstimateTokens(nil, nil), 0)
+
+	// 4 tokens of overhead plus 8 characters of content
+	messages := []*llm.Message{llm.UserMessage("12345678")}
+	is.Equal(llm.EstimateTokens(messages, nil), 6)
+
+	// Thinking and tool calls count too
+	messages = append(messages, &llm.Message{
+		Role:     "assistant",
+		Thinking: "1234",
+		ToolCall: &llm.ToolCall{ID: "call_1", Name: "add", Arguments: []byte(`{"a":1}`)},
+	})
+	is.Equal(llm.EstimateTokens(messages, nil), 14)
+
+	// Tool schemas add to the estimate
+	tools := []*llm.ToolSchema{addTool.Schema()}
+	is.True(llm.EstimateTokens(messages, tools) > 14)
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Tool interface - high-level typed tool definition
@@ -14,12 +16,51 @@ type Tool interface {
 	Run(ctx context.Context, in json.RawMessage) (out []byte, err error)
 }
 
+// MediaTool is implemented by tools whose results can include images, like
+// reading an image file. Chat calls RunParts instead of Run and sends the
+// images back to the model alongside the text of the result.
+type MediaTool interface {
+	Tool
+	RunParts(ctx context.Context, in json.RawMessage) (parts []*ContentPart, err error)
+}
+
+// runTool runs the tool, splitting a media tool's text parts from the images
+// that go with them
+func runTool(ctx context.Context, tool Tool, args json.RawMessage) (content string, images []*ContentPart, err error) {
+	media, ok := tool.(MediaTool)
+	if !ok {
+		out, err := tool.Run(ctx, args)
+		return string(out), nil, err
+	}
+	parts, err := media.RunParts(ctx, args)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, part := range parts {
+		if part.Type == "text" {
+			content += part.Text
+			continue
+		}
+		images = append(images, part)
+	}
+	return content, images, nil
+}
+
 // ToolCall represents a tool invocation from the model
 type ToolCall struct {
 	ID               string          `json:"id,omitzero"`
 	Name             string          `json:"name,omitzero"`
 	Arguments        json.RawMessage `json:"arguments,omitzero"`
 	ThoughtSignature []byte          `json:"thought_signature,omitzero"`
+	// Reasoning the model did before the call, replayed ahead of it
+	Reasoning []*ReasoningItem `json:"reasoning,omitzero"`
+}
+
+// ReasoningItem is an opaque reasoning item that some providers need replayed
+// alongside the tool calls that followed it
+type ReasoningItem struct {
+	ID               string `json:"id,omitzero"`
+	EncryptedContent string `json:"encrypted_content,omitzero"`
 }
 
 // ToolSchema defines a tool's JSON schema specification
@@ -48,6 +89,16 @@ type ToolProperty struct {
 	Description string
 	Enum        []string
 	Items       *ToolProperty
+	Properties  map[string]*ToolProperty // For object properties
+	Required    []string                 // Required object properties
+	// Schema for map values (nil allows any value)
+	AdditionalProperties *ToolProperty
+	Minimum              *float64 // Minimum number value
+	Maximum              *float64 // Maximum number value
+	MinLength            *int     // Minimum string length
+	MaxLength            *int     // Maximum string length
+	Pattern              string   // Regular expression strings must match
+	Default              any      // Default value (nil if none)
 }
 
 func toolSchemas(tools []Tool) []*ToolSchema {
@@ -93,14 +144,22 @@ func (t *typedFunc[In, Out]) Run(ctx context.Context, args json.RawMessage) ([]b
 	var in In
 	if len(args) > 0 {
 		if err := json.Unmarshal(args, &in); err != nil {
-			return nil, fmt.Errorf("tool %s: unmarshaling input: %w", t.name, err)
+			// Retry with numbers like 42.0 or "42" coerced to the field types
+			coerced, ok := coerceNumbers(args, reflect.TypeOf(&in).Elem())
+			if !ok {
+				return nil, fmt.Errorf("tool %s: unmarshaling input: %w", t.name, err)
+			}
+			in = *new(In)
+			if err := json.Unmarshal(coerced, &in); err != nil {
+				return nil, fmt.Errorf("tool %s: unmarshaling input: %w", t.name, err)
+			}
 		}
 	}
 	out, err := t.run(ctx, in)
 	if err != nil {
 		return nil, err
 	}
-	return json.Marshal(out)
+	return CanonicalJSON(out)
 }
 
 // generateSchema creates ToolFunctionParameters from a struct type
@@ -109,6 +168,13 @@ func (t *typedFunc[In, Out]) Run(ctx context.Context, args json.RawMessage) ([]b
 //   - `description:"text"` - field description for the schema
 //   - `enums:"a,b,c"` - allowed values (comma-separated)
 //   - `is:"required"` - marks field as required (presence only, no value)
+//   - `min:"1"`, `max:"100"` - numeric bounds
+//   - `minLength:"1"`, `maxLength:"64"` - string length bounds
+//   - `pattern:"^[a-z]+$"` - regular expression strings must match
+//   - `default:"10"` - default value, coerced to the field's JSON type
+//
+// Nested structs are described with the same tags. Pointer fields describe
+// their underlying type and are always optional.
 func generateSchema(v any) *ToolFunctionParameters {
 	params := &ToolFunctionParameters{
 		Type:       "object",
@@ -117,6 +183,9 @@ func generateSchema(v any) *ToolFunctionParameters {
 	}
 
 	t := reflect.TypeOf(v)
+	if t == nil {
+		return params
+	}
 	if t.Kind() == reflect.Ptr {
 		t = t.Elem()
 	}
@@ -124,6 +193,19 @@ func generateSchema(v any) *ToolFunctionParameters {
 		return params
 	}
 
+	params.Properties, params.Required = structSchema(t, map[reflect.Type]bool{})
+	return params
+}
+
+// structSchema describes the exported fields of a struct. Seen tracks the
+// structs we're inside of to avoid recursing forever on recursive types.
+func structSchema(t reflect.Type, seen map[reflect.Type]bool) (properties map[string]*ToolProperty, required []string) {
+	properties = make(map[string]*ToolProperty)
+	required = []string{}
+
+	seen[t] = true
+	defer delete(seen, t)
+
 	for i := range t.NumField() {
 		field := t.Field(i)
 		if !field.IsExported() {
@@ -148,21 +230,72 @@ func generateSchema(v any) *ToolFunctionParameters {
 			enums = strings.Split(enumTag, ",")
 		}
 
-		prop := schemaType(field.Type)
+		prop := schemaType(field.Type, seen)
 		prop.Description = description
 		prop.Enum = enums
-		params.Properties[name] = prop
+		constrain(prop, field.Tag)
+		properties[name] = prop
 
-		// Check if required
-		if field.Tag.Get("is") == "required" {
-			params.Required = append(params.Required, name)
+		// Check if required, pointers are optional since they may be nil
+		if field.Tag.Get("is") == "required" && field.Type.Kind() != reflect.Ptr {
+			required = append(required, name)
 		}
 	}
 
-	return params
+	return properties, required
+}
+
+// constrain reads the constraint and default tags into the property. Values
+// that don't parse are ignored.
+func constrain(prop *ToolProperty, tag reflect.StructTag) {
+	if v, err := strconv.ParseFloat(tag.Get("min"), 64); err == nil {
+		prop.Minimum = &v
+	}
+	if v, err := strconv.ParseFloat(tag.Get("max"), 64); err == nil {
+		prop.Maximum = &v
+	}
+	if v, err := strconv.Atoi(tag.Get("minLength")); err == nil {
+		prop.MinLength = &v
+	}
+	if v, err := strconv.Atoi(tag.Get("maxLength")); err == nil {
+		prop.MaxLength = &v
+	}
+	prop.Pattern = tag.Get("pattern")
+	if value, ok := tag.Lookup("default"); ok {
+		prop.Default = defaultValue(prop.Type, value)
+	}
+}
+
+// defaultValue coerces the default tag to the property's JSON type. Values
+// that don't parse are ignored.
+func defaultValue(kind, value string) any {
+	switch kind {
+	case "string":
+		return value
+	case "integer":
+		if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return v
+		}
+	case "number":
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			return v
+		}
+	case "boolean":
+		if v, err := strconv.ParseBool(value); err == nil {
+			return v
+		}
+	default:
+		var v any
+		if err := json.Unmarshal([]byte(value), &v); err == nil {
+			return v
+		}
+	}
+	return nil
 }
 
-func schemaType(t reflect.Type) *ToolProperty {
+var timeType = reflect.TypeFor[time.Time]()
+
+func schemaType(t reflect.Type, seen map[reflect.Type]bool) *ToolProperty {
 	for t.Kind() == reflect.Ptr {
 		t = t.Elem()
 	}
@@ -178,9 +311,22 @@ func schemaType(t reflect.Type) *ToolProperty {
 		prop.Type = "boolean"
 	case reflect.Slice, reflect.Array:
 		prop.Type = "array"
-		prop.Items = schemaType(t.Elem())
-	case reflect.Struct, reflect.Map:
+		prop.Items = schemaType(t.Elem(), seen)
+	case reflect.Struct:
+		// Times are marshaled as strings
+		if t == timeType {
+			break
+		}
 		prop.Type = "object"
+		if !seen[t] {
+			prop.Properties, prop.Required = structSchema(t, seen)
+		}
+	case reflect.Map:
+		prop.Type = "object"
+		// Leave maps of any type open
+		if t.Elem().Kind() != reflect.Interface {
+			prop.AdditionalProperties = schemaType(t.Elem(), seen)
+		}
 	}
 
 	return prop
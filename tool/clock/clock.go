@@ -0,0 +1,268 @@
+package clock
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/matthewmueller/llm"
+)
+
+const nowDescription = `Gets the current date and time in the user's time zone.
+- Use this tool whenever you need today's date or the current time instead of guessing
+`
+
+const parseDescription = `Resolves a date expression to an absolute date in the user's time zone.
+- Understands expressions like ` + "`" + `tomorrow at 3pm` + "`" + `, ` + "`" + `next friday` + "`" + `, ` + "`" + `in 2 weeks` + "`" + `, ` + "`" + `3 days ago` + "`" + `, ` + "`" + `march 4` + "`" + ` and ` + "`" + `2026-03-04 09:30` + "`" + `
+- ` + "`" + `next friday` + "`" + ` is the first Friday after today and ` + "`" + `this friday` + "`" + ` may be today
+- Use this tool before scheduling anything relative to today
+`
+
+type NowOut struct {
+	RFC3339  string `json:"rfc3339"`
+	Date     string `json:"date" description:"The date, like Monday, January 2, 2006"`
+	Time     string `json:"time" description:"The time, like 3:04 PM"`
+	TimeZone string `json:"time_zone"`
+	Offset   string `json:"offset" description:"The offset from UTC, like -07:00"`
+}
+
+type ParseIn struct {
+	Expression string `json:"expression" is:"required" description:"The date expression to resolve, like next friday at 3pm"`
+}
+
+type ParseOut struct {
+	RFC3339 string `json:"rfc3339"`
+	Date    string `json:"date" description:"The date, like 2006-01-02"`
+	Weekday string `json:"weekday"`
+	Time    string `json:"time,omitzero" description:"The time, like 15:04, when the expression has one"`
+}
+
+// Now returns a tool that tells the model the current time in the time zone
+// set with llm.WithTimeZone
+func Now() llm.Tool {
+	return llm.Func("now", nowDescription, func(ctx context.Context, in struct{}) (*NowOut, error) {
+		now := time.Now().In(llm.TimeZone(ctx))
+		return &NowOut{
+			RFC3339:  now.Format(time.RFC3339),
+			Date:     now.Format("Monday, January 2, 2006"),
+			Time:     now.Format("3:04 PM"),
+			TimeZone: now.Location().String(),
+			Offset:   now.Format("-07:00"),
+		}, nil
+	})
+}
+
+// ParseDate returns a tool that resolves relative date expressions like "next
+// friday" to absolute dates in the time zone set with llm.WithTimeZone
+func ParseDate() llm.Tool {
+	return llm.Func("parse_date", parseDescription, func(ctx context.Context, in ParseIn) (*ParseOut, error) {
+		t, hasTime, err := parse(in.Expression, time.Now().In(llm.TimeZone(ctx)))
+		if err != nil {
+			return nil, err
+		}
+		out := &ParseOut{
+			RFC3339: t.Format(time.RFC3339),
+			Date:    t.Format("2006-01-02"),
+			Weekday: t.Weekday().String(),
+		}
+		if hasTime {
+			out.Time = t.Format("15:04")
+		}
+		return out, nil
+	})
+}
+
+var (
+	timeOfDay = regexp.MustCompile(`^(.*?)(?:\s*\bat)?\s*\b(noon|midnight|\d{1,2}(?::\d{2})?\s*[ap]\.?m\.?|\d{1,2}:\d{2})$`)
+	atHour    = regexp.MustCompile(`^(.*?)\s*\bat\s+(\d{1,2})$`)
+	inAmount  = regexp.MustCompile(`^in\s+(\d+|an?)\s+(minute|hour|day|week|month|year)s?$`)
+	agoAmount = regexp.MustCompile(`^(\d+|an?)\s+(minute|hour|day|week|month|year)s?\s+(ago|from now|later)$`)
+	relative  = regexp.MustCompile(`^(this|next|last)\s+(week|month|year)$`)
+	weekday   = regexp.MustCompile(`^(?:(this|next|last)\s+)?(sunday|monday|tuesday|wednesday|thursday|friday|saturday)$`)
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Layouts for absolute dates, with and without the year
+var (
+	layouts       = []string{"2006-01-02", "2006/01/02", "1/2/2006", "January 2 2006", "Jan 2 2006", "2 January 2006", "2 Jan 2006"}
+	layoutsNoYear = []string{"January 2", "Jan 2", "2 January", "2 Jan"}
+)
+
+// parse resolves the expression relative to now. It reports whether the
+// expression included a time of day.
+func parse(expression string, now time.Time) (t time.Time, hasTime bool, err error) {
+	expr := strings.Join(strings.Fields(strings.ToLower(strings.ReplaceAll(expression, ",", " "))), " ")
+
+	// Pull off the time of day first
+	hour, minute := -1, 0
+	if m := timeOfDay.FindStringSubmatch(expr); m != nil {
+		if hour, minute, err = parseTime(m[2]); err != nil {
+			return time.Time{}, false, fmt.Errorf("clock: %w in %q", err, expression)
+		}
+		expr = strings.TrimSpace(m[1])
+	} else if m := atHour.FindStringSubmatch(expr); m != nil {
+		hour, _ = strconv.Atoi(m[2])
+		if hour > 23 {
+			return time.Time{}, false, fmt.Errorf("clock: invalid hour in %q", expression)
+		}
+		expr = strings.TrimSpace(m[1])
+	}
+
+	day, exact, ok := parseDay(expr, now)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("clock: unable to understand the date %q", expression)
+	}
+	if hour >= 0 {
+		return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, now.Location()), true, nil
+	}
+	if exact {
+		return day.Truncate(time.Minute), true, nil
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, now.Location()), false, nil
+}
+
+// parseDay resolves the date part of an expression. Exact is true when the
+// result is a moment like "in 2 hours" rather than a day.
+func parseDay(expr string, now time.Time) (day time.Time, exact, ok bool) {
+	switch expr {
+	case "", "today":
+		return now, false, true
+	case "now":
+		return now, true, true
+	case "tomorrow":
+		return now.AddDate(0, 0, 1), false, true
+	case "yesterday":
+		return now.AddDate(0, 0, -1), false, true
+	case "day after tomorrow", "the day after tomorrow":
+		return now.AddDate(0, 0, 2), false, true
+	case "day before yesterday", "the day before yesterday":
+		return now.AddDate(0, 0, -2), false, true
+	}
+	if m := inAmount.FindStringSubmatch(expr); m != nil {
+		return add(now, amount(m[1]), m[2])
+	}
+	if m := agoAmount.FindStringSubmatch(expr); m != nil {
+		n := amount(m[1])
+		if m[3] == "ago" {
+			n = -n
+		}
+		return add(now, n, m[2])
+	}
+	if m := relative.FindStringSubmatch(expr); m != nil {
+		n := map[string]int{"this": 0, "next": 1, "last": -1}[m[1]]
+		return add(now, n, m[2])
+	}
+	if m := weekday.FindStringSubmatch(expr); m != nil {
+		diff := int(weekdays[m[2]] - now.Weekday())
+		switch m[1] {
+		case "next":
+			// The first one after today
+			if diff <= 0 {
+				diff += 7
+			}
+		case "last":
+			// The most recent one before today
+			if diff >= 0 {
+				diff -= 7
+			}
+		default:
+			// The upcoming one, which may be today
+			if diff < 0 {
+				diff += 7
+			}
+		}
+		return now.AddDate(0, 0, diff), false, true
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, expr, now.Location()); err == nil {
+			return t, false, true
+		}
+	}
+	// Dates without a year are the next time that date comes around
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for _, layout := range layoutsNoYear {
+		if t, err := time.ParseInLocation(layout, expr, now.Location()); err == nil {
+			t = time.Date(now.Year(), t.Month(), t.Day(), 0, 0, 0, 0, now.Location())
+			if t.Before(today) {
+				t = t.AddDate(1, 0, 0)
+			}
+			return t, false, true
+		}
+	}
+	return time.Time{}, false, false
+}
+
+// amount parses a count like "3" or "an"
+func amount(s string) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	return 1
+}
+
+// add moves now by n units
+func add(now time.Time, n int, unit string) (time.Time, bool, bool) {
+	switch unit {
+	case "minute":
+		return now.Add(time.Duration(n) * time.Minute), true, true
+	case "hour":
+		return now.Add(time.Duration(n) * time.Hour), true, true
+	case "day":
+		return now.AddDate(0, 0, n), false, true
+	case "week":
+		return now.AddDate(0, 0, 7*n), false, true
+	case "month":
+		return now.AddDate(0, n, 0), false, true
+	case "year":
+		return now.AddDate(n, 0, 0), false, true
+	}
+	return time.Time{}, false, false
+}
+
+// parseTime parses a time of day like "3pm", "9:30 a.m." or "15:00"
+func parseTime(s string) (hour, minute int, err error) {
+	switch s {
+	case "noon":
+		return 12, 0, nil
+	case "midnight":
+		return 0, 0, nil
+	}
+	s = strings.ReplaceAll(strings.ReplaceAll(s, ".", ""), " ", "")
+	suffix := ""
+	if strings.HasSuffix(s, "am") || strings.HasSuffix(s, "pm") {
+		suffix = s[len(s)-2:]
+		s = s[:len(s)-2]
+	}
+	hourText, minuteText, _ := strings.Cut(s, ":")
+	hour, _ = strconv.Atoi(hourText)
+	if minuteText != "" {
+		minute, _ = strconv.Atoi(minuteText)
+	}
+	switch {
+	case suffix != "" && (hour < 1 || hour > 12):
+		return 0, 0, fmt.Errorf("invalid hour")
+	case suffix == "" && hour > 23:
+		return 0, 0, fmt.Errorf("invalid hour")
+	case minute > 59:
+		return 0, 0, fmt.Errorf("invalid minute")
+	}
+	switch {
+	case suffix == "am" && hour == 12:
+		hour = 0
+	case suffix == "pm" && hour != 12:
+		hour += 12
+	}
+	return hour, minute, nil
+}
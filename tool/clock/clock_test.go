@@ -0,0 +1,58 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestParse(t *testing.T) {
+	// Saturday, October 17, 2026 at 10:15 AM
+	now := time.Date(2026, time.October, 17, 10, 15, 30, 0, time.FixedZone("JST", 9*60*60))
+	tests := []struct {
+		expression string
+		want       string
+		hasTime    bool
+	}{
+		{"today", "2026-10-17T00:00:00+09:00", false},
+		{"now", "2026-10-17T10:15:00+09:00", true},
+		{"Tomorrow at 3pm", "2026-10-18T15:00:00+09:00", true},
+		{"tomorrow at 9", "2026-10-18T09:00:00+09:00", true},
+		{"yesterday noon", "2026-10-16T12:00:00+09:00", true},
+		{"the day after tomorrow", "2026-10-19T00:00:00+09:00", false},
+		{"next friday", "2026-10-23T00:00:00+09:00", false},
+		{"next saturday", "2026-10-24T00:00:00+09:00", false},
+		{"this saturday", "2026-10-17T00:00:00+09:00", false},
+		{"last saturday", "2026-10-10T00:00:00+09:00", false},
+		{"Monday 9:30 a.m.", "2026-10-19T09:30:00+09:00", true},
+		{"in 2 hours", "2026-10-17T12:15:00+09:00", true},
+		{"in a week", "2026-10-24T00:00:00+09:00", false},
+		{"3 days ago", "2026-10-14T00:00:00+09:00", false},
+		{"next month", "2026-11-17T00:00:00+09:00", false},
+		{"2026-03-04 18:45", "2026-03-04T18:45:00+09:00", true},
+		{"March 4", "2027-03-04T00:00:00+09:00", false},
+		{"Dec 25, 2026 at midnight", "2026-12-25T00:00:00+09:00", true},
+		{"12am", "2026-10-17T00:00:00+09:00", true},
+	}
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			is := is.New(t)
+			got, hasTime, err := parse(test.expression, now)
+			is.NoErr(err)
+			is.Equal(got.Format(time.RFC3339), test.want)
+			is.Equal(hasTime, test.hasTime)
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	now := time.Date(2026, time.October, 17, 10, 15, 0, 0, time.UTC)
+	for _, expression := range []string{"whenever", "tomorrow at 13pm", "next blursday"} {
+		t.Run(expression, func(t *testing.T) {
+			is := is.New(t)
+			_, _, err := parse(expression, now)
+			is.True(err != nil)
+		})
+	}
+}
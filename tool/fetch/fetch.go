@@ -3,33 +3,79 @@ package fetch
 import (
 	"context"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/matthewmueller/llm"
 
 	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
+	"github.com/go-shiori/go-readability"
 )
 
+// maxContent is the most content in bytes returned to the model
+const maxContent = 100_000
+
+// maxPage is the most of a page in bytes that's read before converting it.
+// Pages are mostly markup, so this is much more than we return.
+const maxPage = 5 << 20
+
 const description = `
 - Fetches the URL content, converting HTML to markdown
+- For web pages, only the main content is returned without the navigation and other boilerplate
 - Use this tool when you need to retrieve and analyze the latest web content
+- Set ` + "`" + `offset` + "`" + ` or ` + "`" + `limit` + "`" + ` to page through large files. Ranges return the raw bytes without converting them, and ` + "`" + `next_offset` + "`" + ` is where the next page starts.
 `
 
 type In struct {
-	URL string `json:"url" is:"required" description:"The URL to fetch content from"`
+	URL    string `json:"url" is:"required" description:"The URL to fetch content from"`
+	Offset int64  `json:"offset" description:"The byte offset to start reading from"`
+	Limit  int64  `json:"limit" description:"The maximum number of bytes to read"`
 }
 
 type Out struct {
-	Status  int    `json:"status"`
-	Content string `json:"content"`
+	Status     int    `json:"status"`
+	Title      string `json:"title,omitzero"`
+	Content    string `json:"content"`
+	Truncated  bool   `json:"truncated,omitzero"`
+	Size       int64  `json:"size,omitzero" description:"The total size in bytes, when known"`
+	NextOffset int64  `json:"next_offset,omitzero" description:"The offset of the next page, when more content remains"`
+}
+
+// Option configures the fetch tool
+type Option func(*config)
+
+type config struct {
+	readability bool
 }
 
-func New(hc *http.Client) llm.Tool {
+// WithReadability toggles extracting the main content from HTML pages, like a
+// browser's reader view, so navigation, ads and other boilerplate are left
+// out. It's on by default.
+func WithReadability(enable bool) Option {
+	return func(c *config) {
+		c.readability = enable
+	}
+}
+
+func New(hc *http.Client, options ...Option) llm.Tool {
+	config := &config{
+		readability: true,
+	}
+	for _, option := range options {
+		option(config)
+	}
 	return llm.Func("Fetch", description, func(ctx context.Context, input In) (*Out, error) {
 		req, err := http.NewRequestWithContext(ctx, http.MethodGet, input.URL, nil)
 		if err != nil {
 			return nil, fmt.Errorf("fetch: failed to create request: %w", err)
 		}
+		if input.Offset > 0 || input.Limit > 0 {
+			return fetchRange(hc, req, input.Offset, input.Limit)
+		}
 
 		res, err := hc.Do(req)
 		if err != nil {
@@ -37,14 +83,157 @@ func New(hc *http.Client) llm.Tool {
 		}
 		defer res.Body.Close()
 
-		markdown, err := htmltomarkdown.ConvertReader(res.Body)
+		// Read enough of the page to find the main content, which often comes
+		// after a lot of markup, and truncate once it's converted
+		body, err := io.ReadAll(io.LimitReader(res.Body, maxPage+1))
+		if err != nil {
+			return nil, fmt.Errorf("fetch: failed to read response: %w", err)
+		}
+		html, truncated := truncate(string(body), maxPage)
+
+		// Extract the article from HTML pages, falling back to the whole page
+		// when there isn't one
+		title := ""
+		if config.readability && isHTML(res.Header.Get("Content-Type")) {
+			article, err := readability.FromReader(strings.NewReader(html), res.Request.URL)
+			if err == nil && strings.TrimSpace(article.TextContent) != "" {
+				title = article.Title
+				html = article.Content
+			}
+		}
+
+		markdown, err := htmltomarkdown.ConvertString(html)
 		if err != nil {
 			return nil, fmt.Errorf("fetch: failed to convert HTML to markdown: %w", err)
 		}
+		content, cut := truncate(markdown, maxContent)
 
 		return &Out{
-			Status:  res.StatusCode,
-			Content: string(markdown),
+			Status:    res.StatusCode,
+			Title:     title,
+			Content:   content,
+			Truncated: truncated || cut,
 		}, nil
 	})
 }
+
+// fetchRange fetches a range of bytes, asking the server for only that range
+// and skipping ahead when the server doesn't support ranges
+func fetchRange(hc *http.Client, req *http.Request, offset, limit int64) (*Out, error) {
+	if offset < 0 || limit < 0 {
+		return nil, fmt.Errorf("fetch: offset and limit can't be negative")
+	}
+	if limit == 0 || limit > maxContent {
+		limit = maxContent
+	}
+	// Ask for at least a whole rune so each page makes progress
+	want := max(limit, utf8.UTFMax)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+want-1))
+
+	res, err := hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch: request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	size := int64(-1)
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		size = rangeSize(res.Header.Get("Content-Range"))
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The offset is past the end
+		return &Out{
+			Status: res.StatusCode,
+			Size:   max(rangeSize(res.Header.Get("Content-Range")), 0),
+		}, nil
+	default:
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			break
+		}
+		// The server sent the whole body, so skip to the offset
+		size = res.ContentLength
+		if _, err := io.CopyN(io.Discard, res.Body, offset); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("fetch: failed to read response: %w", err)
+		}
+	}
+
+	// Read an extra byte to tell whether more content remains when the size
+	// isn't known
+	body, err := io.ReadAll(io.LimitReader(res.Body, want+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetch: failed to read response: %w", err)
+	}
+
+	// more reports whether content remains after the first end bytes
+	more := func(end int) bool {
+		if size >= 0 {
+			return offset+int64(end) < size
+		}
+		return end < len(body)
+	}
+
+	// Don't cut a rune in half, the next page picks it up instead
+	end := int(min(int64(len(body)), limit))
+	if more(end) {
+		start := end - 1
+		for start > 0 && end-start < utf8.UTFMax && !utf8.RuneStart(body[start]) {
+			start--
+		}
+		if start >= 0 && !utf8.FullRune(body[start:end]) {
+			end = start
+		}
+		// Always make progress, even when the limit is smaller than a rune
+		if end == 0 {
+			_, end = utf8.DecodeRune(body)
+		}
+	}
+
+	out := &Out{
+		Status:  res.StatusCode,
+		Content: string(body[:end]),
+		Size:    max(size, 0),
+	}
+	if more(end) {
+		out.NextOffset = offset + int64(end)
+	}
+	return out, nil
+}
+
+// rangeSize parses the total size from a Content-Range header like
+// "bytes 0-99/1234", returning -1 when it's unknown
+func rangeSize(contentRange string) int64 {
+	_, total, ok := strings.Cut(contentRange, "/")
+	if !ok || total == "*" {
+		return -1
+	}
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
+
+// truncate s to at most limit bytes, cutting at the last line break when
+// there is one and otherwise at a rune boundary so the result is valid UTF-8
+func truncate(s string, limit int) (string, bool) {
+	if len(s) <= limit {
+		return s, false
+	}
+	if i := strings.LastIndexByte(s[:limit], '\n'); i > 0 {
+		return s[:i+1], true
+	}
+	// Back up to the start of the rune that straddles the limit
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit], true
+}
+
+// isHTML checks whether the content type is an HTML document
+func isHTML(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
@@ -0,0 +1,165 @@
+package fetch_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm/tool/fetch"
+)
+
+func run(t *testing.T, in fetch.In) *fetch.Out {
+	t.Helper()
+	is := is.New(t)
+	input, err := json.Marshal(in)
+	is.NoErr(err)
+	out, err := fetch.New(http.DefaultClient).Run(context.Background(), input)
+	is.NoErr(err)
+	result := new(fetch.Out)
+	is.NoErr(json.Unmarshal(out, result))
+	return result
+}
+
+func serve(t *testing.T, contentType, body string) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+const article = `<article>
+<h1>Gophers</h1>
+<p>Gophers are small burrowing rodents that live in North and Central America. They spend most of their lives underground, digging long tunnels with their strong front claws.</p>
+<p>They eat roots, tubers and other plants, and store food in the pouches in their cheeks. A single gopher can move a surprising amount of soil in a year.</p>
+<p>Gophers live alone outside of the breeding season and defend their burrows from other gophers.</p>
+</article>`
+
+func TestReadability(t *testing.T) {
+	is := is.New(t)
+	url := serve(t, "text/html; charset=utf-8", `<html><head><title>All about gophers</title></head><body>
+<nav><a href="/">Home</a> <a href="/about">About us</a></nav>
+`+article+`
+<footer>Copyright Example Inc</footer>
+</body></html>`)
+	out := run(t, fetch.In{URL: url})
+	is.Equal(out.Status, http.StatusOK)
+	is.Equal(out.Title, "All about gophers")
+	is.True(strings.Contains(out.Content, "burrowing rodents"))
+	is.True(!strings.Contains(out.Content, "About us"))
+	is.True(!strings.Contains(out.Content, "Copyright"))
+	is.True(!out.Truncated)
+}
+
+func TestReadabilityAfterMarkup(t *testing.T) {
+	is := is.New(t)
+	// More markup than we return comes before the article
+	styles := strings.Repeat(".gopher { color: brown; }\n", 10_000)
+	url := serve(t, "text/html", `<html><head><title>Gophers</title><style>`+styles+`</style></head><body>`+article+`</body></html>`)
+	out := run(t, fetch.In{URL: url})
+	is.True(strings.Contains(out.Content, "burrowing rodents"))
+	is.True(!strings.Contains(out.Content, "color: brown"))
+	is.True(!out.Truncated)
+}
+
+func TestTruncate(t *testing.T) {
+	is := is.New(t)
+	page := new(strings.Builder)
+	page.WriteString("<html><body><article>")
+	for i := range 5_000 {
+		fmt.Fprintf(page, "<p>Paragraph %d about gophers and their long burrows.</p>\n", i)
+	}
+	page.WriteString("</article></body></html>")
+	url := serve(t, "text/html", page.String())
+	out := run(t, fetch.In{URL: url})
+	is.True(out.Truncated)
+	is.True(len(out.Content) <= 100_000)
+	is.True(strings.HasPrefix(out.Content, "Paragraph 0 about gophers"))
+	// Cut at a line break
+	is.True(strings.HasSuffix(out.Content, "\n"))
+	is.True(strings.HasSuffix(strings.TrimSpace(out.Content), "burrows."))
+}
+
+// serveRanges serves the content with support for range requests
+func serveRanges(t *testing.T, content string) (url string, ranges *[]string) {
+	t.Helper()
+	ranges = new([]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*ranges = append(*ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "data.txt", time.Time{}, strings.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, ranges
+}
+
+func TestRange(t *testing.T) {
+	is := is.New(t)
+	url, ranges := serveRanges(t, "0123456789abcdefghij")
+	out := run(t, fetch.In{URL: url, Offset: 5, Limit: 10})
+	is.Equal(*ranges, []string{"bytes=5-14"})
+	is.Equal(out.Status, http.StatusPartialContent)
+	is.Equal(out.Content, "56789abcde")
+	is.Equal(out.Size, int64(20))
+	is.Equal(out.NextOffset, int64(15))
+
+	// The last page has no next offset
+	out = run(t, fetch.In{URL: url, Offset: 15, Limit: 10})
+	is.Equal(out.Content, "fghij")
+	is.Equal(out.NextOffset, int64(0))
+}
+
+func TestRangeIgnored(t *testing.T) {
+	is := is.New(t)
+	// The server sends the whole body, so the range is sliced locally
+	url := serve(t, "text/plain", "0123456789abcdefghij")
+	out := run(t, fetch.In{URL: url, Offset: 5, Limit: 10})
+	is.Equal(out.Status, http.StatusOK)
+	is.Equal(out.Content, "56789abcde")
+	is.Equal(out.Size, int64(20))
+	is.Equal(out.NextOffset, int64(15))
+
+	out = run(t, fetch.In{URL: url, Offset: 15})
+	is.Equal(out.Content, "fghij")
+	is.Equal(out.NextOffset, int64(0))
+}
+
+func TestRangeNotSatisfiable(t *testing.T) {
+	is := is.New(t)
+	url, _ := serveRanges(t, "0123456789")
+	out := run(t, fetch.In{URL: url, Offset: 50, Limit: 10})
+	is.Equal(out.Status, http.StatusRequestedRangeNotSatisfiable)
+	is.Equal(out.Content, "")
+	is.Equal(out.Size, int64(10))
+	is.Equal(out.NextOffset, int64(0))
+}
+
+func TestRangeRune(t *testing.T) {
+	is := is.New(t)
+	// The page ends partway through "é", so the next page starts with it
+	url, _ := serveRanges(t, "abcdé")
+	out := run(t, fetch.In{URL: url, Limit: 5})
+	is.Equal(out.Content, "abcd")
+	is.Equal(out.NextOffset, int64(4))
+	out = run(t, fetch.In{URL: url, Offset: 4, Limit: 5})
+	is.Equal(out.Content, "é")
+}
+
+func TestRangeRuneOverLimit(t *testing.T) {
+	is := is.New(t)
+	// The limit is smaller than each rune, so each page returns a whole one
+	url, _ := serveRanges(t, "日本")
+	out := run(t, fetch.In{URL: url, Limit: 1})
+	is.Equal(out.Content, "日")
+	is.Equal(out.NextOffset, int64(3))
+	out = run(t, fetch.In{URL: url, Offset: 3, Limit: 1})
+	is.Equal(out.Content, "本")
+	is.Equal(out.NextOffset, int64(0))
+}
@@ -0,0 +1,97 @@
+package pty
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/matthewmueller/llm"
+	"github.com/matthewmueller/llm/sandbox"
+)
+
+const defaultTimeout = 10_000 * time.Millisecond
+
+// Size of the terminal when only one of rows or cols is given
+const (
+	defaultRows = 24
+	defaultCols = 80
+)
+
+const description = `Runs a command in a pseudo-terminal and returns the captured terminal output.
+- Use this instead of ` + "`" + `shell` + "`" + ` for programs that need a TTY, buffer their output without one or change their behavior when they detect one isn't attached.
+- Programs that don't exit on their own (e.g. ` + "`" + `top` + "`" + `) are stopped after the timeout and the output so far is returned.
+- Use ` + "`" + `input` + "`" + ` to answer interactive prompts, e.g. "y\n".
+`
+
+type In struct {
+	Cmd       string   `json:"cmd" is:"required" description:"The name of the command to execute"`
+	Args      []string `json:"args" is:"required" description:"The arguments to the command"`
+	WorkDir   string   `json:"workdir" description:"The working directory to execute the command in"`
+	Input     string   `json:"input" description:"Text to type into the terminal"`
+	Rows      int      `json:"rows" description:"The height of the terminal in characters"`
+	Cols      int      `json:"cols" description:"The width of the terminal in characters"`
+	TimeoutMs int      `json:"timeout_ms" description:"The timeout for the command in milliseconds"`
+}
+
+type Out struct {
+	Output   string `json:"output" description:"The terminal output with escape sequences removed"`
+	ExitCode int    `json:"exit_code" description:"The exit code of the command, or -1 if it was stopped"`
+	TimedOut bool   `json:"timed_out,omitzero" description:"Whether the command was stopped after the timeout"`
+}
+
+func New(exec *sandbox.Exec) llm.Tool {
+	return llm.Func("pty", description, func(ctx context.Context, in In) (*Out, error) {
+		timeout := defaultTimeout
+		if in.TimeoutMs > 0 {
+			timeout = time.Duration(in.TimeoutMs) * time.Millisecond
+		}
+		cmdCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(cmdCtx, in.Cmd, in.Args...)
+		cmd.Dir = in.WorkDir
+		cmd.TTY = true
+		if in.Rows > 0 || in.Cols > 0 {
+			cmd.WindowSize = &sandbox.WindowSize{Rows: defaultRows, Cols: defaultCols}
+			if in.Rows > 0 {
+				cmd.WindowSize.Rows = uint16(in.Rows)
+			}
+			if in.Cols > 0 {
+				cmd.WindowSize.Cols = uint16(in.Cols)
+			}
+		}
+		if in.Input != "" {
+			cmd.Stdin = strings.NewReader(in.Input)
+		}
+		out := new(bytes.Buffer)
+		cmd.Stdout = out
+
+		err := cmd.Run()
+		// Return what the command printed before it was stopped
+		timedOut := errors.Is(cmdCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+		if err != nil && !timedOut && cmd.ExitCode() < 0 {
+			return nil, err
+		}
+
+		return &Out{
+			Output:   clean(out.String()),
+			ExitCode: cmd.ExitCode(),
+			TimedOut: timedOut,
+		}, nil
+	})
+}
+
+// Matches CSI sequences like colors and cursor movement, OSC sequences like
+// window titles and other two-character escapes like saving the cursor
+var escapes = regexp.MustCompile(`\x1b(\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)|[0-~])`)
+
+// clean removes terminal escape sequences and normalizes line endings so the
+// output is readable as plain text
+func clean(output string) string {
+	output = escapes.ReplaceAllString(output, "")
+	output = strings.ReplaceAll(output, "\r\n", "\n")
+	return output
+}
@@ -0,0 +1,67 @@
+package pty
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm/sandbox/local"
+)
+
+func TestClean(t *testing.T) {
+	is := is.New(t)
+	// Colors, cursor movement, a window title and CRLF line endings
+	output := "\x1b]0;title\x07\x1b[1;32mok\x1b[0m\r\n\x1b[2K\x1b[1Gdone\x1b7\r\n"
+	is.Equal(clean(output), "ok\ndone\n")
+}
+
+func run(t *testing.T, in string) *Out {
+	t.Helper()
+	is := is.New(t)
+	out, err := New(local.New(t.TempDir())).Run(context.Background(), json.RawMessage(in))
+	is.NoErr(err)
+	result := new(Out)
+	is.NoErr(json.Unmarshal(out, result))
+	return result
+}
+
+func TestTerminal(t *testing.T) {
+	is := is.New(t)
+	out := run(t, `{"cmd":"sh","args":["-c","test -t 1 && printf '\\033[31mtty\\033[0m'"]}`)
+	is.Equal(out.Output, "tty")
+	is.Equal(out.ExitCode, 0)
+	is.True(!out.TimedOut)
+}
+
+func TestPrompt(t *testing.T) {
+	is := is.New(t)
+	out := run(t, `{"cmd":"sh","args":["-c","printf 'Continue? '; read answer; echo \"answer=$answer\""],"input":"y\n"}`)
+	is.Equal(out.ExitCode, 0)
+	is.True(!out.TimedOut)
+	is.Equal(out.Output[len(out.Output)-len("answer=y\n"):], "answer=y\n")
+}
+
+func TestTimeout(t *testing.T) {
+	is := is.New(t)
+	out := run(t, `{"cmd":"sh","args":["-c","echo started; sleep 10"],"timeout_ms":200}`)
+	is.True(out.TimedOut)
+	is.Equal(out.ExitCode, -1)
+	is.Equal(out.Output, "started\n")
+}
+
+func TestExitCode(t *testing.T) {
+	is := is.New(t)
+	out := run(t, `{"cmd":"sh","args":["-c","echo failed; exit 3"]}`)
+	is.Equal(out.ExitCode, 3)
+	is.Equal(out.Output, "failed\n")
+}
+
+func TestSize(t *testing.T) {
+	is := is.New(t)
+	out := run(t, `{"cmd":"stty","args":["size"],"cols":120}`)
+	is.Equal(out.ExitCode, 0)
+	is.Equal(out.Output, "24 120\n")
+	out = run(t, `{"cmd":"stty","args":["size"],"rows":40}`)
+	is.Equal(out.Output, "40 80\n")
+}
@@ -0,0 +1,95 @@
+package read
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/matthewmueller/llm"
+	"github.com/matthewmueller/llm/sandbox"
+)
+
+// maxImageSize is the largest image the providers accept inline
+const maxImageSize = 5 << 20
+
+const description = `Reads a file and returns its contents.
+- PNG, JPEG, GIF and WebP images are returned as images you can look at
+- Other files are returned as text
+`
+
+type In struct {
+	Path string `json:"path" is:"required" description:"The path of the file to read"`
+}
+
+type Out struct {
+	Path      string `json:"path"`
+	Size      int    `json:"size" description:"The size of the file in bytes"`
+	Content   string `json:"content,omitzero" description:"The text of the file, when it isn't an image"`
+	MediaType string `json:"media_type,omitzero" description:"The media type of the image, when it's an image"`
+}
+
+// imageTypes are the image media types the providers accept
+var imageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// New returns a tool that reads files in the sandbox. Images come back as
+// multimodal tool results, so only give it to models that accept images.
+func New(exec *sandbox.Exec) llm.MediaTool {
+	t := &tool{exec: exec}
+	t.Tool = llm.Func("read", description, func(ctx context.Context, in In) (*Out, error) {
+		out, _, err := t.read(ctx, in.Path)
+		return out, err
+	})
+	return t
+}
+
+type tool struct {
+	llm.Tool
+	exec *sandbox.Exec
+}
+
+// RunParts reads the file, returning images alongside the result's text
+func (t *tool) RunParts(ctx context.Context, args json.RawMessage) ([]*llm.ContentPart, error) {
+	var in In
+	if err := json.Unmarshal(args, &in); err != nil {
+		return nil, fmt.Errorf("tool read: unmarshaling input: %w", err)
+	}
+	out, image, err := t.read(ctx, in.Path)
+	if err != nil {
+		return nil, err
+	}
+	text, err := llm.CanonicalJSON(out)
+	if err != nil {
+		return nil, err
+	}
+	parts := []*llm.ContentPart{{Type: "text", Text: string(text)}}
+	if image != nil {
+		parts = append(parts, image)
+	}
+	return parts, nil
+}
+
+// read reads the file through the sandbox, sniffing the content to tell
+// images apart from text
+func (t *tool) read(ctx context.Context, path string) (*Out, *llm.ContentPart, error) {
+	data, err := t.exec.CommandContext(ctx, "cat", path).Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("read: reading %q: %w", path, err)
+	}
+	out := &Out{Path: path, Size: len(data)}
+	mediaType := http.DetectContentType(data)
+	if !imageTypes[mediaType] {
+		out.Content = string(data)
+		return out, nil, nil
+	}
+	if len(data) > maxImageSize {
+		return nil, nil, fmt.Errorf("read: image %q is %d bytes, over the %d byte limit", path, len(data), maxImageSize)
+	}
+	out.MediaType = mediaType
+	return out, &llm.ContentPart{Type: "image", MediaType: mediaType, Data: data}, nil
+}
@@ -0,0 +1,62 @@
+package read_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+	"github.com/matthewmueller/llm/sandbox/local"
+	"github.com/matthewmueller/llm/tool/read"
+)
+
+func TestImage(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	buf := new(bytes.Buffer)
+	is.NoErr(png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 2, 2))))
+	is.NoErr(os.WriteFile(filepath.Join(dir, "dot.png"), buf.Bytes(), 0644))
+
+	parts, err := read.New(local.New(dir)).RunParts(context.Background(), json.RawMessage(`{"path":"dot.png"}`))
+	is.NoErr(err)
+	is.Equal(len(parts), 2)
+	is.Equal(parts[0].Type, "text")
+	var out read.Out
+	is.NoErr(json.Unmarshal([]byte(parts[0].Text), &out))
+	is.Equal(out.MediaType, "image/png")
+	is.Equal(out.Size, buf.Len())
+	is.Equal(out.Content, "")
+	is.Equal(parts[1], &llm.ContentPart{Type: "image", MediaType: "image/png", Data: buf.Bytes()})
+}
+
+func TestText(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	is.NoErr(os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hello\n"), 0644))
+
+	tool := read.New(local.New(dir))
+	parts, err := tool.RunParts(context.Background(), json.RawMessage(`{"path":"notes.txt"}`))
+	is.NoErr(err)
+	is.Equal(len(parts), 1)
+	var out read.Out
+	is.NoErr(json.Unmarshal([]byte(parts[0].Text), &out))
+	is.Equal(out.Content, "hello\n")
+	is.Equal(out.MediaType, "")
+
+	// Run returns the same result for callers that don't handle images
+	result, err := tool.Run(context.Background(), json.RawMessage(`{"path":"notes.txt"}`))
+	is.NoErr(err)
+	is.Equal(string(result), parts[0].Text)
+}
+
+func TestMissing(t *testing.T) {
+	is := is.New(t)
+	_, err := read.New(local.New(t.TempDir())).RunParts(context.Background(), json.RawMessage(`{"path":"missing.png"}`))
+	is.True(err != nil)
+}
@@ -1,7 +1,6 @@
 package shell
 
 import (
-	"bytes"
 	"context"
 	"time"
 
@@ -28,7 +27,26 @@ type Out struct {
 	Output string `json:"output" description:"The combined output of stdout and stderr"`
 }
 
-func New(exec *sandbox.Exec) llm.Tool {
+// Option configures the shell tool
+type Option func(*config)
+
+type config struct {
+	env []string
+}
+
+// WithEnv adds KEY=value pairs to the environment of every command the tool
+// runs
+func WithEnv(env ...string) Option {
+	return func(c *config) {
+		c.env = append(c.env, env...)
+	}
+}
+
+func New(exec *sandbox.Exec, options ...Option) llm.Tool {
+	config := &config{}
+	for _, option := range options {
+		option(config)
+	}
 	return llm.Func("shell", description, func(ctx context.Context, in In) (*Out, error) {
 		timeout := defaultTimeout
 		if in.TimeoutMs > 0 {
@@ -39,19 +57,17 @@ func New(exec *sandbox.Exec) llm.Tool {
 
 		cmd := exec.CommandContext(ctx, in.Cmd, in.Args...)
 		cmd.Dir = in.WorkDir
+		cmd.Env = config.env
 
-		out := new(bytes.Buffer)
-		cmd.Stdout = out
-		cmd.Stderr = out
-
-		// Run the command
-		if err := cmd.Run(); err != nil {
+		// Run the command, keeping stdout and stderr in the order they're written
+		out, err := cmd.CombinedOutput()
+		if err != nil {
 			return nil, err
 		}
 
 		// Return a combined output of stdout and stderr
 		return &Out{
-			Output: out.String(),
+			Output: string(out),
 		}, nil
 	})
 }
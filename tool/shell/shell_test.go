@@ -0,0 +1,21 @@
+package shell_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm/sandbox/local"
+	"github.com/matthewmueller/llm/tool/shell"
+)
+
+func TestEnv(t *testing.T) {
+	is := is.New(t)
+	tool := shell.New(local.New(t.TempDir()), shell.WithEnv("GREETING=hello"))
+	out, err := tool.Run(context.Background(), json.RawMessage(`{"cmd":"sh","args":["-c","echo $GREETING"]}`))
+	is.NoErr(err)
+	var result shell.Out
+	is.NoErr(json.Unmarshal(out, &result))
+	is.Equal(result.Output, "hello\n")
+}
@@ -0,0 +1,39 @@
+package stored
+
+import (
+	"context"
+
+	"github.com/matthewmueller/llm"
+)
+
+const description = `Reads a tool result that was too large to include in the conversation.
+- Use the ` + "`" + `ref` + "`" + ` from the stored result in place of the original output
+- Set ` + "`" + `offset` + "`" + ` and ` + "`" + `limit` + "`" + ` to page through the result, and ` + "`" + `next_offset` + "`" + ` is where the next page starts
+`
+
+type In struct {
+	Ref    string `json:"ref" is:"required" description:"The ref of the stored result"`
+	Offset int    `json:"offset" description:"The byte offset to start reading from"`
+	Limit  int    `json:"limit" description:"The maximum number of bytes to read"`
+}
+
+type Out struct {
+	Content    string `json:"content"`
+	NextOffset int    `json:"next_offset,omitzero" description:"The offset of the next page, when more content remains"`
+}
+
+// New returns a tool that reads tool results kept in the store set with
+// llm.WithResultStore
+func New(store *llm.ResultStore) llm.Tool {
+	return llm.Func("get_stored", description, func(ctx context.Context, in In) (*Out, error) {
+		content, next, err := store.Get(in.Ref, in.Offset, in.Limit)
+		if err != nil {
+			return nil, err
+		}
+		out := &Out{Content: content}
+		if next > 0 {
+			out.NextOffset = next
+		}
+		return out, nil
+	})
+}
@@ -0,0 +1,43 @@
+package stored_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+	"github.com/matthewmueller/llm/tool/stored"
+)
+
+func TestGetStored(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	store := llm.NewResultStore(5)
+	store.Put("call_1", "héllo world")
+	tool := stored.New(store)
+
+	out, err := tool.Run(ctx, json.RawMessage(`{"ref":"call_1","limit":2}`))
+	is.NoErr(err)
+	var page stored.Out
+	is.NoErr(json.Unmarshal(out, &page))
+	is.Equal(page.Content, "h") // Doesn't split the é
+	is.Equal(page.NextOffset, 1)
+
+	out, err = tool.Run(ctx, json.RawMessage(`{"ref":"call_1","offset":1}`))
+	is.NoErr(err)
+	is.NoErr(json.Unmarshal(out, &page))
+	is.Equal(page.Content, "éllo") // Up to the store's limit
+	is.Equal(page.NextOffset, 6)
+
+	out, err = tool.Run(ctx, json.RawMessage(`{"ref":"call_1","offset":6,"limit":100}`))
+	is.NoErr(err)
+	page = stored.Out{}
+	is.NoErr(json.Unmarshal(out, &page))
+	is.Equal(page.Content, " world")
+	is.Equal(page.NextOffset, 0)
+
+	_, err = tool.Run(ctx, json.RawMessage(`{"ref":"missing"}`))
+	is.True(err != nil)
+}
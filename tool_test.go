@@ -2,7 +2,10 @@ package llm_test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/matryer/is"
 	"github.com/matthewmueller/llm"
@@ -48,3 +51,248 @@ func TestFuncSchemaSliceTypes(t *testing.T) {
 	is.Equal(props["ptr"].Type, "array")
 	is.Equal(props["ptr"].Items.Type, "string")
 }
+
+func TestFuncSchemaNestedStruct(t *testing.T) {
+	is := is.New(t)
+
+	type Street struct {
+		Line1 string `json:"line1" description:"First line" is:"required"`
+		Line2 string `json:"line2"`
+	}
+	type Address struct {
+		Street Street    `json:"street" description:"Street address" is:"required"`
+		City   string    `json:"city" is:"required"`
+		Moved  time.Time `json:"moved"`
+	}
+	tool := llm.Func("nested", "tests nested schema generation", func(ctx context.Context, in struct {
+		Name    string     `json:"name" is:"required"`
+		Address *Address   `json:"address" description:"Home address"`
+		Others  []*Address `json:"others"`
+	}) (string, error) {
+		return "", nil
+	})
+
+	schema := tool.Schema()
+	props := schema.Function.Parameters.Properties
+
+	address := props["address"]
+	is.Equal(address.Type, "object")
+	is.Equal(address.Description, "Home address")
+	is.Equal(address.Required, []string{"street", "city"})
+	is.Equal(address.Properties["city"].Type, "string")
+	is.Equal(address.Properties["moved"].Type, "string")
+
+	street := address.Properties["street"]
+	is.Equal(street.Type, "object")
+	is.Equal(street.Description, "Street address")
+	is.Equal(street.Required, []string{"line1"})
+	is.Equal(street.Properties["line1"].Type, "string")
+	is.Equal(street.Properties["line1"].Description, "First line")
+	is.Equal(street.Properties["line2"].Type, "string")
+
+	is.Equal(props["others"].Type, "array")
+	is.Equal(props["others"].Items.Type, "object")
+	is.Equal(props["others"].Items.Properties["street"].Properties["line1"].Type, "string")
+}
+
+func TestFuncSchemaRecursiveStruct(t *testing.T) {
+	is := is.New(t)
+
+	type Node struct {
+		Value    int     `json:"value"`
+		Children []*Node `json:"children"`
+	}
+	tool := llm.Func("tree", "tests recursive schema generation", func(ctx context.Context, in struct {
+		Root Node `json:"root"`
+	}) (string, error) {
+		return "", nil
+	})
+
+	root := tool.Schema().Function.Parameters.Properties["root"]
+	is.Equal(root.Type, "object")
+	is.Equal(root.Properties["value"].Type, "integer")
+	is.Equal(root.Properties["children"].Items.Type, "object")
+	is.Equal(len(root.Properties["children"].Items.Properties), 0)
+}
+
+func TestFuncSchemaMapTypes(t *testing.T) {
+	is := is.New(t)
+
+	type Score struct {
+		Points int    `json:"points" is:"required"`
+		Note   string `json:"note"`
+	}
+	tool := llm.Func("map_types", "tests map schema generation", func(ctx context.Context, in struct {
+		Labels map[string]string `json:"labels"`
+		Counts map[string]int    `json:"counts"`
+		Scores map[string]Score  `json:"scores"`
+		Extra  map[string]any    `json:"extra"`
+	}) (string, error) {
+		return "", nil
+	})
+
+	props := tool.Schema().Function.Parameters.Properties
+
+	is.Equal(props["labels"].Type, "object")
+	is.Equal(props["labels"].AdditionalProperties.Type, "string")
+
+	is.Equal(props["counts"].Type, "object")
+	is.Equal(props["counts"].AdditionalProperties.Type, "integer")
+
+	is.Equal(props["scores"].Type, "object")
+	scores := props["scores"].AdditionalProperties
+	is.Equal(scores.Type, "object")
+	is.Equal(scores.Properties["points"].Type, "integer")
+	is.Equal(scores.Properties["note"].Type, "string")
+	is.Equal(scores.Required, []string{"points"})
+
+	is.Equal(props["extra"].Type, "object")
+	is.Equal(props["extra"].AdditionalProperties, nil)
+}
+
+func TestFuncSchemaPointerFields(t *testing.T) {
+	is := is.New(t)
+
+	type Options struct {
+		Verbose bool `json:"verbose"`
+	}
+	tool := llm.Func("pointers", "tests pointer schema generation", func(ctx context.Context, in struct {
+		Name    string   `json:"name" is:"required"`
+		Limit   *int     `json:"limit" is:"required"`
+		Ratio   *float64 `json:"ratio"`
+		Label   *string  `json:"label"`
+		Enabled **bool   `json:"enabled"`
+		Options *Options `json:"options" is:"required"`
+		Count   int      `json:"count"`
+	}) (string, error) {
+		return "", nil
+	})
+
+	params := tool.Schema().Function.Parameters
+	props := params.Properties
+
+	// Pointers describe the underlying type
+	is.Equal(props["limit"].Type, "integer")
+	is.Equal(props["ratio"].Type, "number")
+	is.Equal(props["label"].Type, "string")
+	is.Equal(props["enabled"].Type, "boolean")
+	is.Equal(props["options"].Type, "object")
+	is.Equal(props["options"].Properties["verbose"].Type, "boolean")
+
+	// Pointers are never required, other fields are optional unless tagged
+	is.Equal(params.Required, []string{"name"})
+}
+
+func TestFuncSchemaConstraints(t *testing.T) {
+	is := is.New(t)
+
+	tool := llm.Func("constraints", "tests constraint schema generation", func(ctx context.Context, in struct {
+		Page  int     `json:"page" min:"1" max:"100"`
+		Ratio float64 `json:"ratio" min:"0.5"`
+		Slug  string  `json:"slug" minLength:"1" maxLength:"64" pattern:"^[a-z-]+$"`
+		Other string  `json:"other" min:"nope"`
+	}) (string, error) {
+		return "", nil
+	})
+
+	props := tool.Schema().Function.Parameters.Properties
+	is.Equal(*props["page"].Minimum, 1.0)
+	is.Equal(*props["page"].Maximum, 100.0)
+	is.Equal(*props["ratio"].Minimum, 0.5)
+	is.Equal(props["ratio"].Maximum, nil)
+	is.Equal(*props["slug"].MinLength, 1)
+	is.Equal(*props["slug"].MaxLength, 64)
+	is.Equal(props["slug"].Pattern, "^[a-z-]+$")
+
+	// Unparsable values are ignored
+	is.Equal(props["other"].Minimum, nil)
+}
+
+func TestFuncSchemaDefaults(t *testing.T) {
+	is := is.New(t)
+
+	tool := llm.Func("defaults", "tests default schema generation", func(ctx context.Context, in struct {
+		TimeoutMS int      `json:"timeout_ms" default:"10000"`
+		Ratio     float64  `json:"ratio" default:"0.5"`
+		Verbose   bool     `json:"verbose" default:"true"`
+		Format    string   `json:"format" default:"text"`
+		Tags      []string `json:"tags" default:"[\"a\",\"b\"]"`
+		Limit     *int     `json:"limit" default:"nope"`
+		Other     string   `json:"other"`
+	}) (string, error) {
+		return "", nil
+	})
+
+	props := tool.Schema().Function.Parameters.Properties
+	is.Equal(props["timeout_ms"].Default, int64(10000))
+	is.Equal(props["ratio"].Default, 0.5)
+	is.Equal(props["verbose"].Default, true)
+	is.Equal(props["format"].Default, "text")
+	is.Equal(props["tags"].Default, []any{"a", "b"})
+
+	// Unparsable and missing defaults are left unset
+	is.Equal(props["limit"].Default, nil)
+	is.Equal(props["other"].Default, nil)
+}
+
+func TestFuncCoerceNumbers(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	type point struct {
+		X int `json:"x"`
+	}
+	tool := llm.Func("sum", "Sum numbers", func(ctx context.Context, in struct {
+		A      int      `json:"a"`
+		Count  *uint    `json:"count"`
+		Ratio  float64  `json:"ratio"`
+		Points []point  `json:"points"`
+		Scores []int64  `json:"scores"`
+		Name   string   `json:"name"`
+		Tags   []string `json:"tags"`
+	}) (int, error) {
+		sum := in.A + int(*in.Count) + int(in.Ratio*10) + len(in.Name) + len(in.Tags)
+		for _, p := range in.Points {
+			sum += p.X
+		}
+		for _, s := range in.Scores {
+			sum += int(s)
+		}
+		return sum, nil
+	})
+
+	for _, args := range []string{
+		`{"a":42.0,"count":"1","ratio":"0.5","points":[{"x":1e1}],"scores":[4.2e1],"name":"7","tags":["1"]}`,
+		`{"a":"42","count":1.0,"ratio":0.5,"points":[{"X":"10"}],"scores":["42"],"name":"7","tags":["1"]}`,
+		`{"a":4.2e1,"count":1,"ratio":0.5,"points":[{"x":10}],"scores":[42],"name":"7","tags":["1"]}`,
+	} {
+		out, err := tool.Run(ctx, json.RawMessage(args))
+		is.NoErr(err)
+		is.Equal(string(out), "102")
+	}
+
+	// Fractions aren't rounded into ints
+	_, err := tool.Run(ctx, json.RawMessage(`{"a":42.5,"count":1}`))
+	is.True(err != nil)
+	_, err = tool.Run(ctx, json.RawMessage(`{"a":"forty-two","count":1}`))
+	is.True(err != nil)
+}
+
+func TestFuncCoerceLargeInts(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	tool := llm.Func("echo", "Echo the IDs", func(ctx context.Context, in struct {
+		ID       int64   `json:"id"`
+		Unsigned uint64  `json:"unsigned"`
+		Count    int     `json:"count"`
+		Ratio    float64 `json:"ratio"`
+	}) (string, error) {
+		return fmt.Sprintf("%d %d %d", in.ID, in.Unsigned, in.Count), nil
+	})
+
+	// Integers above 2^53 survive other fields being coerced
+	out, err := tool.Run(ctx, json.RawMessage(`{"id":9007199254740993,"unsigned":"18446744073709551615","count":2.0,"ratio":"0.5"}`))
+	is.NoErr(err)
+	is.Equal(string(out), `"9007199254740993 18446744073709551615 2"`)
+}
@@ -0,0 +1,72 @@
+package llm
+
+// Truncation configures how an agent trims its history to fit the model's
+// context window
+type Truncation struct {
+	KeepSystem bool // Never drop system messages
+	KeepLastN  int  // Number of most recent turns to always keep
+}
+
+// WithTruncation drops the agent's oldest turns before each request until
+// the estimated tokens fit in the model's context window, leaving room for
+// the maximum output. A turn starts with a user message and includes the
+// responses and tool calls that follow it, so tool calls are never separated
+// from their results. The agent keeps its full history; only the request is
+// truncated. Models without a known context window aren't truncated.
+func WithTruncation(keepSystem bool, keepLastN int) Option {
+	return func(c *Config) {
+		c.Truncation = &Truncation{
+			KeepSystem: keepSystem,
+			KeepLastN:  keepLastN,
+		}
+	}
+}
+
+// truncate drops the oldest turns until the messages fit in the budget. If
+// they still don't fit, as much as allowed is dropped.
+func truncate(messages []*Message, tools []*ToolSchema, budget int, config *Truncation) []*Message {
+	if EstimateTokens(messages, tools) <= budget {
+		return messages
+	}
+
+	turns := splitTurns(messages)
+
+	// Always keep the current turn
+	keep := max(config.KeepLastN, 1)
+
+	var truncated []*Message
+	for drop := 1; drop <= len(turns)-keep; drop++ {
+		truncated = truncated[:0]
+		if config.KeepSystem {
+			for _, turn := range turns[:drop] {
+				for _, message := range turn {
+					if message.Role == "system" {
+						truncated = append(truncated, message)
+					}
+				}
+			}
+		}
+		for _, turn := range turns[drop:] {
+			truncated = append(truncated, turn...)
+		}
+		if EstimateTokens(truncated, tools) <= budget {
+			break
+		}
+	}
+	if truncated == nil {
+		return messages
+	}
+	return truncated
+}
+
+// splitTurns splits the history into turns that start with a user message.
+// Messages before the first user message are a turn of their own.
+func splitTurns(messages []*Message) (turns [][]*Message) {
+	for _, message := range messages {
+		if len(turns) == 0 || message.Role == "user" {
+			turns = append(turns, nil)
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], message)
+	}
+	return turns
+}
@@ -0,0 +1,95 @@
+package llm_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func TestAgentTruncation(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	answer := strings.Repeat("a", 80) // About 20 tokens
+	provider := &fakeProvider{
+		meta: &llm.ModelMeta{ContextWindow: 240, MaxOutputTokens: 10},
+		turns: [][]*llm.ChatResponse{
+			{toolCall("call_1", "add", `{"a":1,"b":1}`), done()},
+			{text(answer), done()},
+			{toolCall("call_2", "add", `{"a":2,"b":2}`), done()},
+			{text(answer), done()},
+			{toolCall("call_3", "add", `{"a":3,"b":3}`), done()},
+			{text(answer), done()},
+		},
+	}
+	agent := llm.New(provider).Agent(provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithTool(addTool),
+		llm.WithMessage(llm.SystemMessage("You add numbers")),
+		llm.WithTruncation(true, 1),
+	)
+
+	for _, prompt := range []string{"1+1?", "2+2?", "3+3?"} {
+		for _, err := range agent.Chat(ctx, prompt) {
+			is.NoErr(err)
+		}
+	}
+
+	// The agent keeps its full history
+	is.Equal(len(agent.Messages()), 13)
+
+	// The last turn's request dropped the oldest turn but kept the system
+	// message
+	requests := provider.Requests()
+	messages := requests[len(requests)-2].Messages
+	is.True(llm.EstimateTokens(messages, []*llm.ToolSchema{addTool.Schema()}) <= 230)
+	is.Equal(messages[0].Role, "system")
+	is.Equal(messages[1].Content, "2+2?")
+
+	// Tool calls stayed with their results
+	for i, message := range messages {
+		if message.ToolCall != nil {
+			is.Equal(messages[i+1].ToolCallID, message.ToolCall.ID)
+		}
+		if message.ToolCallID != "" {
+			is.Equal(messages[i-1].ToolCall.ID, message.ToolCallID)
+		}
+	}
+}
+
+func TestAgentTruncationKeepLastN(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	answer := strings.Repeat("a", 400)
+	provider := &fakeProvider{
+		meta: &llm.ModelMeta{ContextWindow: 50},
+		turns: [][]*llm.ChatResponse{
+			{text(answer), done()},
+			{text(answer), done()},
+			{text(answer), done()},
+		},
+	}
+	agent := llm.New(provider).Agent(provider.Name(),
+		llm.WithModel("fake-model"),
+		llm.WithMessage(llm.SystemMessage("Be brief")),
+		llm.WithTruncation(false, 2),
+	)
+
+	for _, prompt := range []string{"one", "two", "three"} {
+		for _, err := range agent.Chat(ctx, prompt) {
+			is.NoErr(err)
+		}
+	}
+
+	// Nothing fits, so everything but the last 2 turns was dropped,
+	// including the system message
+	requests := provider.Requests()
+	messages := requests[len(requests)-1].Messages
+	is.Equal(len(messages), 3)
+	is.Equal(messages[0].Content, "two")
+	is.Equal(messages[2].Content, "three")
+}
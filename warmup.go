@@ -0,0 +1,36 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// Warmup gets the model ready ahead of the first real request, so the first
+// turn isn't slowed down by a cold start. Providers that implement Warmer load
+// the model directly. Other providers are sent a tiny request, which is
+// billed like any other. An empty model warms up the provider's default.
+func (c *Client) Warmup(ctx context.Context, provider, model string) error {
+	p, err := c.findProvider(provider)
+	if err != nil {
+		return err
+	}
+	config := &Config{Model: model}
+	setDefaultModel(config, p)
+	if warmer, ok := p.(Warmer); ok {
+		if err := warmer.Warmup(ctx, config.Model); err != nil {
+			return fmt.Errorf("llm: warming up %q: %w", config.Model, err)
+		}
+		return nil
+	}
+	for _, err := range p.Chat(ctx, &ChatRequest{
+		Model:           config.Model,
+		Thinking:        ThinkingNone,
+		Messages:        []*Message{UserMessage("Hi")},
+		MaxOutputTokens: 16,
+	}) {
+		if err != nil {
+			return fmt.Errorf("llm: warming up %q: %w", config.Model, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package llm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/llm"
+)
+
+func TestWarmup(t *testing.T) {
+	is := is.New(t)
+	provider := &fakeProvider{
+		model: "fake-default",
+		turns: [][]*llm.ChatResponse{{text("Hello"), done()}},
+	}
+	is.NoErr(llm.New(provider).Warmup(context.Background(), provider.Name(), ""))
+
+	// Providers that can't load models are sent a tiny request
+	requests := provider.Requests()
+	is.Equal(len(requests), 1)
+	is.Equal(requests[0].Model, "fake-default")
+	is.Equal(requests[0].Thinking, llm.ThinkingNone)
+	is.Equal(len(requests[0].Messages), 1)
+}
+
+func TestWarmupUnknownProvider(t *testing.T) {
+	is := is.New(t)
+	err := llm.New().Warmup(context.Background(), "missing", "model")
+	is.True(err != nil)
+}